@@ -0,0 +1,291 @@
+// Package client предоставляет клиент HTTP API calcflow (/api/v2) для других сервисов на Go.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiPrefix - префикс маршрутов ресурсного API.
+const apiPrefix = "/api/v2"
+
+// RetryPolicy задает повторные попытки запросов при временных ошибках.
+type RetryPolicy struct {
+	MaxAttempts int           // Максимальное количество попыток, включая первую
+	MinBackoff  time.Duration // Пауза перед второй попыткой; удваивается с каждой попыткой
+	MaxBackoff  time.Duration // Максимальная пауза между попытками
+
+	// MaxRetryAfter ограничивает ожидание по заголовку Retry-After: если сервер
+	// просит подождать дольше, например до восстановления суточной квоты,
+	// ошибка возвращается сразу. 0 снимает ограничение.
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetryPolicy - политика повторных попыток по умолчанию.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  100 * time.Millisecond,
+	MaxBackoff:  2 * time.Second,
+
+	MaxRetryAfter: 30 * time.Second,
+}
+
+// Client представляет клиент API calcflow. Методы клиента безопасны
+// для одновременного использования из нескольких горутин.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	tenant     string
+	apiKey     string
+	token      string
+	retry      RetryPolicy
+}
+
+// Option задает необязательный параметр клиента.
+type Option func(*Client)
+
+// WithHTTPClient задает HTTP-клиент для запросов к API.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTenant задает арендатора, от имени которого выполняются запросы.
+func WithTenant(tenant string) Option {
+	return func(c *Client) {
+		c.tenant = tenant
+	}
+}
+
+// WithAPIKey задает ключ API, которым подписываются запросы.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithToken задает токен доступа пользователя, полученный методом Login.
+// Пользователю доступны только его собственные выражения.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRetryPolicy задает политику повторных попыток.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// New создает клиент для сервера calcflow с адресом baseURL, например "http://localhost:8080".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		retry:      DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError представляет ответ сервера с кодом ошибки.
+type APIError struct {
+	StatusCode int           // HTTP-статус ответа
+	Message    string        // Текст ошибки из ответа сервера
+	RetryAfter time.Duration // Через сколько повторить запрос, если сервер это указал
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("calcflow: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsNotFound сообщает, что запрошенный ресурс не найден.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsConflict сообщает, что запрос конфликтует с состоянием ресурса: идентификатор
+// запроса уже использован или вычисление уже завершено.
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+// IsRateLimited сообщает, что превышен предел частоты запросов, суточная квота
+// клиента или ограничение арендатора. Время до повтора указано в APIError.RetryAfter.
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// do выполняет запрос к API и декодирует JSON-ответ в out, если он не nil;
+// в out типа *[]byte ответ записывается без разбора.
+// Запросы повторяются при сетевых ошибках и ответах 429, 502, 503 и 504.
+// Запросы POST при сетевых ошибках не повторяются, так как могли быть выполнены.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	attempts := c.retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := c.retry.MinBackoff
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, method, path, payload)
+		var retryAfter time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil || method == http.MethodPost {
+				return err
+			}
+		case isTransient(resp.StatusCode):
+			err = readError(resp)
+			retryAfter = err.(*APIError).RetryAfter
+			if c.retry.MaxRetryAfter > 0 && retryAfter > c.retry.MaxRetryAfter {
+				return err
+			}
+		default:
+			defer resp.Body.Close()
+			if resp.StatusCode >= 400 {
+				return readError(resp)
+			}
+			switch out := out.(type) {
+			case nil:
+				return nil
+			case *[]byte:
+				*out, err = io.ReadAll(resp.Body)
+				return err
+			}
+			return json.NewDecoder(resp.Body).Decode(out)
+		}
+
+		if attempt >= attempts {
+			return err
+		}
+		wait := backoff
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+		backoff *= 2
+		if c.retry.MaxBackoff > 0 && backoff > c.retry.MaxBackoff {
+			backoff = c.retry.MaxBackoff
+		}
+	}
+}
+
+// send отправляет один запрос к API.
+func (c *Client) send(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, path, payload)
+	if err != nil {
+		return nil, err
+	}
+	return c.httpClient.Do(req)
+}
+
+// newRequest создает запрос к API с заголовками клиента.
+func (c *Client) newRequest(ctx context.Context, method, path string, payload []byte) (*http.Request, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.tenant != "" {
+		req.Header.Set("X-Tenant-ID", c.tenant)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// isTransient сообщает, что запрос с таким статусом ответа стоит повторить.
+func isTransient(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// readError читает ошибку из ответа сервера и закрывает тело ответа.
+func readError(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var body struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		message = body.Error
+	}
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    message,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter разбирает заголовок Retry-After в секундах или в виде даты.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// sleep ждет d или отмены контекста.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,390 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"calcflow/backend/internal/agent"
+	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/orchestrator"
+	"calcflow/backend/internal/server"
+	"calcflow/backend/internal/task"
+)
+
+// testRetryPolicy повторяет запросы без долгих пауз между попытками.
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:   3,
+	MinBackoff:    10 * time.Millisecond,
+	MaxBackoff:    50 * time.Millisecond,
+	MaxRetryAfter: 5 * time.Second,
+}
+
+// agentProcessor передает задачи оркестратора агенту.
+type agentProcessor struct {
+	agent *agent.Agent
+}
+
+func (p *agentProcessor) EnqueueTask(t *task.Task) error {
+	return p.agent.EnqueueTask(t)
+}
+
+// testServer - сервер calcflow с базой данных во временном каталоге и одним агентом.
+type testServer struct {
+	url          string
+	adminKey     string
+	orchestrator *orchestrator.Orchestrator
+	server       *server.Server
+}
+
+// newTestServer запускает сервер calcflow в httptest; wrap, если задан,
+// оборачивает его обработчик.
+func newTestServer(t *testing.T, wrap func(http.Handler) http.Handler) *testServer {
+	t.Helper()
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	processor := &agentProcessor{}
+	o, err := orchestrator.NewOrchestrator(db, processor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	processor.agent = agent.NewAgent("test", 10, o)
+	o.ConfigureCapacity(10)
+	o.RegisterAgent(processor.agent)
+	go processor.agent.Start()
+
+	key, err := o.EnsureAdminKey("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := server.NewServer(o)
+	var handler http.Handler = s.Router()
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	return &testServer{url: ts.URL, adminKey: key, orchestrator: o, server: s}
+}
+
+// client возвращает клиент с ключом администратора.
+func (ts *testServer) client(opts ...Option) *Client {
+	opts = append([]Option{WithAPIKey(ts.adminKey), WithRetryPolicy(testRetryPolicy)}, opts...)
+	return New(ts.url, opts...)
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestSubmitWaitGetList(t *testing.T) {
+	ts := newTestServer(t, nil)
+	c := ts.client()
+	ctx := testContext(t)
+
+	created, err := c.Submit(ctx, SubmitRequest{RequestID: "sum-1", Expression: "2 + 3 * 4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || created.RequestID != "sum-1" {
+		t.Fatalf("Submit returned %+v", created)
+	}
+
+	done, err := c.Wait(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := done.Value(); err != nil || value != 14 {
+		t.Fatalf("Wait: value %v, %v; want 14", value, err)
+	}
+
+	got, err := c.Get(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != StatusCompleted || got.Result != done.Result {
+		t.Errorf("Get = %+v, want the completed expression", got)
+	}
+
+	list, err := c.List(ctx, ListOptions{Statuses: []string{StatusCompleted}, RequestIDPrefix: "sum-", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != created.ID || list.NextCursor != "" {
+		t.Errorf("List = %+v, want the single completed expression", list)
+	}
+}
+
+func TestOperationsAndStream(t *testing.T) {
+	ts := newTestServer(t, nil)
+	c := ts.client()
+	ctx := testContext(t)
+
+	updated, err := c.UpdateOperations(ctx, map[string]time.Duration{"summation": 200 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated["summation"] != 200*time.Millisecond {
+		t.Errorf("UpdateOperations: summation = %v, want 200ms", updated["summation"])
+	}
+	operations, err := c.Operations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if operations["summation"] != 200*time.Millisecond {
+		t.Errorf("Operations: summation = %v, want 200ms", operations["summation"])
+	}
+
+	created, err := c.Submit(ctx, SubmitRequest{Expression: "1 + 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := c.Stream(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	first, err := stream.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != StatusPending {
+		t.Errorf("first event status %q, want pending", first.Status)
+	}
+	last, err := stream.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Status != StatusCompleted || last.Result != "2" {
+		t.Errorf("last event = %+v, want completed 2", last)
+	}
+	if _, err := stream.Next(); err != io.EOF {
+		t.Errorf("Next after completion: %v, want io.EOF", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ts := newTestServer(t, nil)
+	c := ts.client()
+	ctx := testContext(t)
+
+	if _, err := c.UpdateOperations(ctx, map[string]time.Duration{"multiplication": 2 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	created, err := c.Submit(ctx, SubmitRequest{Expression: "6 * 7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canceled, err := c.Cancel(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canceled.Status != StatusCanceled || !canceled.Done() {
+		t.Errorf("Cancel = %+v, want canceled", canceled)
+	}
+	if _, err := canceled.Value(); err == nil {
+		t.Error("Value of a canceled expression succeeded")
+	}
+
+	_, err = c.Cancel(ctx, created.ID)
+	if !IsConflict(err) {
+		t.Errorf("second Cancel: %v, want conflict", err)
+	}
+}
+
+func TestErrorDecoding(t *testing.T) {
+	ts := newTestServer(t, nil)
+	c := ts.client()
+	ctx := testContext(t)
+
+	_, err := c.Get(ctx, "missing")
+	if !IsNotFound(err) {
+		t.Errorf("Get missing: %v, want not found", err)
+	}
+
+	_, err = c.Submit(ctx, SubmitRequest{Expression: "2 +"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message == "" {
+		t.Errorf("Submit invalid expression: %v, want 400 with a message", err)
+	}
+	if strings.Contains(apiErr.Message, `{"error"`) {
+		t.Errorf("message %q is not decoded from the JSON body", apiErr.Message)
+	}
+
+	// Повтор запроса возвращает ту же задачу, другое выражение с тем же
+	// идентификатором запроса - конфликт
+	first, err := c.Submit(ctx, SubmitRequest{RequestID: "dup", Expression: "1 + 2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := c.Submit(ctx, SubmitRequest{RequestID: "dup", Expression: "1 + 2"})
+	if err != nil || again.ID != first.ID {
+		t.Errorf("repeated Submit: %+v, %v; want task %s", again, err, first.ID)
+	}
+	_, err = c.Submit(ctx, SubmitRequest{RequestID: "dup", Expression: "2 + 2"})
+	if !IsConflict(err) {
+		t.Errorf("reused request ID: %v, want conflict", err)
+	}
+
+	anonymous := New(ts.url, WithRetryPolicy(testRetryPolicy))
+	_, err = anonymous.Get(ctx, "missing")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Get without credentials: %v, want 401", err)
+	}
+}
+
+func TestRetryServiceUnavailable(t *testing.T) {
+	var attempts atomic.Int32
+	ts := newTestServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Первая попытка добавить выражение получает ответ перегруженного сервера
+			if r.Method == http.MethodPost && attempts.Add(1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, `{"error": "queue is full"}`, http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	c := ts.client()
+	ctx := testContext(t)
+
+	started := time.Now()
+	created, err := c.Submit(ctx, SubmitRequest{Expression: "3 + 4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" {
+		t.Fatal("Submit returned no task")
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("attempts = %d, want 2", got)
+	}
+	if elapsed := time.Since(started); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least Retry-After of 1s", elapsed)
+	}
+}
+
+func TestRetryRateLimited(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.server.ConfigureRateLimit(server.RateLimit{Rate: 1, Burst: 1})
+	c := ts.client()
+	ctx := testContext(t)
+
+	if _, err := c.Usage(ctx); err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	usage, err := c.Usage(ctx)
+	if err != nil {
+		t.Fatalf("second request was not retried: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < 900*time.Millisecond {
+		t.Errorf("retried after %v, want to wait for Retry-After", elapsed)
+	}
+	if usage.RateLimit.Burst != 1 || usage.Client == "" {
+		t.Errorf("Usage = %+v", usage)
+	}
+
+	// Без повторных попыток ошибка 429 возвращается с временем до повтора
+	single := ts.client(WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	_, err = single.Usage(ctx)
+	var apiErr *APIError
+	if !IsRateLimited(err) || !errors.As(err, &apiErr) || apiErr.RetryAfter <= 0 {
+		t.Errorf("Usage without retries: %v, want 429 with Retry-After", err)
+	}
+}
+
+func TestRetryAfterBeyondLimit(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ts.orchestrator.ConfigureQuota(time.Millisecond)
+	c := ts.client()
+	ctx := testContext(t)
+
+	if _, err := c.UpdateOperations(ctx, map[string]time.Duration{"summation": time.Second}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Квота восстановится только в начале следующих суток: ошибка возвращается сразу
+	started := time.Now()
+	_, err := c.Submit(ctx, SubmitRequest{Expression: "1 + 2"})
+	var apiErr *APIError
+	if !IsRateLimited(err) || !errors.As(err, &apiErr) || apiErr.RetryAfter <= testRetryPolicy.MaxRetryAfter {
+		t.Fatalf("Submit over quota: %v, want 429 with a long Retry-After", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Submit waited %v, want an immediate error", elapsed)
+	}
+}
+
+func TestUsersAndTrace(t *testing.T) {
+	ts := newTestServer(t, nil)
+	ctx := testContext(t)
+
+	anonymous := New(ts.url, WithRetryPolicy(testRetryPolicy))
+	user, err := anonymous.Register(ctx, "alice", "wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := anonymous.Login(ctx, "alice", "wonderland")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Token == "" || token.User == nil || token.User.ID != user.ID {
+		t.Fatalf("Login = %+v", token)
+	}
+	if _, err := anonymous.Login(ctx, "alice", "wrong password"); err == nil {
+		t.Error("Login with a wrong password succeeded")
+	}
+
+	alice := New(ts.url, WithToken(token.Token), WithRetryPolicy(testRetryPolicy))
+	created, err := alice.Submit(ctx, SubmitRequest{Expression: "(1 + 2) * 3", Explain: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := alice.Wait(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	trace, err := alice.Trace(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trace.Result != "9" || len(trace.Steps) != 2 {
+		t.Errorf("Trace = %+v, want result 9 in 2 steps", trace)
+	}
+	text, err := alice.TraceText(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "9") {
+		t.Errorf("TraceText = %q", text)
+	}
+
+	// Выражения пользователя не видны другим пользователям
+	if _, err := anonymous.Register(ctx, "bob", "builder1"); err != nil {
+		t.Fatal(err)
+	}
+	bobToken, err := anonymous.Login(ctx, "bob", "builder1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob := New(ts.url, WithToken(bobToken.Token), WithRetryPolicy(testRetryPolicy))
+	if _, err := bob.Get(ctx, created.ID); !IsNotFound(err) {
+		t.Errorf("bob Get alice's expression: %v, want not found", err)
+	}
+}
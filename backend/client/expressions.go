@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Статусы вычисления выражения.
+const (
+	StatusPending   = "pending"
+	StatusCompleted = "completed"
+	StatusError     = "error"
+	StatusCanceled  = "canceled"
+)
+
+// Expression представляет выражение и состояние его вычисления.
+type Expression struct {
+	ID         string        `json:"id"`
+	RequestID  string        `json:"X-Request-id"`
+	Expression string        `json:"expression"`
+	Expanded   string        `json:"expanded,omitempty"` // Выражение с раскрытыми пользовательскими функциями
+	Status     string        `json:"status"`
+	Result     string        `json:"result"`
+	Created    time.Time     `json:"created"`
+	Finished   time.Time     `json:"finished"`
+	Duration   time.Duration `json:"duration"`
+	Explain    bool          `json:"explain,omitempty"`
+	Cached     bool          `json:"cached,omitempty"`
+}
+
+// Done сообщает, завершено ли вычисление: успешно, с ошибкой или отменой.
+func (e *Expression) Done() bool {
+	return e.Status == StatusCompleted || e.Status == StatusError || e.Status == StatusCanceled
+}
+
+// Value возвращает результат вычисления как число.
+func (e *Expression) Value() (float64, error) {
+	if e.Status != StatusCompleted {
+		return 0, errors.New("calcflow: expression is " + e.Status)
+	}
+	return strconv.ParseFloat(e.Result, 64)
+}
+
+// SubmitRequest задает выражение для вычисления.
+type SubmitRequest struct {
+	RequestID  string `json:"request_id,omitempty"` // Необязательный; по умолчанию совпадает с идентификатором задачи
+	Expression string `json:"expression"`
+	Explain    bool   `json:"explain,omitempty"`  // Записывать ли пошаговую трассировку вычисления
+	NoCache    bool   `json:"no_cache,omitempty"` // Вычислить заново, не используя кэш результатов
+}
+
+// ListOptions задает фильтры, сортировку и страницу списка выражений.
+// Нулевые значения полей не ограничивают выборку.
+type ListOptions struct {
+	Statuses        []string
+	CreatedAfter    time.Time
+	CreatedBefore   time.Time
+	RequestIDPrefix string
+	HasResult       *bool
+	SortBy          string // created, finished или duration
+	Descending      bool
+	Limit           int
+	Cursor          string // Курсор из ExpressionList.NextCursor предыдущей страницы
+}
+
+// ExpressionList представляет страницу списка выражений.
+type ExpressionList struct {
+	Items      []*Expression `json:"items"`
+	NextCursor string        `json:"next_cursor,omitempty"` // Пустой на последней странице
+}
+
+// Submit добавляет выражение для вычисления и возвращает созданную задачу.
+func (c *Client) Submit(ctx context.Context, request SubmitRequest) (*Expression, error) {
+	var created Expression
+	if err := c.do(ctx, http.MethodPost, apiPrefix+"/expressions", request, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// Get возвращает выражение по идентификатору задачи.
+func (c *Client) Get(ctx context.Context, id string) (*Expression, error) {
+	var e Expression
+	if err := c.do(ctx, http.MethodGet, apiPrefix+"/expressions/"+url.PathEscape(id), nil, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// List возвращает страницу списка выражений.
+func (c *Client) List(ctx context.Context, opts ListOptions) (*ExpressionList, error) {
+	query := url.Values{}
+	if len(opts.Statuses) > 0 {
+		query.Set("status", strings.Join(opts.Statuses, ","))
+	}
+	if !opts.CreatedAfter.IsZero() {
+		query.Set("created_after", opts.CreatedAfter.Format(time.RFC3339Nano))
+	}
+	if !opts.CreatedBefore.IsZero() {
+		query.Set("created_before", opts.CreatedBefore.Format(time.RFC3339Nano))
+	}
+	if opts.RequestIDPrefix != "" {
+		query.Set("request_id_prefix", opts.RequestIDPrefix)
+	}
+	if opts.HasResult != nil {
+		query.Set("has_result", strconv.FormatBool(*opts.HasResult))
+	}
+	if opts.SortBy != "" {
+		query.Set("sort", opts.SortBy)
+	}
+	if opts.Descending {
+		query.Set("order", "desc")
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Cursor != "" {
+		query.Set("cursor", opts.Cursor)
+	}
+
+	path := apiPrefix + "/expressions"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var list ExpressionList
+	if err := c.do(ctx, http.MethodGet, path, nil, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// Cancel отменяет вычисление выражения. Если вычисление уже завершено,
+// возвращается ошибка, для которой IsConflict возвращает true.
+func (c *Client) Cancel(ctx context.Context, id string) (*Expression, error) {
+	var e Expression
+	if err := c.do(ctx, http.MethodDelete, apiPrefix+"/expressions/"+url.PathEscape(id), nil, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Wait ожидает завершения вычисления выражения по потоку его изменений
+// и возвращает итоговое состояние. При обрыве потока подключается заново;
+// ожидание ограничивается контекстом.
+func (c *Client) Wait(ctx context.Context, id string) (*Expression, error) {
+	failures := 0
+	backoff := c.retry.MinBackoff
+	for {
+		e, err := c.waitStream(ctx, id)
+		if err == nil {
+			return e, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && !isTransient(apiErr.StatusCode) {
+			return nil, err
+		}
+
+		failures++
+		if failures >= c.retry.MaxAttempts {
+			return nil, err
+		}
+		if err := sleep(ctx, backoff); err != nil {
+			return nil, err
+		}
+		backoff *= 2
+		if c.retry.MaxBackoff > 0 && backoff > c.retry.MaxBackoff {
+			backoff = c.retry.MaxBackoff
+		}
+	}
+}
+
+// waitStream читает поток изменений выражения до его завершения.
+func (c *Client) waitStream(ctx context.Context, id string) (*Expression, error) {
+	stream, err := c.Stream(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	for {
+		e, err := stream.Next()
+		if err != nil {
+			return nil, err
+		}
+		if e.Done() {
+			return e, nil
+		}
+	}
+}
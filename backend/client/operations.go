@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Operations возвращает время выполнения каждой операции по ее названию.
+func (c *Client) Operations(ctx context.Context) (map[string]time.Duration, error) {
+	var operations map[string]string
+	if err := c.do(ctx, http.MethodGet, apiPrefix+"/operations", nil, &operations); err != nil {
+		return nil, err
+	}
+	return parseOperations(operations)
+}
+
+// UpdateOperations задает время выполнения операций, например {"summation": time.Second},
+// и возвращает время выполнения всех операций после обновления.
+func (c *Client) UpdateOperations(ctx context.Context, timings map[string]time.Duration) (map[string]time.Duration, error) {
+	request := make(map[string]string, len(timings))
+	for name, duration := range timings {
+		request[name] = duration.String()
+	}
+
+	var operations map[string]string
+	if err := c.do(ctx, http.MethodPut, apiPrefix+"/operations", request, &operations); err != nil {
+		return nil, err
+	}
+	return parseOperations(operations)
+}
+
+// parseOperations разбирает длительности из ответа сервера.
+func parseOperations(operations map[string]string) (map[string]time.Duration, error) {
+	timings := make(map[string]time.Duration, len(operations))
+	for name, value := range operations {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		timings[name] = duration
+	}
+	return timings, nil
+}
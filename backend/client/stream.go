@@ -0,0 +1,86 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Stream представляет поток изменений выражения.
+type Stream struct {
+	resp   *http.Response
+	reader *bufio.Reader
+}
+
+// Stream открывает поток изменений выражения. Первым событием приходит
+// текущее состояние задачи, последним - завершенное; после него Next возвращает io.EOF.
+// Поток нужно закрыть вызовом Close.
+func (c *Client) Stream(ctx context.Context, id string) (*Stream, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, apiPrefix+"/expressions/"+url.PathEscape(id)+"/events", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	return &Stream{resp: resp, reader: bufio.NewReader(resp.Body)}, nil
+}
+
+// Next возвращает следующее состояние выражения. Если поток закончился
+// до завершения вычисления, возвращается io.ErrUnexpectedEOF.
+func (s *Stream) Next() (*Expression, error) {
+	var event string
+	var data strings.Builder
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" && event == "" && data.Len() == 0 {
+				return nil, io.EOF
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "":
+			// Пустая строка завершает событие
+			if data.Len() == 0 || (event != "" && event != "task") {
+				event = ""
+				data.Reset()
+				continue
+			}
+			var e Expression
+			if err := json.Unmarshal([]byte(data.String()), &e); err != nil {
+				return nil, err
+			}
+			return &e, nil
+		case strings.HasPrefix(line, ":"):
+			// Комментарий, поддерживающий соединение
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+}
+
+// Close закрывает поток.
+func (s *Stream) Close() error {
+	return s.resp.Body.Close()
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Trace представляет пошаговую трассировку вычисления выражения.
+type Trace struct {
+	TaskID     string      `json:"task_id"`
+	Expression string      `json:"expression"`
+	Expanded   string      `json:"expanded,omitempty"`
+	Status     string      `json:"status"`
+	Result     string      `json:"result"`
+	Steps      []TraceStep `json:"steps"`
+}
+
+// TraceStep представляет одну операцию трассировки.
+type TraceStep struct {
+	Step       int       `json:"step"`             // Порядковый номер операции, начиная с 1
+	Parent     int       `json:"parent,omitempty"` // Номер операции, использующей результат этой; 0 для корня
+	Operation  string    `json:"operation"`
+	Expression string    `json:"expression"` // Подвыражение, вычисленное операцией
+	Operands   []float64 `json:"operands"`
+	Result     float64   `json:"result"`
+	Agent      string    `json:"agent"`
+	Shared     bool      `json:"shared,omitempty"` // Результат взят у другой задачи, вычислявшей ту же операцию
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+}
+
+// Trace возвращает трассировку вычисления выражения, добавленного с Explain.
+func (c *Client) Trace(ctx context.Context, id string) (*Trace, error) {
+	var trace Trace
+	if err := c.do(ctx, http.MethodGet, tracePath(id), nil, &trace); err != nil {
+		return nil, err
+	}
+	return &trace, nil
+}
+
+// TraceText возвращает трассировку в виде текстового дерева операций.
+func (c *Client) TraceText(ctx context.Context, id string) (string, error) {
+	var text []byte
+	if err := c.do(ctx, http.MethodGet, tracePath(id)+"?format=text", nil, &text); err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
+
+func tracePath(id string) string {
+	return apiPrefix + "/expressions/" + url.PathEscape(id) + "/trace"
+}
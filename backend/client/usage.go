@@ -0,0 +1,42 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Usage описывает предел частоты запросов и расход суточной квоты клиента.
+type Usage struct {
+	Client    string    `json:"client"` // Ключ API, пользователь или IP-адрес
+	RateLimit RateLimit `json:"rate_limit"`
+	Quota     Quota     `json:"quota"`
+}
+
+// RateLimit описывает предел частоты запросов клиента.
+type RateLimit struct {
+	Rate      float64 `json:"rate"`      // Запросов в секунду; 0 - без ограничения
+	Burst     int     `json:"burst"`     // Наибольшее число запросов подряд
+	Remaining int     `json:"remaining"` // Запросов, которые можно выполнить сразу
+}
+
+// Quota описывает расход суточной квоты: оценку времени вычисления выражений,
+// добавленных за сутки по UTC.
+type Quota struct {
+	Day         string        `json:"day"`
+	Expressions int           `json:"expressions"`
+	Operations  int           `json:"operations"`
+	Used        time.Duration `json:"used"`
+	Limit       time.Duration `json:"limit"` // 0 - без ограничения
+	Remaining   time.Duration `json:"remaining"`
+	Reset       time.Time     `json:"reset"` // Начало следующих суток
+}
+
+// Usage возвращает предел частоты запросов и расход суточной квоты клиента.
+func (c *Client) Usage(ctx context.Context) (*Usage, error) {
+	var usage Usage
+	if err := c.do(ctx, http.MethodGet, apiPrefix+"/usage", nil, &usage); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// User представляет учетную запись пользователя.
+type User struct {
+	ID       string    `json:"id"`
+	Username string    `json:"username"`
+	Created  time.Time `json:"created"`
+}
+
+// Token представляет токен доступа пользователя. Клиент с токеном
+// создается с параметром WithToken.
+type Token struct {
+	Token   string    `json:"token"`
+	Type    string    `json:"token_type"`
+	Expires time.Time `json:"expires"`
+	User    *User     `json:"user"`
+}
+
+// credentials - тело запросов регистрации и входа.
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Register создает учетную запись пользователя в арендаторе клиента.
+func (c *Client) Register(ctx context.Context, username, password string) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodPost, apiPrefix+"/users", credentials{username, password}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Login проверяет пароль пользователя арендатора клиента и возвращает токен доступа.
+func (c *Client) Login(ctx context.Context, username, password string) (*Token, error) {
+	var token Token
+	if err := c.do(ctx, http.MethodPost, apiPrefix+"/login", credentials{username, password}, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
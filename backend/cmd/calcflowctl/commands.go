@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"calcflow/backend/client"
+)
+
+// runSubmit добавляет выражения: по одному на аргумент либо по одному на строку
+// файла или стандартного ввода. Пустые строки и строки, начинающиеся с #, пропускаются.
+func runSubmit(a *app, args []string) error {
+	flags := newFlags("submit")
+	file := flags.String("f", "", "файл с выражениями, по одному на строку; - для стандартного ввода")
+	wait := flags.Bool("wait", false, "дождаться завершения вычислений")
+	explain := flags.Bool("explain", false, "записать трассировку вычисления")
+	noCache := flags.Bool("no-cache", false, "вычислить заново, не используя кэш результатов")
+	requestID := flags.String("request-id", "", "идентификатор запроса; для нескольких выражений добавляется суффикс -N")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	expressions := flags.Args()
+	if *file != "" && len(expressions) > 0 {
+		return &usageError{"expressions are given both as arguments and with -f"}
+	}
+	if len(expressions) == 0 {
+		var in io.Reader = a.stdin
+		if *file != "" && *file != "-" {
+			f, err := os.Open(*file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		var err error
+		expressions, err = readExpressions(in)
+		if err != nil {
+			return err
+		}
+	}
+	if len(expressions) == 0 {
+		return &usageError{"no expressions to submit"}
+	}
+
+	submitted := make([]*client.Expression, 0, len(expressions))
+	for i, expression := range expressions {
+		request := client.SubmitRequest{
+			Expression: expression,
+			Explain:    *explain,
+			NoCache:    *noCache,
+			RequestID:  *requestID,
+		}
+		if *requestID != "" && len(expressions) > 1 {
+			request.RequestID = fmt.Sprintf("%s-%d", *requestID, i+1)
+		}
+		e, err := a.client.Submit(a.ctx, request)
+		if err != nil {
+			return fmt.Errorf("%q: %w", expression, err)
+		}
+		submitted = append(submitted, e)
+	}
+
+	if *wait {
+		var err error
+		submitted, err = waitAll(a, submitted)
+		if err != nil {
+			return err
+		}
+	}
+	return a.printExpressions(submitted)
+}
+
+// readExpressions читает выражения по одному на строку.
+func readExpressions(in io.Reader) ([]string, error) {
+	var expressions []string
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		expressions = append(expressions, line)
+	}
+	return expressions, scanner.Err()
+}
+
+// waitAll одновременно ожидает завершения вычисления выражений.
+func waitAll(a *app, expressions []*client.Expression) ([]*client.Expression, error) {
+	done := make([]*client.Expression, len(expressions))
+	errs := make([]error, len(expressions))
+	var wg sync.WaitGroup
+	for i, e := range expressions {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			done[i], errs[i] = a.client.Wait(a.ctx, id)
+		}(i, e.ID)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", expressions[i].ID, err)
+		}
+	}
+	return done, nil
+}
+
+func runGet(a *app, args []string) error {
+	if len(args) == 0 {
+		return &usageError{"expression ID is required"}
+	}
+	expressions := make([]*client.Expression, 0, len(args))
+	for _, id := range args {
+		e, err := a.client.Get(a.ctx, id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		expressions = append(expressions, e)
+	}
+	return a.printExpressions(expressions)
+}
+
+func runList(a *app, args []string) error {
+	flags := newFlags("list")
+	status := flags.String("status", "", "статусы через запятую: pending, completed, error, canceled")
+	createdAfter := flags.String("created-after", "", "созданные после момента: RFC 3339 или длительность назад, например 1h")
+	createdBefore := flags.String("created-before", "", "созданные до момента: RFC 3339 или длительность назад")
+	prefix := flags.String("prefix", "", "префикс идентификатора запроса")
+	hasResult := flags.String("has-result", "", "только с результатом (true) или без него (false)")
+	sortBy := flags.String("sort", "", "поле сортировки: created, finished или duration")
+	desc := flags.Bool("desc", false, "сортировать по убыванию")
+	limit := flags.Int("limit", 0, "размер страницы")
+	cursor := flags.String("cursor", "", "курсор страницы")
+	all := flags.Bool("all", false, "получить все страницы")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return &usageError{"unexpected arguments"}
+	}
+
+	opts := client.ListOptions{
+		RequestIDPrefix: *prefix,
+		SortBy:          *sortBy,
+		Descending:      *desc,
+		Limit:           *limit,
+		Cursor:          *cursor,
+	}
+	if *status != "" {
+		opts.Statuses = strings.Split(*status, ",")
+	}
+	if *createdAfter != "" {
+		t, err := parseTime(*createdAfter)
+		if err != nil {
+			return &usageError{"invalid -created-after: " + err.Error()}
+		}
+		opts.CreatedAfter = t
+	}
+	if *createdBefore != "" {
+		t, err := parseTime(*createdBefore)
+		if err != nil {
+			return &usageError{"invalid -created-before: " + err.Error()}
+		}
+		opts.CreatedBefore = t
+	}
+	if *hasResult != "" {
+		value, err := strconv.ParseBool(*hasResult)
+		if err != nil {
+			return &usageError{"invalid -has-result: " + err.Error()}
+		}
+		opts.HasResult = &value
+	}
+
+	var expressions []*client.Expression
+	var next string
+	for {
+		page, err := a.client.List(a.ctx, opts)
+		if err != nil {
+			return err
+		}
+		expressions = append(expressions, page.Items...)
+		next = page.NextCursor
+		if !*all || next == "" {
+			break
+		}
+		opts.Cursor = next
+	}
+	if err := a.printExpressions(expressions); err != nil {
+		return err
+	}
+
+	// Курсор следующей страницы выводится отдельно, чтобы не смешивать его с данными
+	if next != "" {
+		fmt.Fprintf(os.Stderr, "next page: -cursor %s\n", next)
+	}
+	return nil
+}
+
+// runWatch выводит каждое изменение выражений до завершения их вычисления.
+func runWatch(a *app, args []string) error {
+	if len(args) == 0 {
+		return &usageError{"expression ID is required"}
+	}
+
+	updates := make(chan *client.Expression)
+	errs := make(chan error, len(args))
+	var wg sync.WaitGroup
+	for _, id := range args {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			errs <- watch(a, id, updates)
+		}(id)
+	}
+	go func() {
+		wg.Wait()
+		close(updates)
+	}()
+
+	printer := a.newExpressionPrinter()
+	for e := range updates {
+		if err := printer.print(e); err != nil {
+			return err
+		}
+	}
+
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watch передает в updates изменения выражения до завершения его вычисления.
+func watch(a *app, id string, updates chan<- *client.Expression) error {
+	stream, err := a.client.Stream(a.ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", id, err)
+	}
+	defer stream.Close()
+
+	for {
+		e, err := stream.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// Поток оборвался: дожидаемся результата с повторными подключениями
+			e, err = a.client.Wait(a.ctx, id)
+			if err != nil {
+				return fmt.Errorf("%s: %w", id, err)
+			}
+			updates <- e
+			return nil
+		}
+		updates <- e
+	}
+}
+
+func runTrace(a *app, args []string) error {
+	flags := newFlags("trace")
+	text := flags.Bool("text", false, "вывести трассировку в виде дерева операций")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return &usageError{"exactly one expression ID is required"}
+	}
+	id := flags.Arg(0)
+
+	if *text {
+		tree, err := a.client.TraceText(a.ctx, id)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(a.out, tree)
+		return err
+	}
+
+	trace, err := a.client.Trace(a.ctx, id)
+	if err != nil {
+		return err
+	}
+	return a.printTrace(trace)
+}
+
+func runCancel(a *app, args []string) error {
+	if len(args) == 0 {
+		return &usageError{"expression ID is required"}
+	}
+	expressions := make([]*client.Expression, 0, len(args))
+	for _, id := range args {
+		e, err := a.client.Cancel(a.ctx, id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		expressions = append(expressions, e)
+	}
+	return a.printExpressions(expressions)
+}
+
+// runOps показывает время выполнения операций или задает его аргументами вида операция=длительность.
+func runOps(a *app, args []string) error {
+	if len(args) == 0 {
+		operations, err := a.client.Operations(a.ctx)
+		if err != nil {
+			return err
+		}
+		return a.printOperations(operations)
+	}
+
+	timings := make(map[string]time.Duration, len(args))
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return &usageError{fmt.Sprintf("invalid timing %q, expected operation=duration", arg)}
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return &usageError{fmt.Sprintf("invalid duration for %s: %v", name, err)}
+		}
+		timings[name] = duration
+	}
+
+	operations, err := a.client.UpdateOperations(a.ctx, timings)
+	if err != nil {
+		return err
+	}
+	return a.printOperations(operations)
+}
+
+// runRegister создает учетную запись пользователя. Пароль читается из
+// переменной окружения CALCFLOW_PASSWORD или первой строки стандартного ввода.
+func runRegister(a *app, args []string) error {
+	if len(args) != 1 {
+		return &usageError{"username is required"}
+	}
+	password, err := readPassword(a)
+	if err != nil {
+		return err
+	}
+	user, err := a.client.Register(a.ctx, args[0], password)
+	if err != nil {
+		return err
+	}
+	if a.format == formatJSON {
+		return a.printJSON(user)
+	}
+	return a.printRows([]string{"ID", "USERNAME", "CREATED"},
+		[][]string{{user.ID, user.Username, user.Created.Format(time.RFC3339)}})
+}
+
+// runLogin получает токен доступа пользователя. В формате table выводится
+// только токен, чтобы его можно было сохранить в CALCFLOW_TOKEN.
+func runLogin(a *app, args []string) error {
+	if len(args) != 1 {
+		return &usageError{"username is required"}
+	}
+	password, err := readPassword(a)
+	if err != nil {
+		return err
+	}
+	token, err := a.client.Login(a.ctx, args[0], password)
+	if err != nil {
+		return err
+	}
+	switch a.format {
+	case formatJSON:
+		return a.printJSON(token)
+	case formatCSV:
+		return a.printRows([]string{"TOKEN", "EXPIRES"}, [][]string{{token.Token, token.Expires.Format(time.RFC3339)}})
+	}
+	_, err = fmt.Fprintln(a.out, token.Token)
+	return err
+}
+
+// readPassword возвращает пароль из CALCFLOW_PASSWORD или первой строки стандартного ввода.
+func readPassword(a *app) (string, error) {
+	if password := os.Getenv("CALCFLOW_PASSWORD"); password != "" {
+		return password, nil
+	}
+	line, err := bufio.NewReader(a.stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", &usageError{"password is required: set CALCFLOW_PASSWORD or pass it on standard input"}
+	}
+	return password, nil
+}
+
+// runUsage показывает предел частоты запросов и расход суточной квоты клиента.
+func runUsage(a *app, args []string) error {
+	if len(args) > 0 {
+		return &usageError{"unexpected arguments"}
+	}
+	usage, err := a.client.Usage(a.ctx)
+	if err != nil {
+		return err
+	}
+	if a.format == formatJSON {
+		return a.printJSON(usage)
+	}
+	limit := "unlimited"
+	if usage.Quota.Limit > 0 {
+		limit = usage.Quota.Limit.String()
+	}
+	return a.printRows(
+		[]string{"CLIENT", "RATE", "BURST", "REMAINING", "DAY", "EXPRESSIONS", "OPERATIONS", "USED", "LIMIT", "RESET"},
+		[][]string{{
+			usage.Client,
+			strconv.FormatFloat(usage.RateLimit.Rate, 'g', -1, 64),
+			strconv.Itoa(usage.RateLimit.Burst),
+			strconv.Itoa(usage.RateLimit.Remaining),
+			usage.Quota.Day,
+			strconv.Itoa(usage.Quota.Expressions),
+			strconv.Itoa(usage.Quota.Operations),
+			usage.Quota.Used.String(),
+			limit,
+			usage.Quota.Reset.Format(time.RFC3339),
+		}})
+}
@@ -0,0 +1,176 @@
+// Команда calcflowctl - клиент командной строки для сервера calcflow.
+//
+// Использование:
+//
+//	calcflowctl [флаги] <команда> [флаги команды] [аргументы]
+//
+// Адрес сервера, арендатор и учетные данные задаются флагами -server, -tenant,
+// -api-key и -token или переменными окружения CALCFLOW_SERVER, CALCFLOW_TENANT,
+// CALCFLOW_API_KEY и CALCFLOW_TOKEN.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"calcflow/backend/client"
+)
+
+// command описывает подкоманду.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(app *app, args []string) error
+}
+
+var commands = []*command{
+	{"submit", "submit [-f файл] [-wait] [-explain] [-no-cache] [-request-id ID] [выражение...]",
+		"добавить выражения из аргументов, файла или стандартного ввода", runSubmit},
+	{"get", "get ID...", "показать выражения", runGet},
+	{"list", "list [-status s1,s2] [-created-after t] [-created-before t] [-prefix p] [-has-result bool] [-sort поле] [-desc] [-limit n] [-cursor c] [-all]",
+		"показать список выражений", runList},
+	{"watch", "watch ID...", "следить за вычислением выражений до завершения", runWatch},
+	{"trace", "trace [-text] ID", "показать трассировку вычисления", runTrace},
+	{"cancel", "cancel ID...", "отменить вычисление выражений", runCancel},
+	{"ops", "ops [операция=длительность...]", "показать или задать время выполнения операций", runOps},
+	{"register", "register ИМЯ", "зарегистрировать пользователя; пароль из CALCFLOW_PASSWORD или стандартного ввода", runRegister},
+	{"login", "login ИМЯ", "получить токен доступа пользователя", runLogin},
+	{"usage", "usage", "показать предел частоты запросов и расход суточной квоты", runUsage},
+}
+
+// usageError сообщает о неверном использовании команды.
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+// app содержит общие параметры запуска команд.
+type app struct {
+	ctx    context.Context
+	client *client.Client
+	out    io.Writer
+	format string
+	stdin  io.Reader
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	flags := flag.NewFlagSet("calcflowctl", flag.ContinueOnError)
+	server := flags.String("server", envOr("CALCFLOW_SERVER", "http://localhost:8080"), "адрес сервера calcflow")
+	tenant := flags.String("tenant", os.Getenv("CALCFLOW_TENANT"), "арендатор")
+	apiKey := flags.String("api-key", os.Getenv("CALCFLOW_API_KEY"), "ключ API")
+	token := flags.String("token", os.Getenv("CALCFLOW_TOKEN"), "токен доступа пользователя")
+	format := flags.String("o", formatTable, "формат вывода: table, json или csv")
+	timeout := flags.Duration("timeout", 0, "ограничение времени выполнения команды; 0 - без ограничения")
+	flags.Usage = func() { printUsage(flags) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		printUsage(flags)
+		return 2
+	}
+	switch *format {
+	case formatTable, formatJSON, formatCSV:
+	default:
+		fmt.Fprintf(os.Stderr, "calcflowctl: unknown output format %q\n", *format)
+		return 2
+	}
+
+	var cmd *command
+	for _, c := range commands {
+		if c.name == flags.Arg(0) {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "calcflowctl: unknown command %q\n", flags.Arg(0))
+		printUsage(flags)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	var opts []client.Option
+	if *tenant != "" {
+		opts = append(opts, client.WithTenant(*tenant))
+	}
+	if *apiKey != "" {
+		opts = append(opts, client.WithAPIKey(*apiKey))
+	}
+	if *token != "" {
+		opts = append(opts, client.WithToken(*token))
+	}
+	a := &app{
+		ctx:    ctx,
+		client: client.New(*server, opts...),
+		out:    os.Stdout,
+		format: *format,
+		stdin:  os.Stdin,
+	}
+
+	err := cmd.run(a, flags.Args()[1:])
+	var usage *usageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &usage):
+		fmt.Fprintf(os.Stderr, "calcflowctl %s: %v\nusage: calcflowctl %s\n", cmd.name, err, cmd.usage)
+		return 2
+	case errors.Is(err, flag.ErrHelp):
+		return 2
+	default:
+		fmt.Fprintf(os.Stderr, "calcflowctl %s: %v\n", cmd.name, err)
+		return 1
+	}
+}
+
+func printUsage(flags *flag.FlagSet) {
+	w := flags.Output()
+	fmt.Fprintln(w, "usage: calcflowctl [флаги] <команда> [флаги команды] [аргументы]")
+	fmt.Fprintln(w, "\nкоманды:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w, "\nфлаги:")
+	flags.PrintDefaults()
+}
+
+// newFlags создает набор флагов подкоманды.
+func newFlags(cmd string) *flag.FlagSet {
+	return flag.NewFlagSet("calcflowctl "+cmd, flag.ContinueOnError)
+}
+
+// parseTime разбирает время в формате RFC 3339 или длительность назад от текущего момента, например "1h".
+func parseTime(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
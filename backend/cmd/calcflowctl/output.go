@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"calcflow/backend/client"
+)
+
+// Форматы вывода.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+var expressionColumns = []string{"ID", "REQUEST ID", "EXPRESSION", "STATUS", "RESULT", "CREATED", "DURATION", "CACHED"}
+
+// watchWidths - минимальная ширина столбцов, значения которых меняются
+// по ходу вычисления: статуса, результата и длительности.
+var watchWidths = []int{0, 0, 0, len("completed"), 12, 0, 14, 0}
+
+func expressionRow(e *client.Expression) []string {
+	duration := ""
+	if e.Done() {
+		duration = e.Duration.String()
+	}
+	return []string{
+		e.ID,
+		e.RequestID,
+		e.Expression,
+		e.Status,
+		e.Result,
+		e.Created.Format(time.RFC3339),
+		duration,
+		strconv.FormatBool(e.Cached),
+	}
+}
+
+// printExpressions выводит выражения в выбранном формате.
+func (a *app) printExpressions(expressions []*client.Expression) error {
+	if a.format == formatJSON {
+		if expressions == nil {
+			expressions = []*client.Expression{}
+		}
+		return a.printJSON(expressions)
+	}
+	rows := make([][]string, 0, len(expressions))
+	for _, e := range expressions {
+		rows = append(rows, expressionRow(e))
+	}
+	return a.printRows(expressionColumns, rows)
+}
+
+// expressionPrinter выводит изменения выражений по мере их поступления:
+// в формате JSON - по одному объекту на строку.
+type expressionPrinter struct {
+	app    *app
+	csv    *csv.Writer
+	widths []int // Ширина столбцов таблицы, определяемая по заголовку и первой строке
+}
+
+func (a *app) newExpressionPrinter() *expressionPrinter {
+	p := &expressionPrinter{app: a}
+	if a.format == formatCSV {
+		p.csv = csv.NewWriter(a.out)
+	}
+	return p
+}
+
+func (p *expressionPrinter) print(e *client.Expression) error {
+	row := expressionRow(e)
+	switch p.app.format {
+	case formatJSON:
+		return json.NewEncoder(p.app.out).Encode(e)
+	case formatCSV:
+		if p.widths == nil {
+			p.widths = []int{}
+			p.csv.Write(expressionColumns)
+		}
+		p.csv.Write(row)
+		p.csv.Flush()
+		return p.csv.Error()
+	}
+
+	// Строки выводятся сразу, поэтому ширина столбцов фиксируется по первой строке
+	if p.widths == nil {
+		p.widths = make([]int, len(expressionColumns))
+		for i, column := range expressionColumns {
+			p.widths[i] = max(len(column), utf8.RuneCountInString(row[i]), watchWidths[i])
+		}
+		if _, err := fmt.Fprintln(p.app.out, padRow(expressionColumns, p.widths)); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(p.app.out, padRow(row, p.widths))
+	return err
+}
+
+// padRow дополняет ячейки пробелами до ширины столбцов.
+func padRow(row []string, widths []int) string {
+	var b strings.Builder
+	for i, cell := range row {
+		if i == len(row)-1 {
+			b.WriteString(cell)
+			break
+		}
+		b.WriteString(cell)
+		b.WriteString(strings.Repeat(" ", max(widths[i]-utf8.RuneCountInString(cell), 0)+2))
+	}
+	return b.String()
+}
+
+// printTrace выводит операции трассировки.
+func (a *app) printTrace(trace *client.Trace) error {
+	if a.format == formatJSON {
+		return a.printJSON(trace)
+	}
+	columns := []string{"STEP", "PARENT", "OPERATION", "EXPRESSION", "OPERANDS", "RESULT", "AGENT", "SHARED", "DURATION"}
+	rows := make([][]string, 0, len(trace.Steps))
+	for _, step := range trace.Steps {
+		operands := make([]string, len(step.Operands))
+		for i, operand := range step.Operands {
+			operands[i] = strconv.FormatFloat(operand, 'g', -1, 64)
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(step.Step),
+			strconv.Itoa(step.Parent),
+			step.Operation,
+			step.Expression,
+			strings.Join(operands, " "),
+			strconv.FormatFloat(step.Result, 'g', -1, 64),
+			step.Agent,
+			strconv.FormatBool(step.Shared),
+			step.Finished.Sub(step.Started).String(),
+		})
+	}
+	return a.printRows(columns, rows)
+}
+
+// printOperations выводит время выполнения операций, упорядоченных по названию.
+func (a *app) printOperations(operations map[string]time.Duration) error {
+	if a.format == formatJSON {
+		timings := make(map[string]string, len(operations))
+		for name, duration := range operations {
+			timings[name] = duration.String()
+		}
+		return a.printJSON(timings)
+	}
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := make([][]string, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, []string{name, operations[name].String()})
+	}
+	return a.printRows([]string{"OPERATION", "DURATION"}, rows)
+}
+
+// printRows выводит таблицу в формате table или csv.
+func (a *app) printRows(columns []string, rows [][]string) error {
+	if a.format == formatCSV {
+		w := csv.NewWriter(a.out)
+		w.Write(columns)
+		w.WriteAll(rows)
+		return w.Error()
+	}
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+func (a *app) printJSON(v interface{}) error {
+	encoder := json.NewEncoder(a.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -2,24 +2,34 @@ package main
 
 import (
 	"calcflow/backend/internal/agent"
+	"calcflow/backend/internal/config"
 	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/logging"
 	"calcflow/backend/internal/orchestrator"
 	"calcflow/backend/internal/server"
 	"calcflow/backend/internal/task"
+	"calcflow/backend/internal/tracing"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
 	"net/http"
-
-	"github.com/gorilla/mux"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 // Реализация интерфейса TaskProcessor
 type MyProcessor struct {
 	// здесь могут быть поля, необходимые для обработки результатов задач
+	agent *agent.Agent // Агент, в очередь которого отправляются задачи
 }
 
-func (p *MyProcessor) EnqueueTask(task *task.Task) {
+func (p *MyProcessor) EnqueueTask(task *task.Task) error {
 	// Здесь можно выполнить необходимые действия перед добавлением задачи в очередь агента
+	return p.agent.EnqueueTask(task)
 }
 
 func (p *MyProcessor) ReceiveResult(task *task.Task) error {
@@ -32,11 +42,101 @@ func NewMyProcessor() *MyProcessor {
 	return &MyProcessor{}
 }
 
+// fatal записывает в журнал ошибку запуска сервера и завершает процесс.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// reportAdminKey сообщает оператору созданный ключ администратора: записывает его
+// в новый файл path, доступный только владельцу, или, если файл не задан либо
+// его не удалось создать, один раз выводит в стандартный поток ошибок. В журнал
+// значение ключа не попадает.
+func reportAdminKey(key, path string) {
+	if path != "" {
+		err := writeSecret(path, key)
+		if err == nil {
+			slog.Warn("created admin API key", "file", path)
+			return
+		}
+		slog.Error("can't write admin API key file", "file", path, "error", err)
+	}
+	fmt.Fprintf(os.Stderr, "Created admin API key, it is shown only once: %s\n", key)
+	slog.Warn("created admin API key, printed to stderr")
+}
+
+// writeSecret записывает secret в новый файл с правами 0600. Существующий файл
+// не перезаписывается: его права могли быть шире.
+func writeSecret(path, secret string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(secret + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// configure применяет настройки, которые можно изменить без перезапуска. prev -
+// действующая конфигурация или nil при запуске; предел частоты запросов и время
+// выполнения операций применяются, только если они изменились, поскольку это
+// сбрасывает корзины клиентов и заменяет время, заданное через API.
+func configure(prev, cfg *config.Config, o *orchestrator.Orchestrator, s *server.Server, a *agent.Agent) error {
+	logging.SetLevel(cfg.Log.Level)
+	a.ConfigureRetry(agent.RetryPolicy{Attempts: cfg.Agent.RetryAttempts, Delay: cfg.Agent.RetryDelay})
+	// Оркестратор отправляет агенту не больше задач, чем помещается в его очередь
+	o.ConfigureCapacity(min(cfg.Agent.WorkerCount(), cap(a.WorkQueue)))
+	o.ConfigureBacklog(cfg.Orchestrator.MaxBacklog)
+	o.ConfigureQuota(cfg.Orchestrator.DailyQuota)
+	o.ConfigureEvaluate(cfg.Orchestrator.EvaluateThreshold)
+	o.ConfigureCache(orchestrator.CacheConfig{TTL: cfg.Orchestrator.CacheTTL, Size: cfg.Orchestrator.CacheSize})
+
+	if prev == nil || prev.Server.RateLimit != cfg.Server.RateLimit || prev.Server.RateBurst != cfg.Server.RateBurst {
+		s.ConfigureRateLimit(server.RateLimit{Rate: cfg.Server.RateLimit, Burst: cfg.Server.RateBurst})
+	}
+	if len(cfg.Operations) > 0 && (prev == nil || !maps.Equal(prev.Operations, cfg.Operations)) {
+		if err := o.UpdateCalculateTime(task.DefaultTenant, cfg.Operations); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func main() {
+	// Настройки берутся из файла конфигурации (-config или CALCFLOW_CONFIG),
+	// переменных окружения CALCFLOW_* и флагов командной строки
+	loader, err := config.NewLoader(os.Args[0], os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		os.Exit(2) // Ошибку уже вывел разбор флагов
+	}
+	cfg, err := loader.Load()
+	if err != nil {
+		fatal("invalid config", err)
+	}
+
+	if err := logging.Setup(os.Stderr, cfg.Log.Format, cfg.Log.Level); err != nil {
+		fatal("invalid log format", err)
+	}
+
+	// Участки распределенной трассировки записываются строками JSON в файл
+	// (stdout - в стандартный вывод) и/или отправляются приемнику OTLP
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		File:         cfg.Trace.File,
+		OTLPEndpoint: cfg.Trace.OTLPEndpoint,
+	})
+	if err != nil {
+		fatal("can't set up tracing", err)
+	}
+
 	// Инициализация базы данных
-	db, err := database.New("database.db")
+	db, err := database.New(cfg.Database.Path)
 	if err != nil {
-		log.Fatalf("Ошибка при подключении к базе данных: %v", err)
+		fatal("can't open database", err)
 	}
 
 	// Создание необходимых таблиц
@@ -48,27 +148,76 @@ func main() {
 	// Создание оркестратора
 	orchestrator, err := orchestrator.NewOrchestrator(db, processor)
 	if err != nil {
-		log.Fatalf("Ошибка при создании оркестратора: %v", err)
+		fatal("can't create orchestrator", err)
+	}
+
+	// Если ключ администратора не задан и его нет в базе данных, он создается
+	adminKey, err := orchestrator.EnsureAdminKey(cfg.Auth.AdminKey)
+	if err != nil {
+		fatal("can't create admin API key", err)
+	}
+	if adminKey != "" {
+		reportAdminKey(adminKey, cfg.Auth.AdminKeyFile)
+	}
+
+	// Без ключа подписи токенов пользователей он случайный,
+	// и токены перестают действовать после перезапуска
+	if cfg.Auth.JWTSecret != "" {
+		orchestrator.ConfigureTokens([]byte(cfg.Auth.JWTSecret), 0)
 	}
 
 	// Создание агента (или агентов)
-	agent.NewAgent("AgentName", 100, orchestrator)
+	processor.agent = agent.NewAgent(cfg.Agent.Name, cfg.Agent.QueueSize, orchestrator)
+	orchestrator.RegisterAgent(processor.agent)
 
-	// Инициализация и запуск сервера
+	// Инициализация сервера
 	s := server.NewServer(orchestrator)
-	router := mux.NewRouter()
 
-	// Обработчики запросов
-	router.HandleFunc("/add-calculation", s.AddExpressionHandler).Methods("POST")
-	router.HandleFunc("/get-expressions", s.GetExpressionsHandler).Methods("GET")
-	router.HandleFunc("/get-expression", s.GetExpressionByIDHandler).Methods("GET")
-	router.HandleFunc("/update-operations", s.UpdateOperationsHandler).Methods("POST")
-	router.HandleFunc("/get-available-operations", s.GetAvailableOperationsHandler).Methods("GET")
+	if err := configure(nil, cfg, orchestrator, s, processor.agent); err != nil {
+		fatal("can't apply config", err)
+	}
+	go processor.agent.Start()
 
-	// Запуск сервера
+	// По сигналу SIGHUP конфигурация перечитывается, и настройки, которые можно
+	// изменить без перезапуска, применяются сразу; остальные остаются прежними
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func(current *config.Config) {
+		for range hangup {
+			next, err := loader.Load()
+			if err != nil {
+				slog.Error("can't reload config", "error", err)
+				continue
+			}
+			next, ignored := config.Reload(current, next)
+			for _, key := range ignored {
+				slog.Warn("setting can't be changed without restart", "setting", key)
+			}
+			if err := configure(current, next, orchestrator, s, processor.agent); err != nil {
+				slog.Error("can't apply config", "error", err)
+				continue
+			}
+			current = next
+			slog.Info("config reloaded", "file", loader.Path())
+		}
+	}(cfg)
 
-	fmt.Println("Сервер запущен на :8080...")
-	if err := http.ListenAndServe(":8080", router); err != nil {
-		log.Fatal(err)
+	// Задачи, не вычисленные до перезапуска, снова отправляются агентам
+	recovered, err := orchestrator.RecoverPending()
+	if err != nil {
+		fatal("can't recover pending expressions", err)
 	}
+	if recovered > 0 {
+		slog.Info("recovered pending expressions", "count", recovered)
+	}
+
+	// Обработчики запросов
+	router := s.Router()
+
+	// Запуск сервера
+	addr := cfg.Server.Addr
+	slog.Info("server started", "addr", addr)
+	err = http.ListenAndServe(addr, router)
+	shutdownTracing(context.Background())
+	fatal("server stopped", err)
 }
@@ -1,152 +1,295 @@
 package agent
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"log"
-	"strings"
+	"log/slog"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"calcflow/backend/internal/expr"
+	"calcflow/backend/internal/operation"
 	"calcflow/backend/internal/task"
 	"calcflow/backend/internal/taskresult"
-
-	"github.com/Knetic/govaluate"
+	"calcflow/backend/internal/tracing"
 )
 
+// tracer создает участки трассировки вычисления задач.
+var tracer = otel.Tracer("calcflow/backend/internal/agent")
+
 // Agent представляет вычислительный агент.
 type Agent struct {
 	Name      string          // Имя агента
 	WorkQueue chan *task.Task // Канал-очередь, откуда агент будет брать задачи
-	mu        sync.Mutex
 	processor taskresult.ResultProcessor
+	log       *slog.Logger // Журнал с именем агента
+
+	alive    atomic.Bool // Запущен ли цикл приема задач Start
+	mu       sync.Mutex
+	inflight map[string]taskresult.InFlightTask // Вычисляемые задачи
+	retry    RetryPolicy                        // Повторные попытки получить время выполнения операций
 }
 
+// RetryPolicy задает повторные попытки получить от оркестратора время выполнения
+// операций для задачи.
+type RetryPolicy struct {
+	Attempts int           // Число попыток, не меньше 1
+	Delay    time.Duration // Пауза между попытками
+}
+
+// DefaultRetryPolicy - повторные попытки по умолчанию.
+var DefaultRetryPolicy = RetryPolicy{Attempts: 3, Delay: 100 * time.Millisecond}
+
 // NewAgent создает новый экземпляр агента.
 func NewAgent(name string, workQueueSize int, processor taskresult.ResultProcessor) *Agent {
+	busyWorkers.Set(0, name)
 	return &Agent{
 		Name:      name,
 		WorkQueue: make(chan *task.Task, workQueueSize),
 		processor: processor,
+		log:       slog.With("agent", name),
+		inflight:  make(map[string]taskresult.InFlightTask),
+		retry:     DefaultRetryPolicy,
 	}
 }
 
+// ConfigureRetry задает повторные попытки для задач, которые агент начнет вычислять.
+func (a *Agent) ConfigureRetry(policy RetryPolicy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	policy.Attempts = max(policy.Attempts, 1)
+	a.retry = policy
+}
+
 // Start запускает агента и начинает обработку задач в его очереди.
+// Число одновременно выполняемых задач ограничивает оркестратор.
 func (a *Agent) Start() {
-	for {
-		var task *task.Task
-
-		// Блокировка мьютекса для доступа к каналу
-		a.mu.Lock()
-
-		// Получение задачи из очереди
-		select {
-		case task = <-a.WorkQueue:
-		default:
-			// Если очередь пуста, освобождаем мьютекс и ждем
-			a.mu.Unlock()
-			time.Sleep(time.Millisecond * 100) // Даем шанс другим горутинам
-			continue
-		}
-
-		// Освобождение мьютекса после получения задачи
-		a.mu.Unlock()
+	a.alive.Store(true)
+	defer a.alive.Store(false)
 
-		// Обработка задачи
+	for task := range a.WorkQueue {
 		go a.processTask(task)
 	}
 }
 
+// State возвращает состояние агента: работает ли он, заполненность его очереди
+// и вычисляемые задачи.
+func (a *Agent) State() taskresult.AgentState {
+	a.mu.Lock()
+	inflight := make([]taskresult.InFlightTask, 0, len(a.inflight))
+	for _, t := range a.inflight {
+		t.Elapsed = time.Since(t.Started)
+		inflight = append(inflight, t)
+	}
+	a.mu.Unlock()
+
+	sort.Slice(inflight, func(i, j int) bool {
+		return inflight[i].Started.Before(inflight[j].Started)
+	})
+	return taskresult.AgentState{
+		Name:      a.Name,
+		Alive:     a.alive.Load(),
+		Queued:    len(a.WorkQueue),
+		QueueSize: cap(a.WorkQueue),
+		InFlight:  inflight,
+	}
+}
+
 // ExecuteExpression выполняет вычисление арифметического выражения.
+// Каждая операция занимает время, заданное для нее в calcRequest.
 func (a *Agent) ExecuteExpression(expressionStr string, calcRequest task.CalculationRequest) (string, error) {
-	// Создаем новое выражение
-	expression, err := govaluate.NewEvaluableExpression(expressionStr)
+	graph, err := buildGraph(expressionStr)
 	if err != nil {
 		return "", err
 	}
 
-	// Подсчитываем количество операций в выражении
-	opCounts := map[string]int{
-		"summation":      strings.Count(expressionStr, "+"),
-		"subtraction":    strings.Count(expressionStr, "-"),
-		"multiplication": strings.Count(expressionStr, "*"),
-		"division":       strings.Count(expressionStr, "/"),
-	}
-
-	summation, _ := time.ParseDuration(calcRequest.Summation)
-	subtraction, _ := time.ParseDuration(calcRequest.Subtraction)
-	multiplication, _ := time.ParseDuration(calcRequest.Multiplication)
-	division, _ := time.ParseDuration(calcRequest.Division)
-
-	// Рассчитываем общее время выполнения выражения
-	totalDuration := time.Duration(0)
-	for op, count := range opCounts {
-		switch op {
-		case "summation":
-			totalDuration += summation * time.Duration(count)
-		case "subtraction":
-			totalDuration += subtraction * time.Duration(count)
-		case "multiplication":
-			totalDuration += multiplication * time.Duration(count)
-		case "division":
-			totalDuration += division * time.Duration(count)
+	result, _, err := a.evaluate(context.Background(), graph, calcRequest, nil, false)
+	return result, err
+}
+
+// ExplainExpression вычисляет выражение так же, как ExecuteExpression, и возвращает
+// пошаговую трассировку выполненных операций. При ошибке трассировка содержит
+// операции, выполненные до нее.
+func (a *Agent) ExplainExpression(expressionStr string, calcRequest task.CalculationRequest) (string, []task.TraceStep, error) {
+	graph, err := buildGraph(expressionStr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return a.evaluate(context.Background(), graph, calcRequest, nil, true)
+}
+
+// buildGraph разбирает выражение и строит его граф операций.
+func buildGraph(expressionStr string) (*expr.Graph, error) {
+	expression, err := expr.Parse(expressionStr)
+	if err != nil {
+		return nil, err
+	}
+	return expr.BuildGraph(expression)
+}
+
+// evaluate вычисляет граф операций, при необходимости записывая трассировку.
+// Если задана общая таблица memo, одинаковые операции разных задач выполняются один раз.
+// Если в ctx есть участок распределенной трассировки, каждая операция записывается
+// в дочернем к нему участке.
+func (a *Agent) evaluate(ctx context.Context, graph *expr.Graph, calcRequest task.CalculationRequest, memo *expr.Memo, explain bool) (string, []task.TraceStep, error) {
+	var trace []task.TraceStep
+	steps := make(map[int]int) // Номер операции трассировки для каждого узла графа
+	var started time.Time
+
+	// Участок операции начинается с окончания предыдущей, чтобы включать и ожидание
+	// результата одинаковой операции, которую выполняет другая задача
+	traced := oteltrace.SpanFromContext(ctx).IsRecording()
+	var current *operation.Operation // Выполняемая операция, для участка при ошибке
+	mark := time.Now()
+
+	// Вычисляем граф, имитируя время выполнения каждой операции
+	evaluator := expr.GraphEvaluator{
+		Memo: memo,
+		Before: func(op *operation.Operation) {
+			time.Sleep(operationCost(op, calcRequest))
+		},
+	}
+	if traced {
+		evaluator.Before = func(op *operation.Operation) {
+			current = op
+			time.Sleep(operationCost(op, calcRequest))
+		}
+		evaluator.After = func(step expr.GraphStep) {
+			_, span := tracer.Start(ctx, "operation "+step.Node.Op.Name, oteltrace.WithTimestamp(mark), oteltrace.WithAttributes(
+				attribute.String("expression", step.Node.Expr.String()),
+				attribute.Float64("result", step.Result),
+				attribute.Bool("shared", step.Shared),
+			))
+			span.End()
+			current = nil
+			mark = time.Now()
+		}
+	}
+	if explain {
+		before, after := evaluator.Before, evaluator.After
+		evaluator.Before = func(op *operation.Operation) {
+			started = time.Now()
+			before(op)
+		}
+		evaluator.After = func(step expr.GraphStep) {
+			if step.Shared {
+				started = time.Now()
+			}
+			trace = append(trace, task.TraceStep{
+				Step:       len(trace) + 1,
+				Operation:  step.Node.Op.Name,
+				Expression: step.Node.Expr.String(),
+				Operands:   step.Operands,
+				Result:     step.Result,
+				Agent:      a.Name,
+				Shared:     step.Shared,
+				Started:    started,
+				Finished:   time.Now(),
+			})
+			steps[step.Node.ID] = len(trace)
+			if after != nil {
+				after(step)
+			}
 		}
 	}
 
-	// Создаем таймер с общим временем выполнения
-	timer := time.NewTimer(totalDuration)
+	result, err := evaluator.Eval(graph)
+	if explain {
+		linkTraceSteps(graph, steps, trace)
+	}
+	if err != nil {
+		if current != nil {
+			_, span := tracer.Start(ctx, "operation "+current.Name, oteltrace.WithTimestamp(mark))
+			tracing.SetError(span, err)
+			span.End()
+		}
+		return "", trace, err
+	}
 
-	// Запускаем горутину для выполнения выражения
-	resultChan := make(chan string)
-	go func() {
-		defer close(resultChan)
-		result, err := expression.Evaluate(nil)
-		if err != nil {
-			resultChan <- ""
-			return
+	return fmt.Sprintf("%v", result), trace, nil
+}
+
+// linkTraceSteps проставляет каждой операции трассировки номер первой операции,
+// которая использует ее результат.
+func linkTraceSteps(graph *expr.Graph, steps map[int]int, trace []task.TraceStep) {
+	for _, node := range graph.Nodes {
+		parent, ok := steps[node.ID]
+		if !ok {
+			continue
+		}
+		for _, input := range node.Inputs {
+			if step, ok := steps[input]; ok && trace[step-1].Parent == 0 {
+				trace[step-1].Parent = parent
+			}
 		}
-		resultChan <- fmt.Sprintf("%v", result)
-	}()
+	}
+}
 
-	// Ждем результат или таймаут
-	select {
-	case result := <-resultChan:
-		// Если получен результат из канала, возвращаем его
-		return result, nil
-	case <-timer.C:
-		// Если истекло время таймаута, возвращаем ошибку
-		return "", errors.New("превышено время выполнения выражения")
+// operationCost возвращает время выполнения операции из настроек
+// или время по умолчанию из реестра операций.
+func operationCost(op *operation.Operation, calcRequest task.CalculationRequest) time.Duration {
+	duration, err := time.ParseDuration(calcRequest[op.Name])
+	if err != nil {
+		return op.DefaultCost
 	}
+	return duration
 }
 
-// processTask обрабатывает задачу и отправляет результат обратно оркестратору.
 // processTask обрабатывает задачу и отправляет результат обратно оркестратору.
 func (a *Agent) processTask(taskToWork *task.Task) {
-	var maxAttempts = 3
-	var retryDelay = time.Millisecond * 100
+	busyWorkers.Add(1, a.Name)
+	started := time.Now()
+	a.mu.Lock()
+	a.inflight[taskToWork.ID] = taskresult.InFlightTask{ID: taskToWork.ID, Tenant: taskToWork.Tenant, Started: started}
+	maxAttempts, retryDelay := a.retry.Attempts, a.retry.Delay
+	a.mu.Unlock()
+	ctx, span := tracer.Start(tracing.ContextFrom(taskToWork.TraceParent), "Agent.processTask", oteltrace.WithAttributes(
+		attribute.String("agent", a.Name),
+		attribute.String("task.id", taskToWork.ID),
+	))
+	a.taskLogger(taskToWork).DebugContext(ctx, "task started")
 
-	// Получаем время выполнения для каждой операции от оркестратора из таблицы CalculationRequest
+	// Получаем время выполнения для каждой операции от оркестратора с учетом арендатора задачи
+	var err error
 	for attempts := 0; attempts < maxAttempts; attempts++ {
-		calcRequest, err := a.processor.GetAvailableOperations()
+		var calcRequest task.CalculationRequest
+		calcRequest, err = a.processor.GetAvailableOperations(taskToWork.Tenant)
 		if err != nil {
-			log.Printf("Ошибка получения времени выполнения операций: %v. Повторная попытка через %v", err, retryDelay)
+			a.taskLogger(taskToWork).WarnContext(ctx, "can't get operation timings, retrying",
+				"attempt", attempts+1, "delay", retryDelay, "error", err)
 			time.Sleep(retryDelay)
 			continue
 		}
 
-		// Проверяем, все ли значения в CalculationRequest заполнены
-		if isEmpty(calcRequest) {
-			// Если хотя бы одно значение пусто, устанавливаем время выполнения каждой операции в ноль
-			calcRequest = &task.CalculationRequest{
-				Summation:      "0s",
-				Subtraction:    "0s",
-				Multiplication: "0s",
-				Division:       "0s",
+		// Граф операций строится оркестратором; если его нет, строим его по выражению
+		// с раскрытыми пользовательскими функциями, если они были
+		graph := taskToWork.Graph
+		if graph == nil {
+			expression := taskToWork.Expression
+			if taskToWork.Expanded != "" {
+				expression = taskToWork.Expanded
 			}
+			graph, err = buildGraph(expression)
 		}
 
-		// Обработка задачи
-		result, err := a.ExecuteExpression(taskToWork.Expression, *calcRequest)
+		// Обработка задачи с использованием общей таблицы результатов операций
+		var result string
+		var trace []task.TraceStep
+		if err == nil {
+			memo := a.processor.Memo()
+			memo.Acquire(graph.Keys())
+			result, trace, err = a.evaluate(ctx, graph, calcRequest, memo, taskToWork.Explain)
+			memo.Release(graph.Keys())
+		}
+		taskToWork.Trace = trace
 		if err != nil || result == "" {
 			taskToWork.Status = "error" // Меняем статус вычисления выражения на "error"
 			taskToWork.Result = ""
@@ -156,25 +299,59 @@ func (a *Agent) processTask(taskToWork *task.Task) {
 		}
 
 		// Отправка результата обратно оркестратору
-		a.processor.ReceiveResult(taskToWork)
+		span.SetAttributes(attribute.Int("attempts", attempts+1))
+		a.finish(ctx, taskToWork, started, err)
 		return
 	}
 
 	// Если не удалось выполнить задачу после нескольких попыток, устанавливаем статус "error"
 	taskToWork.Status = "error"
 	taskToWork.Result = ""
-	a.processor.ReceiveResult(taskToWork)
+	span.SetAttributes(attribute.Int("attempts", maxAttempts))
+	a.finish(ctx, taskToWork, started, err)
 }
 
-// isEmpty проверяет, является ли структура CalculationRequest пустой (все поля пусты или равны нулю)
-func isEmpty(calcRequest *task.CalculationRequest) bool {
-	return calcRequest.Summation == "" && calcRequest.Subtraction == "" && calcRequest.Multiplication == "" && calcRequest.Division == ""
+// taskLogger возвращает журнал агента с идентификатором задачи и ее арендатором.
+func (a *Agent) taskLogger(t *task.Task) *slog.Logger {
+	return a.log.With("task_id", t.ID, "tenant", t.Tenant)
 }
 
-// EnqueueTask добавляет задачу в очередь агента для выполнения.
-func (a *Agent) EnqueueTask(task *task.Task) {
+// finish учитывает время вычисления задачи, начатого в started, завершает ее участок
+// трассировки из ctx и отправляет результат оркестратору; err - ошибка вычисления,
+// если она была.
+func (a *Agent) finish(ctx context.Context, t *task.Task, started time.Time, err error) {
+	elapsed := time.Since(started)
+	computationTime.Observe(elapsed.Seconds(), a.Name, t.Status)
+	busyWorkers.Add(-1, a.Name)
 	a.mu.Lock()
-	defer a.mu.Unlock()
+	delete(a.inflight, t.ID)
+	a.mu.Unlock()
 
-	a.WorkQueue <- task
+	span := oteltrace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("task.status", t.Status))
+	tracing.SetError(span, err)
+	span.End()
+
+	// После передачи результата задачу изменяет оркестратор, поэтому журнал
+	// и статус подготавливаются заранее
+	log := a.taskLogger(t).With("status", t.Status)
+	if err != nil {
+		log.DebugContext(ctx, "task computed", "duration", elapsed, "error", err)
+	} else {
+		log.DebugContext(ctx, "task computed", "duration", elapsed)
+	}
+	if err := a.processor.ReceiveResult(t); err != nil {
+		log.ErrorContext(ctx, "can't deliver result", "error", err)
+	}
+}
+
+// EnqueueTask добавляет задачу в очередь агента для выполнения, не блокируясь:
+// если очередь заполнена, возвращается taskresult.ErrQueueFull.
+func (a *Agent) EnqueueTask(task *task.Task) error {
+	select {
+	case a.WorkQueue <- task:
+		return nil
+	default:
+		return taskresult.ErrQueueFull
+	}
 }
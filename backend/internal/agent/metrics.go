@@ -0,0 +1,10 @@
+package agent
+
+import "calcflow/backend/internal/metrics"
+
+var (
+	busyWorkers = metrics.Default.Gauge("calcflow_agent_busy_workers",
+		"Задачи, которые агент вычисляет в данный момент", "agent")
+	computationTime = metrics.Default.Histogram("calcflow_agent_computation_seconds",
+		"Время вычисления задачи агентом по статусу результата", metrics.DefaultBuckets, "agent", "status")
+)
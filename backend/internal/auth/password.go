@@ -0,0 +1,93 @@
+// Пакет auth содержит хеширование паролей пользователей и подписанные
+// токены доступа в формате JWT. Используется только стандартная библиотека.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PasswordIterations - число итераций PBKDF2-HMAC-SHA256 для новых паролей.
+const PasswordIterations = 600000
+
+const (
+	passwordScheme  = "pbkdf2-sha256"
+	passwordSaltLen = 16
+	passwordKeyLen  = 32
+)
+
+// ErrMalformedHash возвращается при проверке пароля по хешу неизвестного формата.
+var ErrMalformedHash = errors.New("malformed password hash")
+
+// HashPassword вычисляет хеш пароля со случайной солью в формате
+// "pbkdf2-sha256$<итерации>$<соль>$<ключ>".
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2([]byte(password), salt, PasswordIterations, passwordKeyLen)
+	return fmt.Sprintf("%s$%d$%s$%s", passwordScheme, PasswordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword сообщает, соответствует ли пароль хешу, полученному HashPassword.
+func CheckPassword(hash, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false, ErrMalformedHash
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false, ErrMalformedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, ErrMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return false, ErrMalformedHash
+	}
+
+	computed := pbkdf2([]byte(password), salt, iterations, len(key))
+	return subtle.ConstantTimeCompare(computed, key) == 1, nil
+}
+
+// pbkdf2 вычисляет ключ длиной keyLen по RFC 8018 с HMAC-SHA256.
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	size := prf.Size()
+	blocks := (keyLen + size - 1) / size
+
+	key := make([]byte, 0, blocks*size)
+	var counter [4]byte
+	u := make([]byte, size)
+	t := make([]byte, size)
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter[:])
+		u = prf.Sum(u[:0])
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
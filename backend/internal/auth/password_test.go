@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestPBKDF2(t *testing.T) {
+	// Контрольные значения PBKDF2-HMAC-SHA256 из RFC 7914 и RFC 6070 с заменой SHA-1 на SHA-256
+	tests := []struct {
+		password, salt string
+		iterations     int
+		keyLen         int
+		want           string
+	}{
+		{"passwd", "salt", 1, 64, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"password", "salt", 4096, 32, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 40, "348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2([]byte(tt.password), []byte(tt.salt), tt.iterations, tt.keyLen))
+		if got != tt.want {
+			t.Errorf("pbkdf2(%q, %q, %d) = %s, want %s", tt.password, tt.salt, tt.iterations, got, tt.want)
+		}
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$600000$") {
+		t.Errorf("hash %s has an unexpected format", hash)
+	}
+
+	for _, tt := range []struct {
+		password string
+		want     bool
+	}{
+		{"correct horse", true},
+		{"correct horse ", false},
+		{"Correct horse", false},
+		{"", false},
+	} {
+		ok, err := CheckPassword(hash, tt.password)
+		if err != nil || ok != tt.want {
+			t.Errorf("CheckPassword(%q) = %v, %v; want %v", tt.password, ok, err, tt.want)
+		}
+	}
+
+	// Соль случайна, поэтому хеши одного пароля различаются
+	other, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == hash {
+		t.Error("two hashes of the same password are equal")
+	}
+}
+
+func TestCheckPasswordMalformed(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"plain text",
+		"bcrypt$10$c2FsdA$a2V5",
+		"pbkdf2-sha256$many$c2FsdA$a2V5",
+		"pbkdf2-sha256$0$c2FsdA$a2V5",
+		"pbkdf2-sha256$1$not base64!$a2V5",
+		"pbkdf2-sha256$1$c2FsdA$",
+		"pbkdf2-sha256$1$c2FsdA",
+	} {
+		if _, err := CheckPassword(hash, "password"); err != ErrMalformedHash {
+			t.Errorf("CheckPassword(%q): %v, want ErrMalformedHash", hash, err)
+		}
+	}
+}
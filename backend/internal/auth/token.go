@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// DefaultTokenTTL - время действия токена доступа по умолчанию.
+const DefaultTokenTTL = 24 * time.Hour
+
+// Issuer - издатель токенов, записываемый в поле iss.
+const Issuer = "calcflow"
+
+var (
+	// ErrInvalidToken возвращается для токена с неверным форматом, алгоритмом или подписью.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired возвращается для токена, срок действия которого истек.
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// Claims представляет утверждения токена доступа.
+type Claims struct {
+	Subject   string `json:"sub"`  // Идентификатор пользователя
+	Name      string `json:"name"` // Имя пользователя
+	Issuer    string `json:"iss"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// tokenHeader - заголовок всех выпускаемых токенов.
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Signer выпускает и проверяет токены JWT, подписанные HMAC-SHA256.
+type Signer struct {
+	key []byte
+	ttl time.Duration
+}
+
+// NewSigner создает Signer с ключом подписи key и временем действия токенов ttl.
+func NewSigner(key []byte, ttl time.Duration) *Signer {
+	if ttl <= 0 {
+		ttl = DefaultTokenTTL
+	}
+	return &Signer{key: key, ttl: ttl}
+}
+
+// Issue выпускает токен для пользователя и возвращает его вместе со временем истечения.
+func (s *Signer) Issue(userID, name string, now time.Time) (string, time.Time, error) {
+	expires := now.Add(s.ttl)
+	payload, err := json.Marshal(Claims{
+		Subject:   userID,
+		Name:      name,
+		Issuer:    Issuer,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expires.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	signed := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + s.sign(signed), expires, nil
+}
+
+// Parse проверяет подпись и срок действия токена и возвращает его утверждения.
+func (s *Signer) Parse(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(rawHeader, &header) != nil || header.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(parts[0]+"."+parts[1]))) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(payload, &claims) != nil || claims.Issuer != Issuer || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+	return &claims, nil
+}
+
+func (s *Signer) sign(signed string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(signed))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
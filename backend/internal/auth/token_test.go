@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenRoundTrip(t *testing.T) {
+	signer := NewSigner([]byte("secret"), time.Hour)
+	now := time.Unix(1700000000, 0)
+
+	token, expires, err := signer.Issue("user-1", "alice", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("expires %v, want %v", expires, now.Add(time.Hour))
+	}
+
+	claims, err := signer.Parse(token, now.Add(time.Hour-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Claims{Subject: "user-1", Name: "alice", Issuer: Issuer, IssuedAt: now.Unix(), ExpiresAt: expires.Unix()}
+	if *claims != want {
+		t.Errorf("claims %+v, want %+v", *claims, want)
+	}
+
+	if _, err := signer.Parse(token, expires); err != ErrTokenExpired {
+		t.Errorf("Parse at expiry: %v, want ErrTokenExpired", err)
+	}
+	if _, err := NewSigner([]byte("other"), time.Hour).Parse(token, now); err != ErrInvalidToken {
+		t.Errorf("Parse with another key: %v, want ErrInvalidToken", err)
+	}
+	if ttl := NewSigner(nil, 0).ttl; ttl != DefaultTokenTTL {
+		t.Errorf("default ttl %v, want %v", ttl, DefaultTokenTTL)
+	}
+}
+
+func TestParseInvalidToken(t *testing.T) {
+	signer := NewSigner([]byte("secret"), time.Hour)
+	now := time.Unix(1700000000, 0)
+	token, _, err := signer.Issue("user-1", "alice", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	// Токен с подписью signer и произвольными заголовком и утверждениями
+	signed := func(header, payload string) string {
+		unsigned := encode(header) + "." + encode(payload)
+		return unsigned + "." + signer.sign(unsigned)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"two parts", parts[0] + "." + parts[1]},
+		{"four parts", token + ".x"},
+		{"tampered payload", parts[0] + "." + encode(`{"sub":"admin","iss":"calcflow","exp":9999999999}`) + "." + parts[2]},
+		{"tampered signature", parts[0] + "." + parts[1] + "." + encode("signature")},
+		{"alg none", encode(`{"alg":"none","typ":"JWT"}`) + "." + parts[1] + "."},
+		{"signed alg none", signed(`{"alg":"none"}`, `{"sub":"user-1","iss":"calcflow","exp":9999999999}`)},
+		{"header not JSON", signed(`alg`, `{"sub":"user-1","iss":"calcflow","exp":9999999999}`)},
+		{"payload not JSON", signed(`{"alg":"HS256"}`, `sub`)},
+		{"other issuer", signed(`{"alg":"HS256"}`, `{"sub":"user-1","iss":"other","exp":9999999999}`)},
+		{"no subject", signed(`{"alg":"HS256"}`, `{"iss":"calcflow","exp":9999999999}`)},
+	}
+	for _, tt := range tests {
+		if _, err := signer.Parse(tt.token, now); err != ErrInvalidToken {
+			t.Errorf("%s: Parse: %v, want ErrInvalidToken", tt.name, err)
+		}
+	}
+
+	// Токен без срока действия считается истекшим
+	if _, err := signer.Parse(signed(`{"alg":"HS256"}`, `{"sub":"user-1","iss":"calcflow"}`), now); err != ErrTokenExpired {
+		t.Errorf("token without exp: %v, want ErrTokenExpired", err)
+	}
+}
@@ -0,0 +1,260 @@
+// Package config собирает настройки сервера из значений по умолчанию, файла
+// конфигурации в формате YAML или TOML, переменных окружения и флагов командной
+// строки; каждый следующий источник переопределяет предыдущий. Часть настроек
+// можно изменить без перезапуска, перечитав конфигурацию.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"calcflow/backend/internal/agent"
+	"calcflow/backend/internal/logging"
+	"calcflow/backend/internal/operation"
+	"calcflow/backend/internal/orchestrator"
+	"calcflow/backend/internal/server"
+	"calcflow/backend/internal/task"
+)
+
+// Config - настройки сервера.
+type Config struct {
+	Server       Server
+	Database     Database
+	Agent        Agent
+	Orchestrator Orchestrator
+	Auth         Auth
+	Log          Log
+	Trace        Trace
+
+	// Общее время выполнения операций (ключи operations.<название>); задается
+	// только в файле и записывается в базу данных, как PUT /api/v2/operations
+	Operations task.CalculationRequest
+}
+
+// Server - настройки HTTP-сервера.
+type Server struct {
+	Addr      string  // Адрес, на котором сервер принимает запросы
+	RateLimit float64 // Запросов клиента в секунду; 0 отключает ограничение
+	RateBurst int     // Запросов клиента подряд
+}
+
+// Database - настройки базы данных.
+type Database struct {
+	Path string // Файл базы данных SQLite
+}
+
+// Agent - настройки агента.
+type Agent struct {
+	Name          string        // Имя агента в журнале, метриках и трассировке
+	QueueSize     int           // Размер очереди задач агента
+	Workers       int           // Задач, вычисляемых одновременно; 0 - по размеру очереди
+	RetryAttempts int           // Попыток получить время выполнения операций
+	RetryDelay    time.Duration // Пауза между попытками
+}
+
+// WorkerCount возвращает число задач, которые агент вычисляет одновременно.
+func (a Agent) WorkerCount() int {
+	if a.Workers > 0 {
+		return a.Workers
+	}
+	return a.QueueSize
+}
+
+// Orchestrator - настройки очереди, квот и кэша оркестратора.
+type Orchestrator struct {
+	MaxBacklog        int           // Задач, ожидающих отправки агентам; 0 снимает ограничение
+	DailyQuota        time.Duration // Суточная квота клиента; 0 отключает ее
+	EvaluateThreshold time.Duration // Порог вычисления при запросе; 0 отключает его
+	CacheTTL          time.Duration // Время хранения результата в кэше; 0 отключает кэш
+	CacheSize         int           // Результатов в кэше; 0 - без ограничения
+}
+
+// Auth - настройки учетных данных.
+type Auth struct {
+	AdminKey     string // Ключ администратора; без него ключ создается при первом запуске
+	AdminKeyFile string // Файл для созданного ключа; без него ключ выводится в стандартный поток ошибок
+	JWTSecret    string // Ключ подписи токенов; без него ключ случайный
+}
+
+// Log - настройки журнала.
+type Log struct {
+	Level  slog.Level // Уровень журнала
+	Format string     // text или json
+}
+
+// Trace - настройки трассировки.
+type Trace struct {
+	File         string // Файл для участков трассировки строками JSON или stdout
+	OTLPEndpoint string // Адрес приемника OTLP/HTTP; без него и без File трассировка отключена
+}
+
+// Default возвращает настройки по умолчанию.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Addr:      ":8080",
+			RateLimit: server.DefaultRateLimit.Rate,
+			RateBurst: server.DefaultRateLimit.Burst,
+		},
+		Database: Database{Path: "database.db"},
+		Agent: Agent{
+			Name:          "AgentName",
+			QueueSize:     100,
+			RetryAttempts: agent.DefaultRetryPolicy.Attempts,
+			RetryDelay:    agent.DefaultRetryPolicy.Delay,
+		},
+		Orchestrator: Orchestrator{
+			MaxBacklog:        orchestrator.DefaultMaxBacklog,
+			DailyQuota:        orchestrator.DefaultDailyQuota,
+			EvaluateThreshold: orchestrator.DefaultEvaluateThreshold,
+			CacheTTL:          orchestrator.DefaultCacheConfig.TTL,
+			CacheSize:         orchestrator.DefaultCacheConfig.Size,
+		},
+		Log:        Log{Level: slog.LevelInfo, Format: logging.FormatText},
+		Operations: make(task.CalculationRequest),
+	}
+}
+
+// setting описывает настройку: ключ в файле, флаг командной строки и поле Config.
+// Переменная окружения - флаг в верхнем регистре с префиксом CALCFLOW_,
+// например CALCFLOW_RATE_LIMIT для -rate-limit.
+type setting struct {
+	key    string
+	flag   string
+	usage  string
+	reload bool // Применяется без перезапуска
+	field  func(c *Config) any
+}
+
+// env возвращает переменную окружения настройки.
+func (s setting) env() string {
+	return "CALCFLOW_" + strings.ToUpper(strings.ReplaceAll(s.flag, "-", "_"))
+}
+
+// settings - все настройки, кроме времени выполнения операций.
+var settings = []setting{
+	{"server.addr", "addr", "HTTP listen address", false, func(c *Config) any { return &c.Server.Addr }},
+	{"server.rate_limit", "rate-limit", "requests per second per client, 0 disables the limit", true, func(c *Config) any { return &c.Server.RateLimit }},
+	{"server.rate_burst", "rate-burst", "requests in a row per client", true, func(c *Config) any { return &c.Server.RateBurst }},
+	{"database.path", "database", "SQLite database file", false, func(c *Config) any { return &c.Database.Path }},
+	{"agent.name", "agent-name", "agent name", false, func(c *Config) any { return &c.Agent.Name }},
+	{"agent.queue_size", "agent-queue-size", "agent task queue size", false, func(c *Config) any { return &c.Agent.QueueSize }},
+	{"agent.workers", "agent-workers", "tasks computed at once, 0 means the queue size", true, func(c *Config) any { return &c.Agent.Workers }},
+	{"agent.retry_attempts", "agent-retry-attempts", "attempts to get operation timings for a task", true, func(c *Config) any { return &c.Agent.RetryAttempts }},
+	{"agent.retry_delay", "agent-retry-delay", "delay between attempts", true, func(c *Config) any { return &c.Agent.RetryDelay }},
+	{"orchestrator.max_backlog", "max-backlog", "tasks waiting for agents, 0 removes the limit", true, func(c *Config) any { return &c.Orchestrator.MaxBacklog }},
+	{"orchestrator.daily_quota", "daily-quota", "daily estimated computation time per client, 0 disables the quota", true, func(c *Config) any { return &c.Orchestrator.DailyQuota }},
+	{"orchestrator.evaluate_threshold", "evaluate-threshold", "largest estimate evaluated inline by POST /evaluate, 0 disables inline evaluation", true, func(c *Config) any { return &c.Orchestrator.EvaluateThreshold }},
+	{"orchestrator.cache_ttl", "cache-ttl", "result cache TTL, 0 disables the cache", true, func(c *Config) any { return &c.Orchestrator.CacheTTL }},
+	{"orchestrator.cache_size", "cache-size", "result cache size, 0 means unlimited", true, func(c *Config) any { return &c.Orchestrator.CacheSize }},
+	{"auth.admin_key", "admin-key", "admin API key", false, func(c *Config) any { return &c.Auth.AdminKey }},
+	{"auth.admin_key_file", "admin-key-file", "new file (mode 0600) for the generated admin API key instead of stderr", false, func(c *Config) any { return &c.Auth.AdminKeyFile }},
+	{"auth.jwt_secret", "jwt-secret", "user token signing key", false, func(c *Config) any { return &c.Auth.JWTSecret }},
+	{"log.level", "log-level", "log level: debug, info, warn or error", true, func(c *Config) any { return &c.Log.Level }},
+	{"log.format", "log-format", "log format: text or json", false, func(c *Config) any { return &c.Log.Format }},
+	{"trace.file", "trace-file", "file for trace spans as JSON lines or stdout", false, func(c *Config) any { return &c.Trace.File }},
+	{"trace.otlp_endpoint", "trace-otlp-endpoint", "OTLP/HTTP trace collector URL, e.g. http://localhost:4318", false, func(c *Config) any { return &c.Trace.OTLPEndpoint }},
+}
+
+// operationsPrefix - префикс ключей времени выполнения операций.
+const operationsPrefix = "operations."
+
+// set задает настройку key значением value.
+func (c *Config) set(key, value string) error {
+	if name, ok := strings.CutPrefix(key, operationsPrefix); ok && name != "" {
+		c.Operations[strings.ToLower(name)] = value
+		return nil
+	}
+	for _, s := range settings {
+		if s.key == key {
+			return parse(s.field(c), key, value)
+		}
+	}
+	return fmt.Errorf("unknown setting %q", key)
+}
+
+// parse разбирает значение value в поле field.
+func parse(field any, key, value string) error {
+	var err error
+	switch field := field.(type) {
+	case *string:
+		*field = value
+	case *int:
+		*field, err = strconv.Atoi(value)
+	case *float64:
+		*field, err = strconv.ParseFloat(value, 64)
+	case *time.Duration:
+		*field, err = time.ParseDuration(value)
+	case *slog.Level:
+		*field, err = logging.ParseLevel(value)
+	default:
+		panic(fmt.Sprintf("config: unsupported type %T of %s", field, key))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %s", value, key)
+	}
+	return nil
+}
+
+// Validate проверяет настройки и возвращает все найденные ошибки.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr is required")
+	check(c.Server.RateLimit >= 0, "server.rate_limit must not be negative")
+	check(c.Server.RateBurst >= 1, "server.rate_burst must be at least 1")
+	check(c.Database.Path != "", "database.path is required")
+	check(c.Agent.Name != "", "agent.name is required")
+	check(c.Agent.QueueSize >= 1, "agent.queue_size must be at least 1")
+	check(c.Agent.Workers >= 0 && c.Agent.Workers <= c.Agent.QueueSize,
+		"agent.workers must be between 0 and agent.queue_size (%d)", c.Agent.QueueSize)
+	check(c.Agent.RetryAttempts >= 1, "agent.retry_attempts must be at least 1")
+	check(c.Agent.RetryDelay >= 0, "agent.retry_delay must not be negative")
+	check(c.Orchestrator.MaxBacklog >= 0, "orchestrator.max_backlog must not be negative")
+	check(c.Orchestrator.DailyQuota >= 0, "orchestrator.daily_quota must not be negative")
+	check(c.Orchestrator.EvaluateThreshold >= 0, "orchestrator.evaluate_threshold must not be negative")
+	check(c.Orchestrator.CacheTTL >= 0, "orchestrator.cache_ttl must not be negative")
+	check(c.Orchestrator.CacheSize >= 0, "orchestrator.cache_size must not be negative")
+	format := strings.ToLower(c.Log.Format)
+	check(format == logging.FormatText || format == logging.FormatJSON, "log.format must be %s or %s", logging.FormatText, logging.FormatJSON)
+
+	for name, value := range c.Operations {
+		if _, ok := operation.Default.ByName(name); !ok {
+			errs = append(errs, fmt.Errorf("unknown operation %q in %s%s", name, operationsPrefix, name))
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		check(err == nil && d >= 0, "invalid duration %q for %s%s", value, operationsPrefix, name)
+	}
+	return errors.Join(errs...)
+}
+
+// Reload возвращает конфигурацию next, в которой настройки, требующие перезапуска,
+// оставлены такими же, как в действующей конфигурации current, и ключи тех из них,
+// которые в next изменились.
+func Reload(current, next *Config) (*Config, []string) {
+	effective := *next
+	var ignored []string
+	for _, s := range settings {
+		if s.reload {
+			continue
+		}
+		was := reflect.ValueOf(s.field(current)).Elem()
+		now := reflect.ValueOf(s.field(&effective)).Elem()
+		if !now.Equal(was) {
+			ignored = append(ignored, s.key)
+			now.Set(was)
+		}
+	}
+	return &effective, ignored
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ConfigEnv - переменная окружения с путем к файлу конфигурации, если не задан флаг -config.
+const ConfigEnv = "CALCFLOW_CONFIG"
+
+// Loader собирает конфигурацию из значений по умолчанию, файла конфигурации,
+// переменных окружения и флагов командной строки. Файл и переменные окружения
+// читаются при каждом вызове Load, флаги разбираются один раз.
+type Loader struct {
+	path      string                      // Файл конфигурации
+	flags     map[string]string           // Значения заданных флагов по ключам настроек
+	lookupEnv func(string) (string, bool) // Чтение переменных окружения
+}
+
+// NewLoader разбирает флаги командной строки args программы name. Ошибки разбора
+// и справка выводятся в стандартный поток ошибок; для -h возвращается flag.ErrHelp.
+func NewLoader(name string, args []string) (*Loader, error) {
+	l := &Loader{flags: make(map[string]string), lookupEnv: os.LookupEnv}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&l.path, "config", os.Getenv(ConfigEnv), "config file (.yaml, .yml or .toml)")
+	for _, s := range settings {
+		key := s.key
+		fs.Func(s.flag, fmt.Sprintf("%s (%s, env %s)", s.usage, key, s.env()), func(value string) error {
+			l.flags[key] = value
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		// Сообщение об ошибке и справка выводятся так же, как для неизвестного флага
+		err := fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+		fmt.Fprintln(fs.Output(), err)
+		fs.Usage()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Path возвращает путь к файлу конфигурации или пустую строку.
+func (l *Loader) Path() string {
+	return l.path
+}
+
+// Load собирает и проверяет конфигурацию. Значение из каждого следующего источника
+// переопределяет предыдущее: значения по умолчанию, файл, переменные окружения, флаги.
+// Пустые переменные окружения не учитываются.
+func (l *Loader) Load() (*Config, error) {
+	cfg := Default()
+
+	if l.path != "" {
+		values, err := readFile(l.path)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range sortedKeys(values) {
+			if err := cfg.set(key, values[key]); err != nil {
+				return nil, fmt.Errorf("%s: %w", l.path, err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := l.lookupEnv(s.env()); ok && value != "" {
+			if err := cfg.set(s.key, value); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env(), err)
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(l.flags) {
+		if err := cfg.set(key, l.flags[key]); err != nil {
+			return nil, fmt.Errorf("command line: %w", err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readFile читает файл конфигурации; формат определяется по расширению.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		values, err = parseYAML(data)
+	case ".toml":
+		values, err = parseTOML(data)
+	default:
+		return nil, fmt.Errorf("%s: unsupported config format %q", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Файлы конфигурации разбираются библиотеками gopkg.in/yaml.v3 и
+// github.com/BurntSushi/toml и сводятся к плоскому набору значений с ключами вида
+// section.name. Настройки - скалярные значения во вложенных разделах; списки,
+// даты и другие значения отклоняются.
+
+// parseYAML разбирает файл YAML.
+func parseYAML(data []byte) (map[string]string, error) {
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	if err := flatten(values, "", doc); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// parseTOML разбирает файл TOML. Строки, в том числе длительности вроде "2h",
+// записываются в кавычках.
+func parseTOML(data []byte) (map[string]string, error) {
+	var doc map[string]any
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	if err := flatten(values, "", doc); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// flatten добавляет в values значения раздела section, дописывая к их ключам
+// префикс prefix. Пустые значения YAML (`key:` без значения) пропускаются.
+func flatten(values map[string]string, prefix string, section map[string]any) error {
+	names := make([]string, 0, len(section))
+	for name := range section {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key := prefix + name
+		var value string
+		switch v := section[name].(type) {
+		case nil:
+			continue
+		case map[string]any:
+			if err := flatten(values, key+".", v); err != nil {
+				return err
+			}
+			continue
+		case string:
+			value = v
+		case bool:
+			value = strconv.FormatBool(v)
+		case int:
+			value = strconv.Itoa(v)
+		case int64:
+			value = strconv.FormatInt(v, 10)
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		case []any, []map[string]any:
+			return fmt.Errorf("%s: lists are not supported", key)
+		default:
+			return fmt.Errorf("%s: unsupported value %v, expected a string, number, boolean or section", key, v)
+		}
+		if _, ok := values[key]; ok {
+			return fmt.Errorf("duplicate key %s", key)
+		}
+		values[key] = value
+	}
+	return nil
+}
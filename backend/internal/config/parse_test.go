@@ -0,0 +1,95 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	want := map[string]string{
+		"server.addr":              ":9090",
+		"server.rate_limit":        "2.5",
+		"server.rate_burst":        "20",
+		"agent.retry_delay":        "100ms",
+		"trace.file":               "",
+		"operations.summation":     "1s",
+		"orchestrator.cache_ttl":   "1h",
+		"orchestrator.max_backlog": "10000",
+	}
+
+	tests := []struct {
+		name  string
+		parse func([]byte) (map[string]string, error)
+		src   string
+	}{
+		{"yaml", parseYAML, `
+server:
+  addr: ":9090"
+  rate_limit: 2.5   # запросов в секунду
+  rate_burst: 20
+agent:
+  retry_delay: 100ms
+  name:
+trace:
+  file: ""
+operations.summation: 1s
+orchestrator: {cache_ttl: 1h, max_backlog: 10_000}
+`},
+		{"toml", parseTOML, `
+operations.summation = "1s"
+
+[server]
+addr = ":9090"
+rate_limit = 2.5 # запросов в секунду
+rate_burst = 20
+
+[agent]
+retry_delay = '100ms'
+
+[trace]
+file = ""
+
+[orchestrator]
+cache_ttl = "1h"
+max_backlog = 10_000
+`},
+	}
+	for _, tt := range tests {
+		got, err := tt.parse([]byte(tt.src))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func([]byte) (map[string]string, error)
+		src   string
+		want  string
+	}{
+		{"yaml list", parseYAML, "server:\n  addr:\n    - a\n    - b\n", "server.addr: lists are not supported"},
+		{"yaml flow list", parseYAML, "server: {addr: [a]}\n", "server.addr: lists are not supported"},
+		{"yaml duplicate", parseYAML, "server:\n  addr: a\n  addr: b\n", "already defined"},
+		{"yaml dotted duplicate", parseYAML, "server.addr: a\nserver:\n  addr: b\n", "duplicate key server.addr"},
+		{"yaml tabs", parseYAML, "server:\n\taddr: a\n", "yaml: line"},
+		{"yaml scalar document", parseYAML, "just text\n", "cannot unmarshal"},
+		{"toml list", parseTOML, "[server]\naddr = [\"a\"]\n", "server.addr: lists are not supported"},
+		{"toml array of tables", parseTOML, "[[server]]\naddr = \"a\"\n", "server: lists are not supported"},
+		{"toml date", parseTOML, "[server]\naddr = 2024-01-01\n", "server.addr: unsupported value"},
+		{"toml unquoted duration", parseTOML, "[agent]\nretry_delay = 100ms\n", "line 2"},
+		{"toml duplicate", parseTOML, "[server]\naddr = \"a\"\naddr = \"b\"\n", "already been defined"},
+	}
+	for _, tt := range tests {
+		_, err := tt.parse([]byte(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
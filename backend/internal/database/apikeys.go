@@ -0,0 +1,74 @@
+package database
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"calcflow/backend/internal/task"
+)
+
+// Добавление ключа API в таблицу `APIKeys`
+func (s *Store) NewAPIKey(key *task.APIKey) error {
+	result := s.db.Create(key)
+	if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
+	return result.Error
+}
+
+// Получение ключа API по идентификатору
+func (s *Store) GetAPIKey(id string) (*task.APIKey, error) {
+	return s.findAPIKey("id = ?", id)
+}
+
+// Получение ключа API по хешу
+func (s *Store) GetAPIKeyByHash(hash string) (*task.APIKey, error) {
+	return s.findAPIKey("hash = ?", hash)
+}
+
+func (s *Store) findAPIKey(query string, args ...interface{}) (*task.APIKey, error) {
+	var key task.APIKey
+	result := s.db.Where(query, args...).First(&key)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &key, nil
+}
+
+// Получение всех ключей API в порядке создания
+func (s *Store) GetAPIKeys() ([]*task.APIKey, error) {
+	var keys []*task.APIKey
+	result := s.db.Order("created, id").Find(&keys)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return keys, nil
+}
+
+// Проверка наличия действующего ключа с областью доступа admin
+func (s *Store) HasAdminAPIKey() (bool, error) {
+	var keys []*task.APIKey
+	result := s.db.Where("revoked = ?", task.APIKey{}.Revoked).Find(&keys)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	for _, key := range keys {
+		if key.Scopes.Has(task.ScopeAdmin) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Обновление ключа API
+func (s *Store) UpdateAPIKey(key *task.APIKey) error {
+	result := s.db.Save(key)
+	if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
+	return result.Error
+}
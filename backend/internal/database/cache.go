@@ -0,0 +1,69 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"calcflow/backend/internal/task"
+)
+
+// Получение результата из таблицы `CachedResults` по ключу.
+// Устаревший результат удаляется, и возвращается ErrNotFound.
+func (s *Store) GetCachedResult(key string, now time.Time) (*task.CachedResult, error) {
+	var entry task.CachedResult
+	result := s.db.Where("key = ?", key).First(&entry)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if !entry.Expires.After(now) {
+		if err := s.db.Delete(&entry).Error; err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+
+	// Обновляем статистику использования для вытеснения давно не используемых результатов
+	entry.Hits++
+	entry.LastUsed = now
+	result = s.db.Model(&entry).Updates(map[string]interface{}{"hits": entry.Hits, "last_used": now})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &entry, nil
+}
+
+// Сохранение результата в таблицу `CachedResults`. Устаревшие результаты удаляются,
+// а при превышении maxSize вытесняются давно не использованные.
+func (s *Store) PutCachedResult(entry *task.CachedResult, maxSize int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(entry).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("expires <= ?", entry.Created).Delete(&task.CachedResult{}).Error; err != nil {
+			return err
+		}
+		if maxSize <= 0 {
+			return nil
+		}
+
+		keep := tx.Model(&task.CachedResult{}).Select("key").Order("last_used DESC").Limit(maxSize)
+		return tx.Where("key NOT IN (?)", keep).Delete(&task.CachedResult{}).Error
+	})
+}
+
+// Получение количества результатов в таблице `CachedResults`
+func (s *Store) CountCachedResults() (int64, error) {
+	var count int64
+	result := s.db.Model(&task.CachedResult{}).Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return count, nil
+}
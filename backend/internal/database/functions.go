@@ -0,0 +1,41 @@
+package database
+
+import (
+	"calcflow/backend/internal/task"
+)
+
+// Добавление новой версии пользовательской функции в таблицу `Functions`
+func (s *Store) NewFunction(function *task.Function) error {
+	result := s.db.Create(function)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// Получение последних версий всех функций арендатора из таблицы `Functions`
+func (s *Store) GetFunctions(tenant string) ([]*task.Function, error) {
+	var functions []*task.Function
+	latest := s.db.Model(&task.Function{}).
+		Select("name, MAX(version)").
+		Where("tenant = ?", tenant).
+		Group("name")
+	result := s.db.
+		Where("tenant = ? AND (name, version) IN (?)", tenant, latest).
+		Order("name").
+		Find(&functions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return functions, nil
+}
+
+// Получение всех версий функции арендатора по ее имени из таблицы `Functions`
+func (s *Store) GetFunctionVersions(tenant, name string) ([]*task.Function, error) {
+	var functions []*task.Function
+	result := s.db.Where("tenant = ? AND name = ?", tenant, name).Order("version").Find(&functions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return functions, nil
+}
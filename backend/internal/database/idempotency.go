@@ -0,0 +1,50 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"calcflow/backend/internal/task"
+)
+
+// Добавление ключа идемпотентности в таблицу `IdempotencyRecords`.
+// Если ключ уже есть, возвращается ErrDuplicate.
+func (s *Store) ReserveIdempotencyKey(record *task.IdempotencyRecord) error {
+	result := s.db.Create(record)
+	if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
+	return result.Error
+}
+
+// Получение ключа идемпотентности арендатора из таблицы `IdempotencyRecords`
+func (s *Store) GetIdempotencyRecord(tenant, key string) (*task.IdempotencyRecord, error) {
+	var record task.IdempotencyRecord
+	result := s.db.Where("tenant = ? AND key = ?", tenant, key).First(&record)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &record, nil
+}
+
+// Сохранение ответа на запрос с ключом идемпотентности
+func (s *Store) CompleteIdempotencyKey(record *task.IdempotencyRecord) error {
+	return s.db.Model(record).
+		Select("completed", "status_code", "content_type", "location", "body").
+		Updates(record).Error
+}
+
+// Удаление ключа идемпотентности, чтобы запрос можно было повторить
+func (s *Store) DeleteIdempotencyKey(tenant, key string) error {
+	return s.db.Where("tenant = ? AND key = ?", tenant, key).Delete(&task.IdempotencyRecord{}).Error
+}
+
+// Удаление ключей идемпотентности, созданных раньше before
+func (s *Store) DeleteExpiredIdempotencyKeys(before time.Time) error {
+	return s.db.Where("created < ?", before).Delete(&task.IdempotencyRecord{}).Error
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// SlowQueryThreshold - время выполнения запроса, начиная с которого он
+// записывается в журнал с уровнем WARN.
+const SlowQueryThreshold = 200 * time.Millisecond
+
+// gormLogger передает записи журнала gorm в журнал процесса slog. Ошибки запросов
+// записываются с уровнем ERROR, медленные запросы - с уровнем WARN, остальные
+// запросы - с уровнем DEBUG. Отсутствие записи и нарушение уникальности не
+// считаются ошибками: их обрабатывают вызывающие методы хранилища.
+type gormLogger struct{}
+
+func (l gormLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	slog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	slog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	slog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	expected := errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, gorm.ErrDuplicatedKey)
+
+	level := slog.LevelDebug
+	switch {
+	case err != nil && !expected:
+		level = slog.LevelError
+	case elapsed >= SlowQueryThreshold:
+		level = slog.LevelWarn
+	}
+	// Текст запроса формируется только для записей, которые попадут в журнал
+	if !slog.Default().Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Duration("duration", elapsed),
+	}
+	switch {
+	case level == slog.LevelError:
+		slog.LogAttrs(ctx, level, "query failed", append(attrs, slog.Any("error", err))...)
+	case level == slog.LevelWarn:
+		slog.LogAttrs(ctx, level, "slow query", attrs...)
+	default:
+		slog.LogAttrs(ctx, level, "query", attrs...)
+	}
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"log/slog"
+
+	"gorm.io/gorm"
+
+	"calcflow/backend/internal/task"
+)
+
+// dedupeTasks подготавливает таблицу `Tasks`, созданную прежними версиями,
+// к ограничениям уникальности идентификаторов задач и запросов. Из повторяющихся
+// записей остается созданная первой; трассировки удаленных задач удаляются.
+// Выполняется до AutoMigrate, который создает уникальный индекс.
+func dedupeTasks(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&task.Task{}) {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Задачи без идентификатора запроса получают идентификатор задачи
+		err := tx.Exec("UPDATE tasks SET request_id = id WHERE request_id IS NULL OR request_id = ''").Error
+		if err != nil {
+			return err
+		}
+
+		byID := tx.Exec("DELETE FROM tasks WHERE rowid NOT IN (SELECT MIN(rowid) FROM tasks GROUP BY id)")
+		if byID.Error != nil {
+			return byID.Error
+		}
+
+		// Время создания сравнивается через julianday: строки времени в разных
+		// часовых поясах нельзя сравнивать как строки
+		byRequestID := tx.Exec(`DELETE FROM tasks WHERE rowid IN (
+			SELECT rowid FROM (
+				SELECT rowid, ROW_NUMBER() OVER (PARTITION BY request_id ORDER BY julianday(created), rowid) AS n
+				FROM tasks)
+			WHERE n > 1)`)
+		if byRequestID.Error != nil {
+			return byRequestID.Error
+		}
+
+		if removed := byID.RowsAffected + byRequestID.RowsAffected; removed > 0 {
+			slog.Warn("removed duplicate tasks", "count", removed)
+			if tx.Migrator().HasTable(&task.TraceStep{}) {
+				err := tx.Exec("DELETE FROM trace_steps WHERE task_id NOT IN (SELECT id FROM tasks)").Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// Прежний неуникальный индекс заменяется уникальным с тем же именем
+		var unique []bool
+		err = tx.Raw(`SELECT "unique" FROM pragma_index_list('tasks') WHERE name = 'idx_tasks_request_id'`).Scan(&unique).Error
+		if err != nil {
+			return err
+		}
+		if len(unique) > 0 && !unique[0] {
+			return tx.Migrator().DropIndex(&task.Task{}, "idx_tasks_request_id")
+		}
+		return nil
+	})
+}
+
+// assignDefaultTenant относит задачи и пользователей, сохраненных до появления
+// арендаторов, к арендатору по умолчанию. Выполняется после AutoMigrate.
+func assignDefaultTenant(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&task.Task{}, &task.User{}} {
+			err := tx.Model(model).Where("tenant IS NULL OR tenant = ''").Update("tenant", task.DefaultTenant).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// dropUsernameIndex удаляет уникальный индекс имен пользователей, созданный прежними
+// версиями: имена уникальны только в пределах арендатора, и этот индекс не позволял
+// бы зарегистрировать одно имя в разных арендаторах. Выполняется после AutoMigrate.
+func dropUsernameIndex(db *gorm.DB) error {
+	if !db.Migrator().HasIndex(&task.User{}, "idx_users_username") {
+		return nil
+	}
+	return db.Migrator().DropIndex(&task.User{}, "idx_users_username")
+}
+
+// tenantCacheKeys переводит ключи кэша результатов, сохраненные до того, как ключи
+// стали включать арендатора: прежние результаты удаляются, а невычисленные задачи
+// получают ключ своего арендатора, чтобы после перезапуска снова присоединяться
+// только к задачам того же арендатора. Выполняется после AutoMigrate.
+func tenantCacheKeys(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("key NOT LIKE ?", `"%`).Delete(&task.CachedResult{}).Error
+		if err != nil {
+			return err
+		}
+
+		var pending []task.Task
+		err = tx.Select("id", "tenant", "cache_key").
+			Where("status = ? AND cache_key <> '' AND cache_key NOT LIKE ?", "pending", `"%`).
+			Find(&pending).Error
+		if err != nil {
+			return err
+		}
+		for _, t := range pending {
+			key := task.CacheKeyFor(t.Tenant, t.CacheKey)
+			if err := tx.Model(&task.Task{}).Where("id = ?", t.ID).Update("cache_key", key).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// utcTaskTimes переводит в UTC время задач, сохраненных прежними версиями
+// в локальном часовом поясе сервера: фильтры, сортировка и курсоры страниц
+// сравнивают время как строки. Выполняется после AutoMigrate.
+func utcTaskTimes(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var tasks []task.Task
+		err := tx.Select("id", "created", "finished").
+			Where("created NOT LIKE ? OR finished NOT LIKE ?", "%+00:00", "%+00:00").
+			Find(&tasks).Error
+		if err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			err := tx.Model(&task.Task{}).Where("id = ?", t.ID).
+				UpdateColumns(map[string]interface{}{"created": t.Created.UTC(), "finished": t.Finished.UTC()}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"calcflow/backend/internal/task"
+)
+
+func TestDedupeTasks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// Таблица прежней версии: индекс идентификаторов запросов не уникален,
+	// время создания записано в разных часовых поясах
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: gormLogger{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(`CREATE TABLE tasks (id text PRIMARY KEY, request_id text, expression text, status text, result text, created datetime)`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(`CREATE INDEX idx_tasks_request_id ON tasks(request_id)`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []struct{ id, requestID, created string }{
+		// Строкой это время меньше, но создана задача на два часа позже
+		{"later", "request-1", "2024-03-01 09:00:00+00:00"},
+		{"first", "request-1", "2024-03-01 10:00:00+03:00"},
+		{"same-1", "request-2", "2024-03-01 10:00:00+03:00"},
+		{"same-2", "request-2", "2024-03-01 07:00:00+00:00"},
+		{"single", "", "2024-03-01 07:00:00+00:00"},
+	}
+	for _, row := range rows {
+		err := db.Exec(`INSERT INTO tasks (id, request_id, expression, status, result, created) VALUES (?, ?, '1', 'completed', '1', ?)`,
+			row.id, row.requestID, row.created).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+
+	s, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var ids []string
+	if err := s.db.Model(&task.Task{}).Order("id").Pluck("id", &ids).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"first", "same-1", "single"}
+	if len(ids) != len(want) {
+		t.Fatalf("tasks %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("tasks %v, want %v", ids, want)
+		}
+	}
+
+	single, err := s.GetTaskByID("single")
+	if err != nil || single.ID != "single" {
+		t.Errorf("task without request ID: %v, %v", single, err)
+	}
+}
+
+func TestUTCTaskTimes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Задачи прежних версий сохранены в локальном часовом поясе сервера,
+	// который менялся между запусками
+	for _, row := range []struct{ id, created string }{
+		{"second", "2024-03-01 09:30:00+00:00"},
+		{"first", "2024-03-01 11:00:00+03:00"},
+		{"third", "2024-03-01 05:00:00-05:00"},
+	} {
+		err := s.db.Exec(`INSERT INTO tasks (id, request_id, status, created, finished) VALUES (?, ?, 'completed', ?, ?)`,
+			row.id, row.id, row.created, row.created).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	if s, err = New(path); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Конкатенация возвращает строку в том виде, в котором она хранится
+	var created []string
+	if err := s.db.Raw("SELECT created || '' FROM tasks ORDER BY created").Scan(&created).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2024-03-01 08:00:00+00:00", "2024-03-01 09:30:00+00:00", "2024-03-01 10:00:00+00:00"}
+	if fmt.Sprint(created) != fmt.Sprint(want) {
+		t.Errorf("created %v, want %v", created, want)
+	}
+	if got := listAll(t, s, TaskQuery{Limit: 1}); fmt.Sprint(got) != "[first second third]" {
+		t.Errorf("tasks %v, want [first second third]", got)
+	}
+}
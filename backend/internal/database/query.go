@@ -0,0 +1,183 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"calcflow/backend/internal/task"
+)
+
+// ErrInvalidQuery возвращается при некорректных параметрах выборки задач.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Поля, по которым можно сортировать задачи.
+const (
+	SortByCreated  = "created"
+	SortByFinished = "finished"
+	SortByDuration = "duration"
+)
+
+// DefaultPageSize - количество задач на странице, если оно не указано.
+const DefaultPageSize = 100
+
+// MaxPageSize - максимальное количество задач на странице.
+const MaxPageSize = 1000
+
+// TaskQuery задает фильтры, сортировку и страницу выборки задач.
+type TaskQuery struct {
+	Statuses        []string  // Допустимые статусы; пусто - любые
+	CreatedAfter    time.Time // Задачи, созданные не раньше этого времени
+	CreatedBefore   time.Time // Задачи, созданные раньше этого времени
+	RequestIDPrefix string    // Префикс идентификатора запроса
+	HasResult       *bool     // Наличие результата; nil - не важно
+	SortBy          string    // Поле сортировки, по умолчанию SortByCreated
+	Descending      bool      // Сортировка по убыванию
+	Limit           int       // Размер страницы, по умолчанию DefaultPageSize
+	Cursor          string    // Курсор, полученный вместе с предыдущей страницей
+	Owner           string    // Пользователь, добавивший задачи; пусто - любой
+	APIKeyID        string    // Ключ API, с которым добавлены задачи; пусто - любой
+	Tenant          string    // Арендатор задач; пусто - любой
+}
+
+// cursor указывает на последнюю задачу предыдущей страницы.
+type cursor struct {
+	SortBy string `json:"s"`
+	Value  string `json:"v"`
+	ID     string `json:"id"`
+}
+
+// Выборка страницы задач из таблицы `Tasks` с фильтрацией и сортировкой.
+// Возвращает курсор следующей страницы или пустую строку, если страница последняя.
+func (s *Store) ListTasks(query TaskQuery) ([]*task.Task, string, error) {
+	sortBy := query.SortBy
+	if sortBy == "" {
+		sortBy = SortByCreated
+	}
+	if sortBy != SortByCreated && sortBy != SortByFinished && sortBy != SortByDuration {
+		return nil, "", fmt.Errorf("%w: unknown sort field %q", ErrInvalidQuery, sortBy)
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return nil, "", fmt.Errorf("%w: limit must not exceed %d", ErrInvalidQuery, MaxPageSize)
+	}
+
+	db := s.db.Model(&task.Task{})
+	if query.Tenant != "" {
+		db = db.Where("tenant = ?", query.Tenant)
+	}
+	if query.Owner != "" {
+		db = db.Where("owner = ?", query.Owner)
+	}
+	if query.APIKeyID != "" {
+		db = db.Where("api_key_id = ?", query.APIKeyID)
+	}
+	if len(query.Statuses) > 0 {
+		db = db.Where("status IN ?", query.Statuses)
+	}
+	if !query.CreatedAfter.IsZero() {
+		db = db.Where("created >= ?", query.CreatedAfter.UTC())
+	}
+	if !query.CreatedBefore.IsZero() {
+		db = db.Where("created < ?", query.CreatedBefore.UTC())
+	}
+	if query.RequestIDPrefix != "" {
+		// Сравнение по диапазону вместо LIKE позволяет использовать индекс
+		db = db.Where("request_id >= ? AND request_id < ?", query.RequestIDPrefix, query.RequestIDPrefix+"\U0010FFFF")
+	}
+	if query.HasResult != nil {
+		if *query.HasResult {
+			db = db.Where("result <> ''")
+		} else {
+			db = db.Where("result = ''")
+		}
+	}
+
+	direction, compare := "ASC", ">"
+	if query.Descending {
+		direction, compare = "DESC", "<"
+	}
+
+	if query.Cursor != "" {
+		c, err := decodeCursor(query.Cursor)
+		if err != nil || c.SortBy != sortBy {
+			return nil, "", fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+		}
+		value, err := cursorValue(sortBy, c.Value)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+		}
+		db = db.Where(fmt.Sprintf("(%[1]s %[2]s ?) OR (%[1]s = ? AND id %[2]s ?)", sortBy, compare), value, value, c.ID)
+	}
+
+	var tasks []*task.Task
+	result := db.Order(sortBy + " " + direction).Order("id " + direction).Limit(limit + 1).Find(&tasks)
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+
+	if len(tasks) <= limit {
+		return tasks, "", nil
+	}
+	tasks = tasks[:limit]
+	next, err := encodeCursor(sortBy, tasks[limit-1])
+	if err != nil {
+		return nil, "", err
+	}
+	return tasks, next, nil
+}
+
+// encodeCursor кодирует позицию задачи в порядке сортировки.
+func encodeCursor(sortBy string, t *task.Task) (string, error) {
+	c := cursor{SortBy: sortBy, ID: t.ID}
+	switch sortBy {
+	case SortByCreated:
+		c.Value = t.Created.UTC().Format(time.RFC3339Nano)
+	case SortByFinished:
+		c.Value = t.Finished.UTC().Format(time.RFC3339Nano)
+	case SortByDuration:
+		c.Value = fmt.Sprintf("%d", int64(t.Duration))
+	}
+	bytes, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// decodeCursor декодирует курсор страницы.
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	bytes, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(bytes, &c)
+	return c, err
+}
+
+// cursorValue преобразует значение поля сортировки из курсора в значение для запроса.
+func cursorValue(sortBy, value string) (interface{}, error) {
+	if sortBy == SortByDuration {
+		var d int64
+		_, err := fmt.Sscanf(value, "%d", &d)
+		return d, err
+	}
+	// Время задач хранится в базе данных в UTC и сравнивается как строка,
+	// поэтому значение из курсора переводится в UTC
+	t, err := time.Parse(time.RFC3339Nano, value)
+	return t.UTC(), err
+}
+
+// utcTimes переводит время создания и окончания задачи в UTC. Время хранится
+// в базе данных строкой со смещением часового пояса, и строки сравниваются
+// в порядке времени, только если смещение у всех одно.
+func utcTimes(t *task.Task) {
+	t.Created = t.Created.UTC()
+	t.Finished = t.Finished.UTC()
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"calcflow/backend/internal/task"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// ids возвращает идентификаторы задач в порядке выборки.
+func ids(tasks []*task.Task) []string {
+	result := make([]string, len(tasks))
+	for i, t := range tasks {
+		result[i] = t.ID
+	}
+	return result
+}
+
+// listAll выбирает все страницы задач с размером страницы limit.
+func listAll(t *testing.T, s *Store, query TaskQuery) []string {
+	t.Helper()
+	var all []string
+	for page := 0; ; page++ {
+		if page > 100 {
+			t.Fatal("pagination does not end")
+		}
+		tasks, next, err := s.ListTasks(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, ids(tasks)...)
+		if next == "" {
+			return all
+		}
+		query.Cursor = next
+	}
+}
+
+func TestListTasksPagination(t *testing.T) {
+	s := newTestStore(t)
+
+	// Время задач задано в разных часовых поясах: порядок строк с разным
+	// смещением не совпадает с порядком времени
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	zones := []*time.Location{time.UTC, time.FixedZone("UTC+3", 3*3600), time.FixedZone("UTC-5", -5*3600)}
+	var want []string
+	for i := 0; i < 10; i++ {
+		created := base.Add(time.Duration(i) * time.Minute)
+		if i == 5 {
+			created = base.Add(4 * time.Minute) // Одинаковое время упорядочивается по идентификатору
+		}
+		id := fmt.Sprintf("task-%02d", i)
+		want = append(want, id)
+		err := s.NewTask(&task.Task{
+			ID:        id,
+			RequestID: id,
+			Status:    "completed",
+			Created:   created.In(zones[i%len(zones)]),
+			Finished:  created.Add(time.Second).In(zones[(i+1)%len(zones)]),
+			Duration:  time.Duration(10-i) * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	reversed := make([]string, len(want))
+	for i, id := range want {
+		reversed[len(want)-1-i] = id
+	}
+
+	tests := []struct {
+		name  string
+		query TaskQuery
+		want  []string
+	}{
+		{"created", TaskQuery{Limit: 3}, want},
+		{"created descending", TaskQuery{Limit: 3, Descending: true}, reversed},
+		{"finished", TaskQuery{Limit: 4, SortBy: SortByFinished}, want},
+		{"duration", TaskQuery{Limit: 2, SortBy: SortByDuration}, reversed},
+		{"one page", TaskQuery{Limit: 10}, want},
+		{"created after", TaskQuery{Limit: 3, CreatedAfter: base.Add(7 * time.Minute).In(zones[1])}, want[7:]},
+		{"created before", TaskQuery{Limit: 3, CreatedBefore: base.Add(2 * time.Minute).In(zones[2])}, want[:2]},
+	}
+	for _, tt := range tests {
+		if got := listAll(t, s, tt.query); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	_, next, err := s.ListTasks(TaskQuery{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := []TaskQuery{
+		{Cursor: "not a cursor"},
+		{Cursor: next, SortBy: SortByDuration},
+		{SortBy: "expression"},
+		{Limit: MaxPageSize + 1},
+	}
+	for _, query := range invalid {
+		if _, _, err := s.ListTasks(query); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("ListTasks(%+v): %v, want ErrInvalidQuery", query, err)
+		}
+	}
+}
+
+func TestListTasksFilters(t *testing.T) {
+	s := newTestStore(t)
+	now := time.Now()
+	for _, tt := range []task.Task{
+		{ID: "a", RequestID: "import-1", Status: "completed", Result: "1", Tenant: "default", Owner: "alice"},
+		{ID: "b", RequestID: "import-2", Status: "pending", Tenant: "default", APIKeyID: "key-1"},
+		{ID: "c", RequestID: "manual-1", Status: "error", Tenant: "default", APIKeyID: "key-1"},
+		{ID: "d", RequestID: "import-3", Status: "completed", Result: "4", Tenant: "acme"},
+	} {
+		tt := tt
+		tt.Created = now
+		if err := s.NewTask(&tt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	yes, no := true, false
+	tests := []struct {
+		name  string
+		query TaskQuery
+		want  []string
+	}{
+		{"all", TaskQuery{}, []string{"a", "b", "c", "d"}},
+		{"statuses", TaskQuery{Statuses: []string{"pending", "error"}}, []string{"b", "c"}},
+		{"request ID prefix", TaskQuery{RequestIDPrefix: "import-"}, []string{"a", "b", "d"}},
+		{"with result", TaskQuery{HasResult: &yes}, []string{"a", "d"}},
+		{"without result", TaskQuery{HasResult: &no}, []string{"b", "c"}},
+		{"tenant", TaskQuery{Tenant: "acme"}, []string{"d"}},
+		{"owner", TaskQuery{Tenant: "default", Owner: "alice"}, []string{"a"}},
+		{"API key", TaskQuery{Tenant: "default", APIKeyID: "key-1"}, []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		if got := listAll(t, s, tt.query); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
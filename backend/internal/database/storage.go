@@ -1,30 +1,54 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"calcflow/backend/internal/task"
 )
 
+// ErrNotFound возвращается, если запрошенная запись отсутствует в базе данных.
+var ErrNotFound = errors.New("not found")
+
+// ErrDuplicate возвращается, если запись нарушает ограничение уникальности.
+var ErrDuplicate = errors.New("duplicate key")
+
 type Store struct {
 	db *gorm.DB
 }
 
 // Создание сущности базы данных
 func New(path string) (*Store, error) {
-	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{TranslateError: true, Logger: gormLogger{}})
 	if err != nil {
 		return nil, fmt.Errorf("can't open database: %v", err)
 	}
 
 	// Выполните миграцию таблицы, если это необходимо
-	err = db.AutoMigrate(&task.Task{})
+	if err := dedupeTasks(db); err != nil {
+		return nil, fmt.Errorf("can't deduplicate tasks: %v", err)
+	}
+	err = db.AutoMigrate(&task.Task{}, &task.Function{}, &task.OperationTiming{}, &task.TraceStep{}, &task.CachedResult{}, &task.IdempotencyRecord{}, &task.APIKey{}, &task.User{}, &task.Tenant{}, &task.Usage{})
 	if err != nil {
 		return nil, fmt.Errorf("can't migrate database: %v", err)
 	}
+	if err := assignDefaultTenant(db); err != nil {
+		return nil, fmt.Errorf("can't assign default tenant: %v", err)
+	}
+	if err := dropUsernameIndex(db); err != nil {
+		return nil, fmt.Errorf("can't migrate users: %v", err)
+	}
+	if err := tenantCacheKeys(db); err != nil {
+		return nil, fmt.Errorf("can't migrate cache keys: %v", err)
+	}
+	if err := utcTaskTimes(db); err != nil {
+		return nil, fmt.Errorf("can't migrate task times: %v", err)
+	}
 
 	return &Store{db: db}, nil
 }
@@ -42,6 +66,11 @@ func (s *Store) Close() error {
 	return nil
 }
 
+// Проверка соединения с базой данных простым запросом
+func (s *Store) Ping(ctx context.Context) error {
+	return s.db.WithContext(ctx).Exec("SELECT 1").Error
+}
+
 // Создание необходимых таблиц
 func (s *Store) CreateTables() error {
 	// Создание таблицы Tasks
@@ -50,8 +79,8 @@ func (s *Store) CreateTables() error {
 		return err
 	}
 
-	// Создание таблицы CalculationRequest
-	err = s.db.AutoMigrate(&task.CalculationRequest{})
+	// Создание таблицы OperationTimings
+	err = s.db.AutoMigrate(&task.OperationTiming{})
 	if err != nil {
 		return err
 	}
@@ -60,8 +89,13 @@ func (s *Store) CreateTables() error {
 }
 
 // Добавление новой задачи в таблицу `Tasks`
+// Если задача с таким идентификатором или requestID уже есть, возвращается ErrDuplicate.
 func (s *Store) NewTask(task *task.Task) error {
+	utcTimes(task)
 	result := s.db.Create(task)
+	if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
 	if result.Error != nil {
 		return result.Error
 	}
@@ -72,45 +106,65 @@ func (s *Store) NewTask(task *task.Task) error {
 func (s *Store) GetTaskByID(requestID string) (*task.Task, error) {
 	var task task.Task
 	result := s.db.Where("request_id = ?", requestID).First(&task)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return &task, nil
 }
 
-// Получение всех задач из таблицы `Tasks`
-func (s *Store) GetAllTasks() ([]*task.Task, error) {
-	var tasks []*task.Task
-	result := s.db.Find(&tasks)
+// Получение задачи по ее идентификатору из таблицы `Tasks`
+func (s *Store) GetTask(taskID string) (*task.Task, error) {
+	var task task.Task
+	result := s.db.Where("id = ?", taskID).First(&task)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
 	if result.Error != nil {
 		return nil, result.Error
 	}
-	return tasks, nil
+	return &task, nil
 }
 
-// Проверка на существование выражения с таким requestID
-func (s *Store) AlreadyExistsRequest(requestID string) (bool, error) {
-	var count int64
-	result := s.db.Model(&task.Task{}).Where("request_id = ?", requestID).Count(&count)
+// Получение невычисленных задач из таблицы `Tasks` в порядке их создания
+func (s *Store) GetPendingTasks() ([]*task.Task, error) {
+	var tasks []*task.Task
+	result := s.db.Where("status = ?", "pending").Order("created, id").Find(&tasks)
 	if result.Error != nil {
-		return false, result.Error
+		return nil, result.Error
 	}
-	return count > 0, nil
+	return tasks, nil
 }
 
-// Получение времени выполнения каждой операции из таблицы `CalculationRequest`
-func (s *Store) GetCalculateTime() (*task.CalculationRequest, error) {
-	var calcRequest task.CalculationRequest
-	result := s.db.First(&calcRequest)
+// Получение времени выполнения операций из таблицы `OperationTimings`
+func (s *Store) GetCalculateTime() (task.CalculationRequest, error) {
+	var timings []task.OperationTiming
+	result := s.db.Find(&timings)
 	if result.Error != nil {
 		return nil, result.Error
 	}
-	return &calcRequest, nil
+
+	calcRequest := make(task.CalculationRequest, len(timings))
+	for _, timing := range timings {
+		calcRequest[timing.Operation] = timing.Duration
+	}
+	return calcRequest, nil
 }
 
-// Обновление значений времени выполнения для каждой операции
+// Обновление значений времени выполнения для переданных операций
 func (s *Store) UpdateCalculateTime(request task.CalculationRequest) error {
-	result := s.db.Save(&request)
+	if len(request) == 0 {
+		return nil
+	}
+
+	timings := make([]task.OperationTiming, 0, len(request))
+	for operation, duration := range request {
+		timings = append(timings, task.OperationTiming{Operation: operation, Duration: duration})
+	}
+
+	result := s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&timings)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -119,6 +173,7 @@ func (s *Store) UpdateCalculateTime(request task.CalculationRequest) error {
 
 // Обновление данных задачи в таблице `Tasks` после того, как выражение будет посчитано
 func (s *Store) UpdateTask(task *task.Task) error {
+	utcTimes(task)
 	result := s.db.Save(task)
 	if result.Error != nil {
 		return result.Error
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"calcflow/backend/internal/task"
+)
+
+// Получение настроек всех арендаторов из таблицы `Tenants`
+func (s *Store) GetTenants() ([]*task.Tenant, error) {
+	var tenants []*task.Tenant
+	result := s.db.Order("id").Find(&tenants)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return tenants, nil
+}
+
+// Сохранение настроек арендатора
+func (s *Store) SaveTenant(tenant *task.Tenant) error {
+	result := s.db.Save(tenant)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// Подсчет невычисленных задач арендатора
+func (s *Store) CountPendingTasks(tenant string) (int64, error) {
+	var count int64
+	result := s.db.Model(&task.Task{}).Where("tenant = ? AND status = ?", tenant, "pending").Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return count, nil
+}
+
+// Подсчет пользовательских функций арендатора без учета версий
+func (s *Store) CountFunctions(tenant string) (int64, error) {
+	var count int64
+	result := s.db.Model(&task.Function{}).Where("tenant = ?", tenant).Distinct("name").Count(&count)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return count, nil
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"calcflow/backend/internal/task"
+)
+
+// Сохранение трассировки вычисления задачи в таблицу `TraceSteps`
+func (s *Store) SaveTrace(taskID string, steps []task.TraceStep) error {
+	if len(steps) == 0 {
+		return nil
+	}
+	for i := range steps {
+		steps[i].TaskID = taskID
+	}
+	result := s.db.Create(&steps)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// Получение трассировки вычисления задачи из таблицы `TraceSteps`
+func (s *Store) GetTrace(taskID string) ([]task.TraceStep, error) {
+	var steps []task.TraceStep
+	result := s.db.Where("task_id = ?", taskID).Order("step").Find(&steps)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return steps, nil
+}
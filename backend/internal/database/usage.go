@@ -0,0 +1,40 @@
+package database
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"calcflow/backend/internal/task"
+)
+
+// Получение расхода квоты клиента за сутки; если клиент ничего не добавлял,
+// возвращается пустой расход
+func (s *Store) GetUsage(client, day string) (*task.Usage, error) {
+	usage := &task.Usage{Client: client, Day: day}
+	result := s.db.Where("client = ? AND day = ?", client, day).Take(usage)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return usage, nil
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return usage, nil
+}
+
+// Добавление расхода к квоте клиента за сутки
+func (s *Store) AddUsage(usage *task.Usage) error {
+	result := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "client"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"expressions": gorm.Expr("expressions + ?", usage.Expressions),
+			"operations":  gorm.Expr("operations + ?", usage.Operations),
+			"cost":        gorm.Expr("cost + ?", usage.Cost),
+		}),
+	}).Create(usage)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package database
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"calcflow/backend/internal/task"
+)
+
+// Добавление пользователя в таблицу `Users`
+func (s *Store) NewUser(user *task.User) error {
+	result := s.db.Create(user)
+	if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+		return ErrDuplicate
+	}
+	return result.Error
+}
+
+// Получение пользователя по идентификатору
+func (s *Store) GetUser(id string) (*task.User, error) {
+	return s.findUser("id = ?", id)
+}
+
+// Получение пользователя арендатора по имени
+func (s *Store) GetUserByName(tenant, username string) (*task.User, error) {
+	return s.findUser("tenant = ? AND username = ?", tenant, username)
+}
+
+func (s *Store) findUser(query string, args ...interface{}) (*task.User, error) {
+	var user task.User
+	result := s.db.Where(query, args...).First(&user)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &user, nil
+}
@@ -0,0 +1,118 @@
+package expr
+
+import (
+	"strings"
+
+	"calcflow/backend/internal/operation"
+)
+
+// Node представляет узел синтаксического дерева выражения.
+type Node interface {
+	String() string
+}
+
+// Number представляет числовой литерал.
+type Number struct {
+	Value   float64
+	Literal string
+}
+
+// Ident представляет имя переменной (параметра функции).
+type Ident struct {
+	Name string
+}
+
+// Unary представляет унарную операцию, например, смену знака.
+type Unary struct {
+	Op string
+	X  Node
+}
+
+// Binary представляет бинарную арифметическую операцию.
+type Binary struct {
+	Op    string
+	Left  Node
+	Right Node
+}
+
+// Call представляет вызов пользовательской функции.
+type Call struct {
+	Name string
+	Args []Node
+}
+
+func (n *Number) String() string { return n.Literal }
+
+func (n *Ident) String() string { return n.Name }
+
+func (n *Unary) String() string {
+	switch n.X.(type) {
+	case *Number, *Ident, *Call:
+		return n.Op + n.X.String()
+	}
+	return n.Op + "(" + n.X.String() + ")"
+}
+
+func (n *Binary) String() string {
+	op, _ := operation.Default.Binary(n.Op)
+	left := n.Left.String()
+	if needParens(n.Left, op, false) {
+		left = "(" + left + ")"
+	}
+	right := n.Right.String()
+	if needParens(n.Right, op, true) {
+		right = "(" + right + ")"
+	}
+	return left + " " + n.Op + " " + right
+}
+
+func (n *Call) String() string {
+	args := make([]string, len(n.Args))
+	for i, arg := range n.Args {
+		args[i] = arg.String()
+	}
+	return n.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// needParens сообщает, нужно ли заключить операнд бинарной операции в скобки
+// при выводе выражения в строку.
+func needParens(child Node, parent *operation.Operation, right bool) bool {
+	var op *operation.Operation
+	switch child := child.(type) {
+	case *Binary:
+		op, _ = operation.Default.Binary(child.Op)
+	case *Unary:
+		op, _ = operation.Default.Unary(child.Op)
+	default:
+		return false
+	}
+	if op == nil || parent == nil {
+		return true
+	}
+	if op.Precedence != parent.Precedence {
+		return op.Precedence < parent.Precedence
+	}
+	if op.Arity == 1 {
+		return !right
+	}
+	if parent.RightAssoc {
+		return !right
+	}
+	return right
+}
+
+// Walk обходит дерево выражения в глубину, вызывая fn для каждого узла.
+func Walk(n Node, fn func(Node)) {
+	fn(n)
+	switch n := n.(type) {
+	case *Unary:
+		Walk(n.X, fn)
+	case *Binary:
+		Walk(n.Left, fn)
+		Walk(n.Right, fn)
+	case *Call:
+		for _, arg := range n.Args {
+			Walk(arg, fn)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package expr
+
+import (
+	"strconv"
+
+	"calcflow/backend/internal/operation"
+)
+
+// Canonical возвращает нормализованную запись выражения: без лишних пробелов,
+// с единообразной записью чисел и упорядоченными операндами коммутативных операций.
+// Выражения с одинаковой нормализованной записью дают одинаковый результат.
+func Canonical(n Node) string {
+	return canonicalize(n).String()
+}
+
+// canonicalize строит нормализованную копию дерева выражения.
+func canonicalize(n Node) Node {
+	switch n := n.(type) {
+	case *Number:
+		return &Number{Value: n.Value, Literal: strconv.FormatFloat(n.Value, 'g', -1, 64)}
+	case *Unary:
+		return &Unary{Op: canonicalSymbol(n.Op, 1), X: canonicalize(n.X)}
+	case *Binary:
+		left, right := canonicalize(n.Left), canonicalize(n.Right)
+		if op, ok := operation.Default.Binary(n.Op); ok && op.Commutative && left.String() > right.String() {
+			left, right = right, left
+		}
+		return &Binary{Op: canonicalSymbol(n.Op, 2), Left: left, Right: right}
+	case *Call:
+		call := &Call{Name: n.Name, Args: make([]Node, len(n.Args))}
+		for i, arg := range n.Args {
+			call.Args[i] = canonicalize(arg)
+		}
+		return call
+	}
+	return n
+}
+
+// canonicalSymbol возвращает основной символ операции, например "^" вместо "**".
+func canonicalSymbol(symbol string, arity int) string {
+	op, ok := operation.Default.Binary(symbol)
+	if arity == 1 {
+		op, ok = operation.Default.Unary(symbol)
+	}
+	if !ok {
+		return symbol
+	}
+	return op.Symbols[0]
+}
@@ -0,0 +1,44 @@
+package expr
+
+import (
+	"fmt"
+
+	"calcflow/backend/internal/operation"
+)
+
+// Eval вычисляет значение выражения без имитации времени выполнения операций.
+func Eval(n Node) (float64, error) {
+	switch n := n.(type) {
+	case *Number:
+		return n.Value, nil
+	case *Unary:
+		op, ok := operation.Default.Unary(n.Op)
+		if !ok {
+			return 0, fmt.Errorf("unsupported operator %q", n.Op)
+		}
+		x, err := Eval(n.X)
+		if err != nil {
+			return 0, err
+		}
+		return op.Apply(x)
+	case *Binary:
+		op, ok := operation.Default.Binary(n.Op)
+		if !ok {
+			return 0, fmt.Errorf("unsupported operator %q", n.Op)
+		}
+		left, err := Eval(n.Left)
+		if err != nil {
+			return 0, err
+		}
+		right, err := Eval(n.Right)
+		if err != nil {
+			return 0, err
+		}
+		return op.Apply(left, right)
+	case *Ident:
+		return 0, fmt.Errorf("unknown variable %q", n.Name)
+	case *Call:
+		return 0, fmt.Errorf("function %q is not expanded", n.Name)
+	}
+	return 0, fmt.Errorf("unsupported node %T", n)
+}
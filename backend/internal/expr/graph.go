@@ -0,0 +1,172 @@
+package expr
+
+import (
+	"fmt"
+	"time"
+
+	"calcflow/backend/internal/operation"
+)
+
+// GraphNode представляет узел графа операций: константу или операцию над
+// результатами других узлов.
+type GraphNode struct {
+	ID     int                  // Индекс узла в Graph.Nodes
+	Key    string               // Нормализованная запись подвыражения
+	Op     *operation.Operation // Операция; nil для констант
+	Value  float64              // Значение константы
+	Inputs []int                // Узлы, результаты которых являются операндами
+	Expr   Node                 // Подвыражение, соответствующее узлу
+}
+
+// Graph представляет выражение в виде ациклического графа операций, в котором
+// одинаковые подвыражения объединены в один узел. Узлы упорядочены так,
+// что операнды всегда предшествуют использующим их операциям.
+type Graph struct {
+	Nodes []*GraphNode
+	Root  int
+}
+
+// BuildGraph строит граф операций выражения, устраняя общие подвыражения.
+// Выражение не должно содержать переменных и вызовов функций.
+func BuildGraph(n Node) (*Graph, error) {
+	g := &Graph{}
+	byKey := make(map[string]int)
+
+	var build func(n Node) (int, error)
+	build = func(n Node) (int, error) {
+		key := Canonical(n)
+		if id, ok := byKey[key]; ok {
+			return id, nil
+		}
+
+		node := &GraphNode{Key: key, Expr: n}
+		switch n := n.(type) {
+		case *Number:
+			node.Value = n.Value
+		case *Unary:
+			op, ok := operation.Default.Unary(n.Op)
+			if !ok {
+				return 0, fmt.Errorf("unsupported operator %q", n.Op)
+			}
+			x, err := build(n.X)
+			if err != nil {
+				return 0, err
+			}
+			node.Op, node.Inputs = op, []int{x}
+		case *Binary:
+			op, ok := operation.Default.Binary(n.Op)
+			if !ok {
+				return 0, fmt.Errorf("unsupported operator %q", n.Op)
+			}
+			left, err := build(n.Left)
+			if err != nil {
+				return 0, err
+			}
+			right, err := build(n.Right)
+			if err != nil {
+				return 0, err
+			}
+			node.Op, node.Inputs = op, []int{left, right}
+		case *Ident:
+			return 0, fmt.Errorf("unknown variable %q", n.Name)
+		case *Call:
+			return 0, fmt.Errorf("function %q is not expanded", n.Name)
+		default:
+			return 0, fmt.Errorf("unsupported node %T", n)
+		}
+
+		node.ID = len(g.Nodes)
+		g.Nodes = append(g.Nodes, node)
+		byKey[key] = node.ID
+		return node.ID, nil
+	}
+
+	root, err := build(n)
+	if err != nil {
+		return nil, err
+	}
+	g.Root = root
+	return g, nil
+}
+
+// Keys возвращает нормализованные записи всех операций графа.
+func (g *Graph) Keys() []string {
+	var keys []string
+	for _, node := range g.Nodes {
+		if node.Op != nil {
+			keys = append(keys, node.Key)
+		}
+	}
+	return keys
+}
+
+// Estimate оценивает суммарное время вычисления графа, где каждая
+// операция выполняется один раз. cost возвращает время выполнения операции.
+func (g *Graph) Estimate(cost func(op *operation.Operation) time.Duration) time.Duration {
+	var total time.Duration
+	for _, node := range g.Nodes {
+		if node.Op != nil {
+			total += cost(node.Op)
+		}
+	}
+	return total
+}
+
+// GraphStep описывает одну операцию, выполненную при вычислении графа.
+type GraphStep struct {
+	Node     *GraphNode
+	Operands []float64
+	Result   float64
+	Shared   bool // Результат получен из общей таблицы, а не вычислен заново
+}
+
+// GraphEvaluator вычисляет графы операций.
+type GraphEvaluator struct {
+	// Memo - общая таблица результатов операций; если задана, одинаковые
+	// операции выполняются один раз для всех использующих ее вычислений.
+	Memo *Memo
+	// Before вызывается перед выполнением каждой операции, если задан.
+	Before func(op *operation.Operation)
+	// After вызывается после успешного выполнения каждой операции, если задан.
+	After func(step GraphStep)
+}
+
+// Eval вычисляет значение графа операций.
+func (e *GraphEvaluator) Eval(g *Graph) (float64, error) {
+	values := make([]float64, len(g.Nodes))
+	for _, node := range g.Nodes {
+		if node.Op == nil {
+			values[node.ID] = node.Value
+			continue
+		}
+
+		operands := make([]float64, len(node.Inputs))
+		for i, input := range node.Inputs {
+			operands[i] = values[input]
+		}
+		compute := func() (float64, error) {
+			if e.Before != nil {
+				e.Before(node.Op)
+			}
+			return node.Op.Apply(operands...)
+		}
+
+		var result float64
+		var shared bool
+		var err error
+		if e.Memo != nil {
+			result, shared, err = e.Memo.Do(node.Key, compute)
+		} else {
+			result, err = compute()
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		values[node.ID] = result
+		if e.After != nil {
+			e.After(GraphStep{Node: node, Operands: operands, Result: result, Shared: shared})
+		}
+	}
+	return values[g.Root], nil
+}
@@ -0,0 +1,199 @@
+package expr
+
+import "fmt"
+
+// Definition описывает пользовательскую функцию, доступную в выражениях.
+type Definition struct {
+	Name   string
+	Params []string
+	Body   Node
+}
+
+// MaxInlineNodes - наибольшее число узлов выражения после раскрытия функций.
+// Вложенные вызовы увеличивают выражение экспоненциально: если f1(x) = x + x
+// и fn(x) = fn-1(x) + fn-1(x), то fn(1) раскрывается в 2^(n+1) - 1 узлов.
+const MaxInlineNodes = 10000
+
+// ErrTooLarge возвращается Inline, если раскрытое выражение больше MaxInlineNodes узлов.
+var ErrTooLarge = fmt.Errorf("expression exceeds %d nodes after inlining functions", MaxInlineNodes)
+
+// Inline раскрывает вызовы пользовательских функций, подставляя их тела
+// с аргументами вместо параметров. lookup возвращает определение по имени.
+// Определения не должны образовывать циклов, см. FindCycle. Если раскрытое
+// выражение больше MaxInlineNodes узлов, возвращается ErrTooLarge.
+func Inline(n Node, lookup func(name string) (*Definition, bool)) (Node, error) {
+	inlined, err := inline(n, lookup)
+	if err != nil {
+		return nil, err
+	}
+	if countNodes(inlined, MaxInlineNodes) > MaxInlineNodes {
+		return nil, ErrTooLarge
+	}
+	return inlined, nil
+}
+
+func inline(n Node, lookup func(name string) (*Definition, bool)) (Node, error) {
+	switch n := n.(type) {
+	case *Unary:
+		x, err := inline(n.X, lookup)
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: n.Op, X: x}, nil
+	case *Binary:
+		left, err := inline(n.Left, lookup)
+		if err != nil {
+			return nil, err
+		}
+		right, err := inline(n.Right, lookup)
+		if err != nil {
+			return nil, err
+		}
+		return &Binary{Op: n.Op, Left: left, Right: right}, nil
+	case *Call:
+		def, ok := lookup(n.Name)
+		if !ok {
+			return nil, fmt.Errorf("unknown function %q", n.Name)
+		}
+		if len(n.Args) != len(def.Params) {
+			return nil, fmt.Errorf("function %q expects %d arguments, got %d", n.Name, len(def.Params), len(n.Args))
+		}
+		args := make(map[string]Node, len(n.Args))
+		for i, arg := range n.Args {
+			inlined, err := inline(arg, lookup)
+			if err != nil {
+				return nil, err
+			}
+			args[def.Params[i]] = inlined
+		}
+		body, err := inline(def.Body, lookup)
+		if err != nil {
+			return nil, err
+		}
+		// Размер проверяется после каждого вызова, чтобы не раскрывать
+		// экспоненциально растущие вызовы до конца
+		result := substitute(body, args)
+		if countNodes(result, MaxInlineNodes) > MaxInlineNodes {
+			return nil, ErrTooLarge
+		}
+		return result, nil
+	}
+	return n, nil
+}
+
+// countNodes возвращает число узлов дерева n, но не больше limit+1: подсчет
+// прекращается, как только узлов становится больше limit. Узлы, на которые
+// после подстановки ссылаются несколько родителей, считаются каждый раз.
+func countNodes(n Node, limit int) int {
+	count := 0
+	var visit func(n Node)
+	visit = func(n Node) {
+		if count > limit {
+			return
+		}
+		count++
+		switch n := n.(type) {
+		case *Unary:
+			visit(n.X)
+		case *Binary:
+			visit(n.Left)
+			visit(n.Right)
+		case *Call:
+			for _, arg := range n.Args {
+				visit(arg)
+			}
+		}
+	}
+	visit(n)
+	return count
+}
+
+// substitute заменяет имена параметров на переданные узлы.
+func substitute(n Node, args map[string]Node) Node {
+	switch n := n.(type) {
+	case *Ident:
+		if arg, ok := args[n.Name]; ok {
+			return arg
+		}
+	case *Unary:
+		return &Unary{Op: n.Op, X: substitute(n.X, args)}
+	case *Binary:
+		return &Binary{Op: n.Op, Left: substitute(n.Left, args), Right: substitute(n.Right, args)}
+	case *Call:
+		call := &Call{Name: n.Name, Args: make([]Node, len(n.Args))}
+		for i, arg := range n.Args {
+			call.Args[i] = substitute(arg, args)
+		}
+		return call
+	}
+	return n
+}
+
+// Calls возвращает имена функций, вызываемых в выражении, без повторов.
+func Calls(n Node) []string {
+	var names []string
+	seen := make(map[string]bool)
+	Walk(n, func(n Node) {
+		if call, ok := n.(*Call); ok && !seen[call.Name] {
+			seen[call.Name] = true
+			names = append(names, call.Name)
+		}
+	})
+	return names
+}
+
+// Idents возвращает имена переменных, используемых в выражении, без повторов.
+func Idents(n Node) []string {
+	var names []string
+	seen := make(map[string]bool)
+	Walk(n, func(n Node) {
+		if ident, ok := n.(*Ident); ok && !seen[ident.Name] {
+			seen[ident.Name] = true
+			names = append(names, ident.Name)
+		}
+	})
+	return names
+}
+
+// FindCycle ищет цикл в графе вызовов функций, где deps[name] содержит
+// имена функций, вызываемых из name. Возвращает путь цикла или nil.
+func FindCycle(deps map[string][]string) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(deps))
+	var path []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			for i, n := range path {
+				if n == name {
+					return append(append([]string{}, path[i:]...), name)
+				}
+			}
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range deps[name] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+
+	for name := range deps {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
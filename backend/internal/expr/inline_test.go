@@ -0,0 +1,133 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// definitions разбирает определения функций вида `f(x) = x + 1`.
+func definitions(t *testing.T, sources ...string) func(string) (*Definition, bool) {
+	t.Helper()
+	defs := make(map[string]*Definition, len(sources))
+	for _, src := range sources {
+		name, params, body, err := ParseDefinition(src)
+		if err != nil {
+			t.Fatalf("ParseDefinition(%q): %v", src, err)
+		}
+		defs[name] = &Definition{Name: name, Params: params, Body: body}
+	}
+	return func(name string) (*Definition, bool) {
+		def, ok := defs[name]
+		return def, ok
+	}
+}
+
+// doubling возвращает функции f1..fn, каждая из которых вдвое больше предыдущей.
+func doubling(n int) []string {
+	sources := []string{"f1(x) = x + x"}
+	for i := 2; i <= n; i++ {
+		sources = append(sources, fmt.Sprintf("f%d(x) = f%d(x) + f%d(x)", i, i-1, i-1))
+	}
+	return sources
+}
+
+func TestInline(t *testing.T) {
+	lookup := definitions(t, "sq(x) = x * x", "add(a, b) = a + b", "hyp(a, b) = add(sq(a), sq(b))")
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"1 + 2", "1 + 2"},
+		{"sq(3)", "3 * 3"},
+		{"sq(1 + 2)", "(1 + 2) * (1 + 2)"},
+		{"-sq(2)", "-(2 * 2)"},
+		{"hyp(3, 4)", "3 * 3 + 4 * 4"},
+		{"add(1, 2) * 3", "(1 + 2) * 3"},
+	}
+	for _, tt := range tests {
+		node, err := Parse(tt.src)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.src, err)
+		}
+		inlined, err := Inline(node, lookup)
+		if err != nil {
+			t.Errorf("Inline(%q): %v", tt.src, err)
+			continue
+		}
+		if got := inlined.String(); got != tt.want {
+			t.Errorf("Inline(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestInlineErrors(t *testing.T) {
+	lookup := definitions(t, "sq(x) = x * x")
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"cube(2)", `unknown function "cube"`},
+		{"sq(1, 2)", `function "sq" expects 1 arguments, got 2`},
+		{"1 + sq()", `function "sq" expects 1 arguments, got 0`},
+	}
+	for _, tt := range tests {
+		node, err := Parse(tt.src)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.src, err)
+		}
+		_, err = Inline(node, lookup)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Inline(%q): %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestInlineLimit(t *testing.T) {
+	// fn(1) раскрывается в 2^(n+1) - 1 узлов: f12 - в 8191, f13 - в 16383
+	// gn(1) раскрывается в 2^(2^n + 1) - 1 узлов: g3 - в 31, g4 - в 511, g5 - в 131071
+	sources := append(doubling(40), "g1(x) = x + x", "g2(x) = g1(g1(x))", "g3(x) = g2(g2(x))",
+		"g4(x) = g3(g3(x))", "g5(x) = g4(g4(x))", "g6(x) = g5(g5(x))")
+	lookup := definitions(t, sources...)
+
+	tests := []struct {
+		src     string
+		tooLong bool
+	}{
+		{"f12(1)", false},
+		{"f13(1)", true},
+		{"f12(1) + f12(1)", true},
+		{"f11(f1(1))", false},
+		{"f12(f1(1))", true},
+		// Без ограничения раскрытие заняло бы 2^40 шагов
+		{"f40(1)", true},
+		{"g4(1)", false},
+		{"g5(1)", true},
+		{"g6(1)", true},
+		{"g3(g3(1))", false},
+		{"g4(g4(1))", true},
+	}
+	for _, tt := range tests {
+		node, err := Parse(tt.src)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.src, err)
+		}
+		inlined, err := Inline(node, lookup)
+		if tt.tooLong {
+			if !errors.Is(err, ErrTooLarge) {
+				t.Errorf("Inline(%q): %v, want ErrTooLarge", tt.src, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Inline(%q): %v", tt.src, err)
+			continue
+		}
+		if n := countNodes(inlined, MaxInlineNodes); n > MaxInlineNodes {
+			t.Errorf("Inline(%q) has %d nodes, want at most %d", tt.src, n, MaxInlineNodes)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"calcflow/backend/internal/operation"
+)
+
+// tokenKind описывает тип лексемы выражения.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenAssign
+)
+
+// token представляет одну лексему выражения.
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// tokenize разбивает строку выражения на лексемы.
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			start := i
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[start:i], pos: start})
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(src) && (unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i])) || src[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[start:i], pos: start})
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+			i++
+		case c == '=':
+			tokens = append(tokens, token{kind: tokenAssign, text: "=", pos: i})
+			i++
+		default:
+			op := matchOperator(src[i:])
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(src)})
+	return tokens, nil
+}
+
+// matchOperator возвращает самый длинный зарегистрированный оператор,
+// с которого начинается строка.
+func matchOperator(s string) string {
+	for _, op := range operation.Default.Symbols() {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []token
+	}{
+		{"", []token{{kind: tokenEOF, pos: 0}}},
+		{"1+2", []token{
+			{tokenNumber, "1", 0}, {tokenOperator, "+", 1}, {tokenNumber, "2", 2}, {kind: tokenEOF, pos: 3},
+		}},
+		{"  3.14 *\t10 ", []token{
+			{tokenNumber, "3.14", 2}, {tokenOperator, "*", 7}, {tokenNumber, "10", 9}, {kind: tokenEOF, pos: 12},
+		}},
+		// Из операторов с общим началом выбирается самый длинный
+		{"2**3//4^5", []token{
+			{tokenNumber, "2", 0}, {tokenOperator, "**", 1}, {tokenNumber, "3", 3}, {tokenOperator, "//", 4},
+			{tokenNumber, "4", 6}, {tokenOperator, "^", 7}, {tokenNumber, "5", 8}, {kind: tokenEOF, pos: 9},
+		}},
+		{"-(7 % 2)", []token{
+			{tokenOperator, "-", 0}, {tokenLParen, "(", 1}, {tokenNumber, "7", 2}, {tokenOperator, "%", 4},
+			{tokenNumber, "2", 6}, {tokenRParen, ")", 7}, {kind: tokenEOF, pos: 8},
+		}},
+		{"sq_2(x, y1) = x", []token{
+			{tokenIdent, "sq_2", 0}, {tokenLParen, "(", 4}, {tokenIdent, "x", 5}, {tokenComma, ",", 6},
+			{tokenIdent, "y1", 8}, {tokenRParen, ")", 10}, {tokenAssign, "=", 12}, {tokenIdent, "x", 14},
+			{kind: tokenEOF, pos: 15},
+		}},
+		// Число с несколькими точками - одна лексема, ее отклоняет разбор
+		{"1..2", []token{{tokenNumber, "1..2", 0}, {kind: tokenEOF, pos: 4}}},
+	}
+	for _, tt := range tests {
+		got, err := tokenize(tt.src)
+		if err != nil {
+			t.Errorf("tokenize(%q): %v", tt.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"2 $ 3", `unexpected character '$' at position 2`},
+		{"1 & 2", `unexpected character '&' at position 2`},
+		{"a;", `unexpected character ';' at position 1`},
+		{"{1}", `unexpected character '{' at position 0`},
+	}
+	for _, tt := range tests {
+		_, err := tokenize(tt.src)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("tokenize(%q): %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package expr
+
+import "sync"
+
+// Memo - общая таблица результатов операций для одновременно выполняющихся
+// вычислений. Результат хранится, пока хотя бы одно вычисление, объявившее
+// операцию через Acquire, не вызвало Release.
+type Memo struct {
+	mu       sync.Mutex
+	entries  map[string]*memoEntry
+	computed int64
+	shared   int64
+}
+
+// memoEntry хранит результат операции или ожидание его вычисления.
+type memoEntry struct {
+	refs  int
+	done  chan struct{}
+	value float64
+	err   error
+}
+
+// MemoStats представляет статистику использования общей таблицы результатов.
+type MemoStats struct {
+	Computed int64 `json:"computed"` // Операции, выполненные заново
+	Shared   int64 `json:"shared"`   // Операции, результат которых взят из таблицы
+}
+
+// NewMemo создает пустую таблицу результатов.
+func NewMemo() *Memo {
+	return &Memo{entries: make(map[string]*memoEntry)}
+}
+
+// Acquire объявляет, что вычисление будет использовать операции с ключами keys.
+func (m *Memo) Acquire(keys []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		entry, ok := m.entries[key]
+		if !ok {
+			entry = &memoEntry{}
+			m.entries[key] = entry
+		}
+		entry.refs++
+	}
+}
+
+// Release сообщает, что вычисление больше не использует операции с ключами keys.
+// Результаты, которые больше никому не нужны, удаляются из таблицы.
+func (m *Memo) Release(keys []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		entry, ok := m.entries[key]
+		if !ok {
+			continue
+		}
+		entry.refs--
+		if entry.refs <= 0 {
+			delete(m.entries, key)
+		}
+	}
+}
+
+// Do возвращает результат операции с ключом key. Если операция уже выполнена
+// или выполняется другим вычислением, результат берется из таблицы (shared = true),
+// иначе операция выполняется функцией compute. Операции, не объявленные
+// через Acquire, выполняются без сохранения результата.
+func (m *Memo) Do(key string, compute func() (float64, error)) (value float64, shared bool, err error) {
+	m.mu.Lock()
+	entry, ok := m.entries[key]
+	if !ok {
+		m.computed++
+		m.mu.Unlock()
+		value, err = compute()
+		return value, false, err
+	}
+	if entry.done != nil {
+		m.shared++
+		m.mu.Unlock()
+		<-entry.done
+		return entry.value, true, entry.err
+	}
+	entry.done = make(chan struct{})
+	m.computed++
+	m.mu.Unlock()
+
+	entry.value, entry.err = compute()
+	close(entry.done)
+	return entry.value, false, entry.err
+}
+
+// Stats возвращает статистику использования таблицы.
+func (m *Memo) Stats() MemoStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return MemoStats{Computed: m.computed, Shared: m.shared}
+}
@@ -0,0 +1,188 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+
+	"calcflow/backend/internal/operation"
+)
+
+// parser выполняет разбор выражения методом рекурсивного спуска
+// с учетом приоритетов операторов из реестра операций.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse разбирает арифметическое выражение и возвращает его синтаксическое дерево.
+func Parse(src string) (Node, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	node, err := p.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+	return node, nil
+}
+
+// ParseDefinition разбирает определение функции вида `f(x, y) = x*x + 3*y`.
+func ParseDefinition(src string) (name string, params []string, body Node, err error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	p := &parser{tokens: tokens}
+
+	tok := p.next()
+	if tok.kind != tokenIdent {
+		return "", nil, nil, fmt.Errorf("expected function name at position %d", tok.pos)
+	}
+	name = tok.text
+
+	if tok := p.next(); tok.kind != tokenLParen {
+		return "", nil, nil, fmt.Errorf("expected \"(\" at position %d", tok.pos)
+	}
+	seen := make(map[string]bool)
+	for p.peek().kind != tokenRParen {
+		if len(params) > 0 {
+			if tok := p.next(); tok.kind != tokenComma {
+				return "", nil, nil, fmt.Errorf("expected \",\" at position %d", tok.pos)
+			}
+		}
+		tok := p.next()
+		if tok.kind != tokenIdent {
+			return "", nil, nil, fmt.Errorf("expected parameter name at position %d", tok.pos)
+		}
+		if seen[tok.text] {
+			return "", nil, nil, fmt.Errorf("duplicate parameter %q", tok.text)
+		}
+		seen[tok.text] = true
+		params = append(params, tok.text)
+	}
+	p.next()
+
+	if tok := p.next(); tok.kind != tokenAssign {
+		return "", nil, nil, fmt.Errorf("expected \"=\" at position %d", tok.pos)
+	}
+	body, err = p.parseExpr(0)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return "", nil, nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+	return name, params, body, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// parseExpr разбирает бинарные операции с приоритетом не ниже minPrec.
+func (p *parser) parseExpr(minPrec int) (Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok.kind != tokenOperator {
+			return left, nil
+		}
+		op, ok := operation.Default.Binary(tok.text)
+		if !ok || op.Precedence < minPrec {
+			return left, nil
+		}
+		p.next()
+
+		nextPrec := op.Precedence + 1
+		if op.RightAssoc {
+			nextPrec = op.Precedence
+		}
+		right, err := p.parseExpr(nextPrec)
+		if err != nil {
+			return nil, err
+		}
+		left = &Binary{Op: tok.text, Left: left, Right: right}
+	}
+}
+
+// parseUnary разбирает префиксные операции и унарный плюс.
+func (p *parser) parseUnary() (Node, error) {
+	tok := p.peek()
+	if tok.kind != tokenOperator {
+		return p.parsePrimary()
+	}
+	if op, ok := operation.Default.Unary(tok.text); ok {
+		p.next()
+		x, err := p.parseExpr(op.Precedence)
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{Op: tok.text, X: x}, nil
+	}
+	if tok.text == "+" {
+		p.next()
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary разбирает числа, имена, вызовы функций и выражения в скобках.
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", tok.text, tok.pos)
+		}
+		return &Number{Value: value, Literal: tok.text}, nil
+	case tokenIdent:
+		if p.peek().kind != tokenLParen {
+			return &Ident{Name: tok.text}, nil
+		}
+		p.next()
+		call := &Call{Name: tok.text}
+		for p.peek().kind != tokenRParen {
+			if len(call.Args) > 0 {
+				if sep := p.next(); sep.kind != tokenComma {
+					return nil, fmt.Errorf("expected \",\" at position %d", sep.pos)
+				}
+			}
+			arg, err := p.parseExpr(0)
+			if err != nil {
+				return nil, err
+			}
+			call.Args = append(call.Args, arg)
+		}
+		p.next()
+		return call, nil
+	case tokenLParen:
+		node, err := p.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, fmt.Errorf("expected \")\" at position %d", closing.pos)
+		}
+		return node, nil
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
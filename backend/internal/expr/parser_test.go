@@ -0,0 +1,147 @@
+package expr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tree записывает дерево в префиксной форме со всеми скобками, чтобы в тестах
+// была видна его структура: (+ 1 (* 2 3)).
+func tree(n Node) string {
+	switch n := n.(type) {
+	case *Unary:
+		return "(" + n.Op + " " + tree(n.X) + ")"
+	case *Binary:
+		return "(" + n.Op + " " + tree(n.Left) + " " + tree(n.Right) + ")"
+	case *Call:
+		args := make([]string, len(n.Args))
+		for i, arg := range n.Args {
+			args[i] = tree(arg)
+		}
+		return n.Name + "(" + strings.Join(args, " ") + ")"
+	}
+	return n.String()
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		// Приоритет операторов
+		{"1 + 2 * 3", "(+ 1 (* 2 3))"},
+		{"1 * 2 + 3", "(+ (* 1 2) 3)"},
+		{"(1 + 2) * 3", "(* (+ 1 2) 3)"},
+		{"10 - 4 / 2 % 3", "(- 10 (% (/ 4 2) 3))"},
+		{"7 // 2 * 3", "(* (// 7 2) 3)"},
+		{"2 * 3 ^ 2", "(* 2 (^ 3 2))"},
+
+		// Левоассоциативные операторы
+		{"8 - 3 - 2", "(- (- 8 3) 2)"},
+		{"8 / 4 / 2", "(/ (/ 8 4) 2)"},
+
+		// Возведение в степень правоассоциативно
+		{"2 ^ 3 ^ 2", "(^ 2 (^ 3 2))"},
+		{"2 ** 3 ** 2", "(** 2 (** 3 2))"},
+		{"(2 ^ 3) ^ 2", "(^ (^ 2 3) 2)"},
+
+		// Унарный минус связывает сильнее умножения, но слабее степени
+		{"-2", "(- 2)"},
+		{"-2 * 3", "(* (- 2) 3)"},
+		{"-2 ^ 2", "(- (^ 2 2))"},
+		{"2 ^ -1", "(^ 2 (- 1))"},
+		{"--2", "(- (- 2))"},
+		{"1 - -2", "(- 1 (- 2))"},
+		{"3 * -(1 + 2)", "(* 3 (- (+ 1 2)))"},
+
+		// Унарный плюс отбрасывается
+		{"+2", "2"},
+		{"1 + +2", "(+ 1 2)"},
+
+		// Числа, имена и вызовы
+		{"3.5", "3.5"},
+		{".5 + 1.", "(+ .5 1.)"},
+		{"x * y", "(* x y)"},
+		{"f()", "f()"},
+		{"f(1, g(2) + 3)", "f(1 (+ g(2) 3))"},
+		{" ( ( 4 ) ) ", "4"},
+	}
+	for _, tt := range tests {
+		node, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.src, err)
+			continue
+		}
+		if got := tree(node); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"", "unexpected end of expression"},
+		{"   ", "unexpected end of expression"},
+		{"2 +", "unexpected end of expression"},
+		{"-", "unexpected end of expression"},
+		{"(1 + 2", `expected ")" at position 6`},
+		{"1 + 2)", `unexpected ")" at position 5`},
+		{"2 3", `unexpected "3" at position 2`},
+		{"* 2", `unexpected "*" at position 0`},
+		{"1 + * 2", `unexpected "*" at position 4`},
+		{"()", `unexpected ")" at position 1`},
+		{"1..2", `invalid number "1..2" at position 0`},
+		{"2 * 3..", `invalid number "3.." at position 4`},
+		{"f(1 2)", `expected "," at position 4`},
+		{"f(1,)", `unexpected ")" at position 4`},
+		{"f(1", `expected "," at position 3`},
+		{"1 = 2", `unexpected "=" at position 2`},
+		{"2 # 3", `unexpected character '#' at position 2`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("Parse(%q): %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestParseDefinition(t *testing.T) {
+	name, params, body, err := ParseDefinition("hyp(a, b) = a^2 + b^2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "hyp" || !reflect.DeepEqual(params, []string{"a", "b"}) || tree(body) != "(+ (^ a 2) (^ b 2))" {
+		t.Errorf("ParseDefinition = %s %v %s", name, params, tree(body))
+	}
+
+	_, params, _, err = ParseDefinition("pi() = 3.14")
+	if err != nil || len(params) != 0 {
+		t.Errorf("ParseDefinition without parameters: %v %v", params, err)
+	}
+
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"(x) = x", "expected function name at position 0"},
+		{"f x = x", `expected "(" at position 2`},
+		{"f(x y) = x", `expected "," at position 4`},
+		{"f(1) = 1", "expected parameter name at position 2"},
+		{"f(x, x) = x", `duplicate parameter "x"`},
+		{"f(x) x", `expected "=" at position 5`},
+		{"f(x) =", "unexpected end of expression"},
+		{"f(x) = x )", `unexpected ")" at position 9`},
+		{"f(x) = x $", `unexpected character '$' at position 9`},
+	}
+	for _, tt := range tests {
+		_, _, _, err := ParseDefinition(tt.src)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("ParseDefinition(%q): %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
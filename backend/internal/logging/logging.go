@@ -0,0 +1,103 @@
+// Package logging настраивает структурированный журнал процесса на основе log/slog
+// и переносит идентификатор HTTP-запроса через контекст в каждую запись журнала.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Форматы вывода журнала.
+const (
+	FormatText = "text" // Строки вида key=value
+	FormatJSON = "json" // Один JSON-объект на запись
+)
+
+// level - уровень журнала процесса; его можно изменить без пересоздания журнала.
+var level slog.LevelVar
+
+// ParseLevel разбирает уровень журнала: debug, info, warn или error.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+	return l, nil
+}
+
+// SetLevel задает уровень журнала процесса, в том числе для уже созданных журналов.
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// New создает журнал, который пишет в w в формате format с уровнем процесса.
+// Записи, сделанные с контекстом запроса, получают атрибут request_id, а с контекстом
+// участка трассировки - атрибуты trace_id и span_id.
+func New(w io.Writer, format string) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: &level}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// Setup задает уровень журнала процесса и делает журнал в формате format
+// журналом по умолчанию для slog и пакета log.
+func Setup(w io.Writer, format string, l slog.Level) error {
+	logger, err := New(w, format)
+	if err != nil {
+		return err
+	}
+	SetLevel(l)
+	slog.SetDefault(logger)
+	return nil
+}
+
+type requestIDKey struct{}
+
+// WithRequestID возвращает контекст с идентификатором HTTP-запроса.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает идентификатор HTTP-запроса из контекста или пустую строку.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler добавляет в записи идентификатор запроса и участок трассировки
+// из их контекста.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		record.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
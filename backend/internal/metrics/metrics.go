@@ -0,0 +1,313 @@
+// Package metrics реализует счетчики, измерители и гистограммы с метками
+// и их вывод в текстовом формате Prometheus.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets - верхние границы интервалов гистограмм длительности в секундах.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+// Default - реестр метрик процесса, который отдается по /metrics.
+var Default = NewRegistry()
+
+// metric - метрика, зарегистрированная в реестре.
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Registry хранит метрики и выводит их в текстовом формате Prometheus.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// NewRegistry создает пустой реестр метрик.
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// register добавляет метрику в реестр. Повторная регистрация имени - ошибка
+// программы, поэтому вызывает панику.
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.metrics[name]; ok {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.metrics[name] = m
+}
+
+// Counter регистрирует счетчик с метками labels.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: newVec(name, help, "counter", labels)}
+	r.register(name, c)
+	return c
+}
+
+// Gauge регистрирует измеритель с метками labels.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vec: newVec(name, help, "gauge", labels)}
+	r.register(name, g)
+	return g
+}
+
+// GaugeFunc регистрирует измеритель без меток, значение которого вычисляется
+// функцией value при каждом выводе метрик.
+func (r *Registry) GaugeFunc(name, help string, value func() float64) {
+	r.register(name, &gaugeFunc{name: name, help: help, value: value})
+}
+
+// Histogram регистрирует гистограмму с верхними границами интервалов buckets
+// в порядке возрастания и метками labels.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{vec: newVec(name, help, "histogram", labels), buckets: buckets}
+	r.register(name, h)
+	return h
+}
+
+// WriteText выводит все метрики в текстовом формате Prometheus в порядке их имен.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		metrics[i] = r.metrics[name]
+	}
+	r.mu.Unlock()
+
+	b := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(b)
+	}
+	return b.Flush()
+}
+
+// vec хранит ряды значений метрики по сочетаниям значений меток.
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series - ряд метрики с конкретными значениями меток.
+type series struct {
+	labels []string
+	value  float64
+
+	// Только для гистограмм
+	counts []uint64 // Число наблюдений в каждом интервале, без накопления
+	count  uint64
+}
+
+func newVec(name, help, kind string, labels []string) vec {
+	return vec{name: name, help: help, kind: kind, labels: labels, series: make(map[string]*series)}
+}
+
+// get возвращает ряд для значений меток, создавая его при необходимости.
+// Вызывается под v.mu.
+func (v *vec) get(values []string) *series {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), values...)}
+		v.series[key] = s
+	}
+	return s
+}
+
+// sorted возвращает копии рядов, упорядоченные по значениям меток.
+func (v *vec) sorted() []series {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]series, len(keys))
+	for i, key := range keys {
+		s := *v.series[key]
+		s.counts = append([]uint64(nil), s.counts...)
+		result[i] = s
+	}
+	return result
+}
+
+func (v *vec) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.kind)
+}
+
+// Counter - счетчик, который только возрастает.
+type Counter struct {
+	vec
+}
+
+// Inc увеличивает счетчик со значениями меток values на единицу.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add увеличивает счетчик со значениями меток values на delta; delta не должна быть отрицательной.
+func (c *Counter) Add(delta float64, values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.get(values).value += delta
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.writeHeader(w)
+	for _, s := range c.sorted() {
+		writeSample(w, c.name, c.labels, s.labels, "", "", s.value)
+	}
+}
+
+// Gauge - измеритель, значение которого может как расти, так и убывать.
+type Gauge struct {
+	vec
+}
+
+// Set задает значение измерителя со значениями меток values.
+func (g *Gauge) Set(value float64, values ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.get(values).value = value
+}
+
+// Add изменяет значение измерителя со значениями меток values на delta.
+func (g *Gauge) Add(delta float64, values ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.get(values).value += delta
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.writeHeader(w)
+	for _, s := range g.sorted() {
+		writeSample(w, g.name, g.labels, s.labels, "", "", s.value)
+	}
+}
+
+// gaugeFunc - измеритель, значение которого вычисляется при выводе.
+type gaugeFunc struct {
+	name  string
+	help  string
+	value func() float64
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", g.name, escapeHelp(g.help))
+	fmt.Fprintf(w, "# TYPE %s gauge\n", g.name)
+	writeSample(w, g.name, nil, nil, "", "", g.value())
+}
+
+// Histogram распределяет наблюдения по интервалам и считает их сумму и число.
+type Histogram struct {
+	vec
+	buckets []float64
+}
+
+// Observe добавляет наблюдение value в ряд со значениями меток values.
+func (h *Histogram) Observe(value float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.get(values)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets))
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.value += value
+	s.count++
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.writeHeader(w)
+	for _, s := range h.sorted() {
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			writeSample(w, h.name+"_bucket", h.labels, s.labels, "le", formatFloat(bound), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, s.labels, "le", "+Inf", float64(s.count))
+		writeSample(w, h.name+"_sum", h.labels, s.labels, "", "", s.value)
+		writeSample(w, h.name+"_count", h.labels, s.labels, "", "", float64(s.count))
+	}
+}
+
+// writeSample выводит строку значения ряда; extraName и extraValue задают
+// дополнительную метку, например границу интервала гистограммы.
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraName, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", label, escapeLabel(values[i]))
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
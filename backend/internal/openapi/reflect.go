@@ -0,0 +1,91 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// SchemaOf строит схему значения по его Go-типу с учетом тегов json.
+// Именованные структуры и словари добавляются в компоненты документа,
+// а вместо них возвращается ссылка.
+func (d *Document) SchemaOf(v interface{}) *Schema {
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "Длительность в наносекундах"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return d.named(t, func() *Schema {
+			return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+		})
+	case reflect.Struct:
+		return d.named(t, func() *Schema {
+			return d.structSchema(t)
+		})
+	}
+	return &Schema{}
+}
+
+// named регистрирует схему именованного типа в компонентах и возвращает ссылку на нее.
+func (d *Document) named(t reflect.Type, build func() *Schema) *Schema {
+	if t.Name() == "" {
+		return build()
+	}
+	if d.Components.Schemas == nil {
+		d.Components.Schemas = make(map[string]*Schema)
+	}
+	if _, ok := d.Components.Schemas[t.Name()]; !ok {
+		// Резервируем имя до построения схемы, чтобы не зациклиться на рекурсивных типах
+		d.Components.Schemas[t.Name()] = &Schema{}
+		*d.Components.Schemas[t.Name()] = *build()
+	}
+	return Ref(t.Name())
+}
+
+// structSchema строит схему объекта по экспортируемым полям структуры.
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		s.Properties[name] = d.schemaOf(field.Type)
+	}
+	return s
+}
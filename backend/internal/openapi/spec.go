@@ -0,0 +1,136 @@
+package openapi
+
+import "encoding/json"
+
+// Version - версия спецификации OpenAPI, которой соответствует документ.
+const Version = "3.0.3"
+
+// Document представляет документ OpenAPI 3.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// Info содержит общие сведения об API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem описывает операции, доступные по одному пути, по HTTP-методам.
+type PathItem map[string]*Operation
+
+// Operation описывает одну операцию API.
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+	Scope       string                `json:"x-required-scope,omitempty"` // Область доступа, необходимая для вызова
+}
+
+// SecurityRequirement перечисляет схемы аутентификации, любая из которых допускает вызов операции.
+type SecurityRequirement map[string][]string
+
+// SecurityScheme описывает способ аутентификации.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Parameter описывает параметр пути, запроса или заголовок.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody описывает тело запроса.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response описывает ответ операции.
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Parameter `json:"headers,omitempty"`
+	Content     map[string]MediaType  `json:"content,omitempty"`
+}
+
+// MediaType связывает тип содержимого со схемой.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components содержит именованные схемы и способы аутентификации, на которые ссылаются операции.
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// Schema представляет подмножество JSON Schema, используемое в OpenAPI 3.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Closed               bool               `json:"-"` // Запрещает свойства, не перечисленные в Properties
+	FoldCase             bool               `json:"-"` // Имена свойств сравниваются с Properties без учета регистра
+	Items                *Schema            `json:"items,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+// JSON возвращает тип содержимого application/json со схемой s.
+func JSON(s *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: s}}
+}
+
+// Ref возвращает ссылку на именованную схему из компонентов.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Resolve возвращает схему, на которую указывает ссылка, или саму схему.
+func (d *Document) Resolve(s *Schema) *Schema {
+	const prefix = "#/components/schemas/"
+	for s != nil && s.Ref != "" {
+		if len(s.Ref) <= len(prefix) {
+			return nil
+		}
+		s = d.Components.Schemas[s.Ref[len(prefix):]]
+	}
+	return s
+}
+
+// MarshalJSON кодирует схему, добавляя "additionalProperties": false для закрытых объектов.
+func (s Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	if !s.Closed || s.AdditionalProperties != nil {
+		return json.Marshal(plain(s))
+	}
+	return json.Marshal(struct {
+		plain
+		AdditionalProperties bool `json:"additionalProperties"`
+	}{plain: plain(s)})
+}
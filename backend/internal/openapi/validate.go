@@ -0,0 +1,165 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidationError содержит все несоответствия значения схеме.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "request does not match schema: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate проверяет значение, полученное из json.Unmarshal, на соответствие схеме.
+// location используется в сообщениях об ошибках, например "body".
+func (d *Document) Validate(s *Schema, value interface{}, location string) error {
+	v := validator{doc: d}
+	v.validate(s, value, location)
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+type validator struct {
+	doc      *Document
+	problems []string
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) validate(s *Schema, value interface{}, path string) {
+	s = v.doc.Resolve(s)
+	if s == nil {
+		return
+	}
+	if value == nil {
+		if !s.Nullable && s.Type != "" {
+			v.fail(path, "must not be null")
+		}
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			v.fail(path, "must be an object")
+			return
+		}
+		v.validateObject(s, object, path)
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			v.fail(path, "must be an array")
+			return
+		}
+		for i, item := range array {
+			v.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			v.fail(path, "must be a string")
+			return
+		}
+		v.validateString(s, str, path)
+	case "number", "integer":
+		number, ok := value.(float64)
+		if !ok {
+			v.fail(path, "must be a %s", s.Type)
+			return
+		}
+		if s.Type == "integer" && number != math.Trunc(number) {
+			v.fail(path, "must be an integer")
+		}
+		if s.Minimum != nil && number < *s.Minimum {
+			v.fail(path, "must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && number > *s.Maximum {
+			v.fail(path, "must be at most %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(path, "must be a boolean")
+		}
+	}
+}
+
+func (v *validator) validateObject(s *Schema, object map[string]interface{}, path string) {
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			v.fail(path+"."+name, "is required")
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if property, ok := s.property(name); ok {
+			v.validate(property, object[name], path+"."+name)
+			continue
+		}
+		switch {
+		case s.AdditionalProperties != nil:
+			v.validate(s.AdditionalProperties, object[name], path+"."+name)
+		case s.Closed:
+			v.fail(path+"."+name, "unknown property")
+		}
+	}
+}
+
+// property возвращает схему свойства с именем name с учетом FoldCase.
+func (s *Schema) property(name string) (*Schema, bool) {
+	if property, ok := s.Properties[name]; ok || !s.FoldCase {
+		return property, ok
+	}
+	for candidate, property := range s.Properties {
+		if strings.EqualFold(candidate, name) {
+			return property, true
+		}
+	}
+	return nil, false
+}
+
+func (v *validator) validateString(s *Schema, str, path string) {
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if str == allowed {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "must be one of %s", strings.Join(s.Enum, ", "))
+		}
+	}
+	if s.MinLength != nil && len(str) < *s.MinLength {
+		v.fail(path, "must be at least %d characters long", *s.MinLength)
+	}
+
+	switch s.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			v.fail(path, "must be an RFC 3339 date-time")
+		}
+	case "duration":
+		if _, err := time.ParseDuration(str); err != nil {
+			v.fail(path, "must be a duration such as \"1s\" or \"250ms\"")
+		}
+	}
+}
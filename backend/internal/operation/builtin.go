@@ -0,0 +1,116 @@
+package operation
+
+import (
+	"math"
+	"time"
+)
+
+// Default - реестр со встроенными операциями, используемый по умолчанию.
+var Default = NewRegistry()
+
+// MustRegister добавляет операцию в реестр по умолчанию и паникует при ошибке.
+// Предназначена для регистрации операций при инициализации пакетов.
+func MustRegister(op Operation) {
+	if err := Default.Register(op); err != nil {
+		panic(err)
+	}
+}
+
+// Время выполнения по умолчанию отражает относительную сложность операций:
+// пока оно не задано в настройках, по нему оцениваются выражения для вычисления
+// при запросе и расход суточной квоты.
+func init() {
+	MustRegister(Operation{
+		Name:        "summation",
+		Symbols:     []string{"+"},
+		Arity:       2,
+		Precedence:  1,
+		Commutative: true,
+		DefaultCost: 10 * time.Millisecond,
+		Apply: func(args ...float64) (float64, error) {
+			return args[0] + args[1], nil
+		},
+	})
+	MustRegister(Operation{
+		Name:        "subtraction",
+		Symbols:     []string{"-"},
+		Arity:       2,
+		Precedence:  1,
+		DefaultCost: 10 * time.Millisecond,
+		Apply: func(args ...float64) (float64, error) {
+			return args[0] - args[1], nil
+		},
+	})
+	MustRegister(Operation{
+		Name:        "multiplication",
+		Symbols:     []string{"*"},
+		Arity:       2,
+		Precedence:  2,
+		Commutative: true,
+		DefaultCost: 20 * time.Millisecond,
+		Apply: func(args ...float64) (float64, error) {
+			return args[0] * args[1], nil
+		},
+	})
+	MustRegister(Operation{
+		Name:        "division",
+		Symbols:     []string{"/"},
+		Arity:       2,
+		Precedence:  2,
+		DefaultCost: 20 * time.Millisecond,
+		Apply: func(args ...float64) (float64, error) {
+			if args[1] == 0 {
+				return 0, ErrDivisionByZero
+			}
+			return args[0] / args[1], nil
+		},
+	})
+	MustRegister(Operation{
+		Name:        "modulo",
+		Symbols:     []string{"%"},
+		Arity:       2,
+		Precedence:  2,
+		DefaultCost: 20 * time.Millisecond,
+		Apply: func(args ...float64) (float64, error) {
+			if args[1] == 0 {
+				return 0, ErrDivisionByZero
+			}
+			return math.Mod(args[0], args[1]), nil
+		},
+	})
+	MustRegister(Operation{
+		Name:        "integer_division",
+		Symbols:     []string{"//"},
+		Arity:       2,
+		Precedence:  2,
+		DefaultCost: 20 * time.Millisecond,
+		Apply: func(args ...float64) (float64, error) {
+			if args[1] == 0 {
+				return 0, ErrDivisionByZero
+			}
+			return math.Floor(args[0] / args[1]), nil
+		},
+	})
+	// Унарный минус связывает слабее возведения в степень: -2^2 = -(2^2)
+	MustRegister(Operation{
+		Name:        "negation",
+		Symbols:     []string{"-"},
+		Arity:       1,
+		Precedence:  3,
+		DefaultCost: 5 * time.Millisecond,
+		Apply: func(args ...float64) (float64, error) {
+			return -args[0], nil
+		},
+	})
+	MustRegister(Operation{
+		Name:        "exponentiation",
+		Symbols:     []string{"^", "**"},
+		Arity:       2,
+		Precedence:  4,
+		RightAssoc:  true,
+		DefaultCost: 50 * time.Millisecond,
+		Apply: func(args ...float64) (float64, error) {
+			return math.Pow(args[0], args[1]), nil
+		},
+	})
+}
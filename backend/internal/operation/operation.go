@@ -0,0 +1,157 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrDivisionByZero возвращается при делении на ноль.
+var ErrDivisionByZero = errors.New("division by zero")
+
+// Operation описывает арифметическую операцию, доступную в выражениях.
+// Парсер, оценка стоимости, агенты и настройки времени выполнения
+// строятся на основе зарегистрированных операций.
+type Operation struct {
+	Name        string   // Название операции, под которым задается время ее выполнения
+	Symbols     []string // Символы оператора, например "^" и "**"
+	Arity       int      // Количество операндов: 1 для префиксных, 2 для инфиксных операций
+	Precedence  int      // Приоритет оператора, больше - связывает сильнее
+	RightAssoc  bool     // Правая ассоциативность (только для бинарных операций)
+	Commutative bool     // Результат не зависит от порядка операндов
+	Apply       func(args ...float64) (float64, error)
+	DefaultCost time.Duration // Время выполнения, если оно не задано в настройках
+}
+
+// Registry хранит набор зарегистрированных операций.
+type Registry struct {
+	mu      sync.RWMutex
+	byName  map[string]*Operation
+	unary   map[string]*Operation
+	binary  map[string]*Operation
+	symbols []string
+}
+
+// NewRegistry создает пустой реестр операций.
+func NewRegistry() *Registry {
+	return &Registry{
+		byName: make(map[string]*Operation),
+		unary:  make(map[string]*Operation),
+		binary: make(map[string]*Operation),
+	}
+}
+
+// Register добавляет операцию в реестр.
+func (r *Registry) Register(op Operation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if op.Name == "" || len(op.Symbols) == 0 || op.Apply == nil {
+		return fmt.Errorf("operation %q: name, symbols and implementation are required", op.Name)
+	}
+	if _, ok := r.byName[op.Name]; ok {
+		return fmt.Errorf("operation %q is already registered", op.Name)
+	}
+
+	var bySymbol map[string]*Operation
+	switch op.Arity {
+	case 1:
+		bySymbol = r.unary
+	case 2:
+		bySymbol = r.binary
+	default:
+		return fmt.Errorf("operation %q: unsupported arity %d", op.Name, op.Arity)
+	}
+	for _, symbol := range op.Symbols {
+		if _, ok := bySymbol[symbol]; ok {
+			return fmt.Errorf("operation %q: symbol %q is already registered", op.Name, symbol)
+		}
+	}
+
+	registered := op
+	r.byName[op.Name] = &registered
+	for _, symbol := range op.Symbols {
+		bySymbol[symbol] = &registered
+		if !r.hasSymbol(symbol) {
+			r.symbols = append(r.symbols, symbol)
+		}
+	}
+
+	// Многосимвольные операторы должны проверяться раньше односимвольных
+	sort.SliceStable(r.symbols, func(i, j int) bool {
+		return len(r.symbols[i]) > len(r.symbols[j])
+	})
+
+	return nil
+}
+
+func (r *Registry) hasSymbol(symbol string) bool {
+	for _, s := range r.symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// Unary возвращает префиксную операцию по ее символу.
+func (r *Registry) Unary(symbol string) (*Operation, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	op, ok := r.unary[symbol]
+	return op, ok
+}
+
+// Binary возвращает инфиксную операцию по ее символу.
+func (r *Registry) Binary(symbol string) (*Operation, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	op, ok := r.binary[symbol]
+	return op, ok
+}
+
+// ByName возвращает операцию по ее названию.
+func (r *Registry) ByName(name string) (*Operation, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	op, ok := r.byName[name]
+	return op, ok
+}
+
+// Symbols возвращает символы всех операторов, начиная с самых длинных.
+func (r *Registry) Symbols() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string(nil), r.symbols...)
+}
+
+// All возвращает все операции, отсортированные по названию.
+func (r *Registry) All() []*Operation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ops := make([]*Operation, 0, len(r.byName))
+	for _, op := range r.byName {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].Name < ops[j].Name
+	})
+	return ops
+}
+
+// Names возвращает названия всех операций, отсортированные по алфавиту.
+func (r *Registry) Names() []string {
+	ops := r.All()
+	names := make([]string, len(ops))
+	for i, op := range ops {
+		names[i] = op.Name
+	}
+	return names
+}
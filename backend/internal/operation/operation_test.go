@@ -0,0 +1,119 @@
+package operation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuiltinOperations(t *testing.T) {
+	want := []string{"division", "exponentiation", "integer_division", "modulo", "multiplication", "negation", "subtraction", "summation"}
+	if got := Default.Names(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Names() = %v, want %v", got, want)
+	}
+	for _, op := range Default.All() {
+		if op.DefaultCost <= 0 {
+			t.Errorf("operation %s has default cost %v, want a positive duration", op.Name, op.DefaultCost)
+		}
+	}
+
+	tests := []struct {
+		symbol string
+		arity  int
+		want   string
+	}{
+		{"+", 2, "summation"},
+		{"-", 2, "subtraction"},
+		{"-", 1, "negation"},
+		{"^", 2, "exponentiation"},
+		{"**", 2, "exponentiation"},
+		{"//", 2, "integer_division"},
+	}
+	for _, tt := range tests {
+		lookup := Default.Binary
+		if tt.arity == 1 {
+			lookup = Default.Unary
+		}
+		op, ok := lookup(tt.symbol)
+		if !ok || op.Name != tt.want {
+			t.Errorf("operator %q with %d operands: %v, want %s", tt.symbol, tt.arity, op, tt.want)
+		}
+	}
+	if _, ok := Default.Unary("+"); ok {
+		t.Error("unary + is registered")
+	}
+
+	// Многосимвольные операторы проверяются раньше односимвольных
+	symbols := Default.Symbols()
+	for i := 1; i < len(symbols); i++ {
+		if len(symbols[i]) > len(symbols[i-1]) {
+			t.Fatalf("Symbols() = %v, want longer symbols first", symbols)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name string
+		args []float64
+		want float64
+		err  error
+	}{
+		{"summation", []float64{2, 3}, 5, nil},
+		{"subtraction", []float64{2, 3}, -1, nil},
+		{"multiplication", []float64{2, 3}, 6, nil},
+		{"division", []float64{3, 2}, 1.5, nil},
+		{"division", []float64{3, 0}, 0, ErrDivisionByZero},
+		{"modulo", []float64{7, 3}, 1, nil},
+		{"modulo", []float64{-7, 3}, -1, nil},
+		{"modulo", []float64{7, 0}, 0, ErrDivisionByZero},
+		{"integer_division", []float64{7, 2}, 3, nil},
+		{"integer_division", []float64{-7, 2}, -4, nil},
+		{"integer_division", []float64{7, 0}, 0, ErrDivisionByZero},
+		{"exponentiation", []float64{2, 10}, 1024, nil},
+		{"exponentiation", []float64{4, 0.5}, 2, nil},
+		{"negation", []float64{2}, -2, nil},
+	}
+	for _, tt := range tests {
+		op, ok := Default.ByName(tt.name)
+		if !ok {
+			t.Fatalf("operation %s is not registered", tt.name)
+		}
+		got, err := op.Apply(tt.args...)
+		if err != tt.err || got != tt.want {
+			t.Errorf("%s%v = %v, %v; want %v, %v", tt.name, tt.args, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	apply := func(args ...float64) (float64, error) { return args[0], nil }
+	r := NewRegistry()
+	if err := r.Register(Operation{Name: "max", Symbols: []string{"|"}, Arity: 2, Apply: apply}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		op   Operation
+	}{
+		{"duplicate name", Operation{Name: "max", Symbols: []string{"&"}, Arity: 2, Apply: apply}},
+		{"duplicate symbol", Operation{Name: "min", Symbols: []string{"|"}, Arity: 2, Apply: apply}},
+		{"no name", Operation{Symbols: []string{"&"}, Arity: 2, Apply: apply}},
+		{"no symbols", Operation{Name: "min", Arity: 2, Apply: apply}},
+		{"no implementation", Operation{Name: "min", Symbols: []string{"&"}, Arity: 2}},
+		{"unsupported arity", Operation{Name: "min", Symbols: []string{"&"}, Arity: 3, Apply: apply}},
+	}
+	for _, tt := range tests {
+		if err := r.Register(tt.op); err == nil {
+			t.Errorf("%s: Register succeeded", tt.name)
+		}
+	}
+
+	// Один символ может быть и унарным, и бинарным оператором
+	if err := r.Register(Operation{Name: "abs", Symbols: []string{"|"}, Arity: 1, Apply: apply}); err != nil {
+		t.Errorf("unary operator with a binary symbol: %v", err)
+	}
+	if got := r.Names(); !reflect.DeepEqual(got, []string{"abs", "max"}) {
+		t.Errorf("Names() = %v", got)
+	}
+}
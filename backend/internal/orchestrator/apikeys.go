@@ -0,0 +1,207 @@
+package orchestrator
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/task"
+)
+
+// APIKeyPrefix - начало всех ключей API, отличающее их от других учетных данных.
+const APIKeyPrefix = "cf_"
+
+var (
+	// ErrUnauthorized возвращается для неизвестного или отозванного ключа API.
+	ErrUnauthorized = errors.New("invalid API key")
+
+	// ErrInvalidScope возвращается при создании ключа с неизвестной областью доступа.
+	ErrInvalidScope = errors.New("invalid scope")
+
+	// ErrAPIKeyRevoked возвращается при попытке заменить отозванный ключ.
+	ErrAPIKeyRevoked = errors.New("API key is revoked")
+)
+
+// CreateAPIKey создает ключ API с заданными областями доступа. Ключ, привязанный
+// к арендатору, дает доступ только к нему; с пустым tenant ключ дает доступ
+// к арендатору из заголовка запроса. Ключ возвращается только здесь:
+// в базе данных хранится его хеш.
+func (o *Orchestrator) CreateAPIKey(name, tenant string, scopes []string) (*task.APIKey, string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := validateScopes(scopes); err != nil {
+		return nil, "", err
+	}
+	return o.createAPIKey(name, tenant, scopes)
+}
+
+// createAPIKey создает ключ API. Вызывается под o.mu.
+func (o *Orchestrator) createAPIKey(name, tenant string, scopes []string) (*task.APIKey, string, error) {
+	secret, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &task.APIKey{
+		ID:      task.NewID(),
+		Name:    name,
+		Prefix:  secret[:len(APIKeyPrefix)+6],
+		Hash:    hashAPIKey(secret),
+		Scopes:  scopes,
+		Tenant:  tenant,
+		Created: time.Now(),
+	}
+	if err := o.db.NewAPIKey(key); err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// RotateAPIKey заменяет ключ API новым с теми же областями доступа.
+// Прежний ключ перестает действовать.
+func (o *Orchestrator) RotateAPIKey(id string) (*task.APIKey, string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	key, err := o.db.GetAPIKey(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if !key.Active() {
+		return nil, "", ErrAPIKeyRevoked
+	}
+
+	secret, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key.Prefix = secret[:len(APIKeyPrefix)+6]
+	key.Hash = hashAPIKey(secret)
+	key.Rotated = time.Now()
+	if err := o.db.UpdateAPIKey(key); err != nil {
+		return nil, "", err
+	}
+	return key, secret, nil
+}
+
+// RevokeAPIKey отзывает ключ API. Повторный отзыв не меняет время отзыва.
+func (o *Orchestrator) RevokeAPIKey(id string) (*task.APIKey, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	key, err := o.db.GetAPIKey(id)
+	if err != nil {
+		return nil, err
+	}
+	if !key.Active() {
+		return key, nil
+	}
+
+	key.Revoked = time.Now()
+	if err := o.db.UpdateAPIKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ListAPIKeys возвращает все ключи API, включая отозванные.
+func (o *Orchestrator) ListAPIKeys() ([]*task.APIKey, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.db.GetAPIKeys()
+}
+
+// Authenticate возвращает действующий ключ API по его значению.
+func (o *Orchestrator) Authenticate(secret string) (*task.APIKey, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !strings.HasPrefix(secret, APIKeyPrefix) {
+		return nil, ErrUnauthorized
+	}
+	key, err := o.db.GetAPIKeyByHash(hashAPIKey(secret))
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !key.Active() {
+		return nil, ErrUnauthorized
+	}
+	return key, nil
+}
+
+// EnsureAdminKey обеспечивает наличие ключа администратора при запуске.
+// Если secret задан, ключ с этим значением добавляется, если его еще нет.
+// Иначе, если действующих ключей администратора нет, создается новый ключ,
+// который возвращается, чтобы его можно было сообщить оператору.
+func (o *Orchestrator) EnsureAdminKey(secret string) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if secret == "" {
+		exists, err := o.db.HasAdminAPIKey()
+		if err != nil || exists {
+			return "", err
+		}
+		_, secret, err := o.createAPIKey("admin", "", []string{task.ScopeAdmin})
+		return secret, err
+	}
+
+	if !strings.HasPrefix(secret, APIKeyPrefix) {
+		return "", fmt.Errorf("admin API key must start with %q", APIKeyPrefix)
+	}
+	_, err := o.db.GetAPIKeyByHash(hashAPIKey(secret))
+	if !errors.Is(err, database.ErrNotFound) {
+		return "", err
+	}
+	return "", o.db.NewAPIKey(&task.APIKey{
+		ID:      task.NewID(),
+		Name:    "admin",
+		Prefix:  secret[:min(len(secret), len(APIKeyPrefix)+6)],
+		Hash:    hashAPIKey(secret),
+		Scopes:  task.Scopes{task.ScopeAdmin},
+		Created: time.Now(),
+	})
+}
+
+// validateScopes проверяет, что набор областей доступа не пуст и известен.
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	for _, scope := range scopes {
+		switch scope {
+		case task.ScopeSubmit, task.ScopeRead, task.ScopeAdmin:
+		default:
+			return fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+	}
+	return nil
+}
+
+// generateAPIKey создает случайный ключ API.
+func generateAPIKey() (string, error) {
+	var random [32]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return "", err
+	}
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	return APIKeyPrefix + strings.ToLower(encoding.EncodeToString(random[:])), nil
+}
+
+// hashAPIKey вычисляет хеш ключа API для хранения и поиска.
+// Ключи случайны и длинны, поэтому медленное хеширование не требуется.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,188 @@
+package orchestrator
+
+import (
+	"errors"
+	"time"
+
+	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/expr"
+	"calcflow/backend/internal/task"
+)
+
+// CacheConfig задает параметры кэша результатов вычислений.
+type CacheConfig struct {
+	TTL  time.Duration // Время хранения результата; 0 отключает кэш
+	Size int           // Максимальное количество результатов; 0 - без ограничения
+}
+
+// DefaultCacheConfig - параметры кэша результатов по умолчанию.
+var DefaultCacheConfig = CacheConfig{
+	TTL:  time.Hour,
+	Size: 10000,
+}
+
+// CacheStats представляет статистику использования кэша результатов.
+type CacheStats struct {
+	Hits      int64 `json:"hits"`      // Результат найден в кэше
+	Misses    int64 `json:"misses"`    // Выражение отправлено на вычисление
+	Coalesced int64 `json:"coalesced"` // Задача присоединена к идентичной выполняющейся задаче
+	Entries   int64 `json:"entries"`   // Количество результатов в кэше
+
+	Operations expr.MemoStats `json:"operations"` // Использование общей таблицы результатов операций
+}
+
+// ConfigureCache задает параметры кэша результатов.
+func (o *Orchestrator) ConfigureCache(config CacheConfig) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.cacheConfig = config
+}
+
+// GetCacheStats возвращает статистику использования кэша результатов.
+func (o *Orchestrator) GetCacheStats() (CacheStats, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	stats := o.cacheStats
+	entries, err := o.db.CountCachedResults()
+	if err != nil {
+		return CacheStats{}, err
+	}
+	stats.Entries = entries
+	stats.Operations = o.memo.Stats()
+
+	return stats, nil
+}
+
+// resolveFromCache пытается завершить задачу без вычисления: результатом из кэша
+// или присоединением к выполняющейся задаче с тем же выражением.
+// Возвращает true, если задачу не нужно отправлять агенту.
+func (o *Orchestrator) resolveFromCache(t *task.Task) (bool, error) {
+	if hit, err := o.completeFromCache(t); hit || err != nil {
+		return hit, err
+	}
+
+	if followers, ok := o.inflight[t.CacheKey]; ok {
+		o.cacheStats.Coalesced++
+		o.inflight[t.CacheKey] = append(followers, t)
+		return true, nil
+	}
+
+	o.cacheStats.Misses++
+	o.inflight[t.CacheKey] = nil
+	return false, nil
+}
+
+// completeFromCache завершает задачу результатом из кэша, если он там есть.
+// Промах не учитывается в статистике. Вызывается под o.mu.
+func (o *Orchestrator) completeFromCache(t *task.Task) (bool, error) {
+	entry, err := o.db.GetCachedResult(t.CacheKey, time.Now())
+	if errors.Is(err, database.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	o.cacheStats.Hits++
+	t.Status = "completed"
+	t.Result = entry.Result
+	t.Cached = true
+	t.Finished = time.Now()
+	t.Duration = t.Finished.Sub(t.Created)
+	return true, nil
+}
+
+// putCached сохраняет результат успешно вычисленной задачи в кэш. Вызывается под o.mu.
+func (o *Orchestrator) putCached(t *task.Task) error {
+	if t.Status != "completed" || o.cacheConfig.TTL <= 0 {
+		return nil
+	}
+	now := time.Now()
+	return o.db.PutCachedResult(&task.CachedResult{
+		Key:      t.CacheKey,
+		Result:   t.Result,
+		Created:  now,
+		Expires:  now.Add(o.cacheConfig.TTL),
+		LastUsed: now,
+	}, o.cacheConfig.Size)
+}
+
+// completeCached сохраняет результат вычисленной задачи в кэш и завершает
+// присоединенные к ней задачи с тем же выражением. Ошибки сохранения не прерывают
+// завершение остальных задач и возвращаются вместе.
+func (o *Orchestrator) completeCached(t *task.Task) error {
+	followers := o.inflight[t.CacheKey]
+	delete(o.inflight, t.CacheKey)
+
+	var errs []error
+	if err := o.putCached(t); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, follower := range followers {
+		if _, ok := o.canceled[follower.ID]; ok {
+			delete(o.canceled, follower.ID)
+			continue
+		}
+		follower.Status = t.Status
+		follower.Result = t.Result
+		follower.Cached = true
+		follower.Finished = t.Finished
+		follower.Duration = follower.Finished.Sub(follower.Created)
+		if err := o.db.UpdateTask(follower); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		o.notify(follower)
+		observeFinished(follower)
+	}
+
+	return errors.Join(errs...)
+}
+
+// promoteFollower передает вычисление выражения отмененной задачи, которая не была
+// отправлена агенту, первой присоединенной к ней неотмененной задаче: та ставится
+// в очередь, а остальные остаются присоединенными к ней. Если таких задач нет,
+// отметка о выполнении выражения снимается. Вызывается под o.mu.
+func (o *Orchestrator) promoteFollower(t *task.Task) {
+	if t.CacheKey == "" {
+		return
+	}
+	followers, ok := o.inflight[t.CacheKey]
+	if !ok {
+		return
+	}
+	for len(followers) > 0 {
+		leader := followers[0]
+		followers = followers[1:]
+		if _, canceled := o.canceled[leader.ID]; canceled {
+			delete(o.canceled, leader.ID)
+			continue
+		}
+		o.inflight[t.CacheKey] = followers
+		taskLogger(leader).Debug("expression taken over from canceled task", "canceled_task_id", t.ID)
+		o.enqueue(leader)
+		return
+	}
+	delete(o.inflight, t.CacheKey)
+}
+
+// forgetInflight отменяет регистрацию задачи, которую не удалось сохранить:
+// удаляет ее из ожидающих либо снимает отметку о выполнении выражения.
+func (o *Orchestrator) forgetInflight(t *task.Task) {
+	if t.CacheKey == "" || t.Cached {
+		return
+	}
+	followers, ok := o.inflight[t.CacheKey]
+	if !ok {
+		return
+	}
+	for i, follower := range followers {
+		if follower == t {
+			o.inflight[t.CacheKey] = append(followers[:i], followers[i+1:]...)
+			return
+		}
+	}
+	delete(o.inflight, t.CacheKey)
+}
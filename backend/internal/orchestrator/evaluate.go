@@ -0,0 +1,219 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/expr"
+	"calcflow/backend/internal/operation"
+	"calcflow/backend/internal/task"
+	"calcflow/backend/internal/tracing"
+)
+
+// DefaultEvaluateThreshold - оценка времени вычисления по умолчанию, ниже которой
+// Evaluate вычисляет выражение сразу, не отправляя его агенту.
+const DefaultEvaluateThreshold = 100 * time.Millisecond
+
+// Способы вычисления выражения в Evaluate.
+const (
+	EvaluateInline = "inline" // Вычислено сразу при запросе
+	EvaluateQueued = "queued" // Отправлено агенту
+)
+
+// Evaluation описывает результат Evaluate: задачу и принятое решение о способе вычисления.
+type Evaluation struct {
+	Mode      string        `json:"mode"`      // inline или queued
+	Estimate  time.Duration `json:"estimate"`  // Оценка времени вычисления
+	Threshold time.Duration `json:"threshold"` // Порог вычисления при запросе
+	Task      *task.Task    `json:"task"`
+}
+
+// ConfigureEvaluate задает порог оценки времени вычисления для Evaluate;
+// 0 отключает вычисление при запросе.
+func (o *Orchestrator) ConfigureEvaluate(threshold time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.evaluateThreshold = threshold
+}
+
+// Evaluate оценивает время вычисления выражения по времени выполнения операций.
+// Если оценка не превышает порог, выражение вычисляется сразу и возвращается
+// завершенная задача; иначе, а также при запросе трассировки, выражение
+// добавляется для вычисления агентом так же, как в AddCalculation.
+// Как и у агента, результат берется из кэша и сохраняется в него, если не задан
+// NoCache. Оценка времени вычисления расходует суточную квоту клиента.
+func (o *Orchestrator) Evaluate(ctx context.Context, expression, taskID, requestID string, opts CalculationOptions) (*Evaluation, error) {
+	if opts.Tenant == "" {
+		opts.Tenant = task.DefaultTenant
+	}
+	ctx, span := tracer.Start(ctx, "Orchestrator.Evaluate", trace.WithAttributes(
+		attribute.String("task.id", taskID),
+		attribute.String("tenant", opts.Tenant),
+	))
+	defer span.End()
+
+	evaluation, err := o.evaluate(ctx, expression, taskID, requestID, opts)
+	if err != nil {
+		tracing.SetError(span, err)
+		return nil, err
+	}
+	span.SetAttributes(
+		attribute.String("mode", evaluation.Mode),
+		attribute.Stringer("estimate", evaluation.Estimate),
+	)
+	return evaluation, nil
+}
+
+func (o *Orchestrator) evaluate(ctx context.Context, expression, taskID, requestID string, opts CalculationOptions) (*Evaluation, error) {
+	o.mu.Lock()
+	node, expanded, err := o.expandExpression(opts.Tenant, expression)
+	if err != nil {
+		o.mu.Unlock()
+		return nil, rejected(ctx, opts.Tenant, err)
+	}
+	graph, err := expr.BuildGraph(node)
+	if err != nil {
+		o.mu.Unlock()
+		return nil, rejected(ctx, opts.Tenant, fmt.Errorf("%w: %v", ErrInvalidExpression, err))
+	}
+	cost, err := o.costFunc(opts.Tenant)
+	if err != nil {
+		o.mu.Unlock()
+		return nil, rejected(ctx, opts.Tenant, err)
+	}
+	threshold := o.evaluateThreshold
+	evaluation := &Evaluation{
+		Estimate:  graph.Estimate(cost),
+		Threshold: threshold,
+	}
+
+	if opts.Explain || evaluation.Estimate > threshold {
+		o.mu.Unlock()
+		evaluation.Mode = EvaluateQueued
+		evaluation.Task, err = o.AddCalculation(ctx, expression, taskID, requestID, opts)
+		if err != nil {
+			return nil, err
+		}
+		return evaluation, nil
+	}
+
+	t := &task.Task{
+		ID:         taskID,
+		RequestID:  requestID,
+		Expression: expression,
+		Expanded:   expanded,
+		Created:    time.Now(),
+		APIKeyID:   opts.APIKeyID,
+		Owner:      opts.Owner,
+		Tenant:     opts.Tenant,
+	}
+	if !opts.NoCache && o.cacheConfig.TTL > 0 {
+		t.CacheKey = task.CacheKeyFor(opts.Tenant, expr.Canonical(node))
+	}
+	cached, err := o.beginInline(t, graph, opts.Client, evaluation.Estimate)
+	o.mu.Unlock()
+	if err != nil {
+		return nil, rejected(ctx, opts.Tenant, err)
+	}
+
+	if !cached {
+		// Вычисление без блокировки оркестратора с тем же временем выполнения
+		// операций, что и у агента, и общей таблицей результатов операций
+		evaluator := expr.GraphEvaluator{
+			Memo: o.memo,
+			Before: func(op *operation.Operation) {
+				time.Sleep(cost(op))
+			},
+		}
+		_, span := tracer.Start(ctx, "Orchestrator.evaluateInline")
+		o.memo.Acquire(graph.Keys())
+		result, err := evaluator.Eval(graph)
+		o.memo.Release(graph.Keys())
+		tracing.SetError(span, err)
+		span.End()
+		if err != nil {
+			t.Status = "error"
+		} else {
+			t.Status = "completed"
+			t.Result = fmt.Sprintf("%v", result)
+		}
+		t.Finished = time.Now()
+		t.Duration = t.Finished.Sub(t.Created)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	t.TraceParent = tracing.TraceParent(ctx)
+	err = traceDB(ctx, "database.NewTask", func() error { return o.db.NewTask(t) })
+	if err != nil {
+		// Выражение не сохранено, поэтому расход квоты возвращается
+		if err := o.refundQuota(opts.Client, graph, evaluation.Estimate); err != nil {
+			taskLogger(t).Error("can't refund quota", "client", opts.Client, "error", err)
+		}
+		if errors.Is(err, database.ErrDuplicate) {
+			err = ErrDuplicateRequest
+		}
+		return nil, rejected(ctx, opts.Tenant, err)
+	}
+	if t.CacheKey != "" && !cached {
+		if err := o.putCached(t); err != nil {
+			taskLogger(t).Error("can't cache result", "error", err)
+		}
+	}
+
+	source := sourceInline
+	if cached {
+		source = sourceCache
+	}
+	submitted(ctx, t, source, evaluation.Estimate)
+	observeFinished(t)
+
+	evaluation.Mode = EvaluateInline
+	evaluation.Task = t
+	return evaluation, nil
+}
+
+// beginInline готовит вычисление выражения при запросе: проверяет, что
+// идентификатор запроса свободен, списывает оценку времени вычисления с квоты
+// клиента и завершает задачу результатом из кэша, если задан ключ кэша и
+// результат в нем есть. Квота списывается до вычисления, чтобы одновременные
+// запросы не превысили ее. Вызывается под o.mu.
+func (o *Orchestrator) beginInline(t *task.Task, graph *expr.Graph, client string, estimate time.Duration) (cached bool, err error) {
+	_, err = o.db.GetTaskByID(t.RequestID)
+	if err == nil {
+		return false, ErrDuplicateRequest
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return false, err
+	}
+
+	if err := o.checkQuota(client, estimate); err != nil {
+		return false, err
+	}
+	if err := o.chargeQuota(client, graph, estimate); err != nil {
+		return false, err
+	}
+
+	if t.CacheKey == "" {
+		return false, nil
+	}
+	cached, err = o.completeFromCache(t)
+	if err != nil {
+		if err := o.refundQuota(client, graph, estimate); err != nil {
+			taskLogger(t).Error("can't refund quota", "client", client, "error", err)
+		}
+		return false, err
+	}
+	if !cached {
+		o.cacheStats.Misses++
+	}
+	return cached, nil
+}
@@ -0,0 +1,152 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"calcflow/backend/internal/expr"
+	"calcflow/backend/internal/task"
+)
+
+var (
+	// ErrInvalidExpression возвращается, если выражение не удалось разобрать или раскрыть.
+	ErrInvalidExpression = errors.New("invalid expression")
+	// ErrInvalidFunction возвращается, если определение функции некорректно.
+	ErrInvalidFunction = errors.New("invalid function definition")
+)
+
+// DefineFunction регистрирует новую версию пользовательской функции арендатора.
+// Определение задается в виде `f(x, y) = x*x + 3*y`.
+func (o *Orchestrator) DefineFunction(tenant, definition string) (*task.Function, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	name, params, body, err := expr.ParseDefinition(definition)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFunction, err)
+	}
+
+	// Тело функции может ссылаться только на собственные параметры
+	known := make(map[string]bool, len(params))
+	for _, param := range params {
+		known[param] = true
+	}
+	for _, ident := range expr.Idents(body) {
+		if !known[ident] {
+			return nil, fmt.Errorf("%w: unknown variable %q", ErrInvalidFunction, ident)
+		}
+	}
+
+	defs, err := o.loadDefinitions(tenant)
+	if err != nil {
+		return nil, err
+	}
+	if limit := o.tenant(tenant).MaxFunctions; limit > 0 && defs[name] == nil && len(defs) >= limit {
+		return nil, fmt.Errorf("%w: tenant has %d functions, limit is %d", ErrQuotaExceeded, len(defs), limit)
+	}
+	defs[name] = &expr.Definition{Name: name, Params: params, Body: body}
+
+	// Проверяем, что новая версия не образует циклических вызовов
+	deps := make(map[string][]string, len(defs))
+	for n, def := range defs {
+		deps[n] = expr.Calls(def.Body)
+	}
+	if cycle := expr.FindCycle(deps); cycle != nil {
+		return nil, fmt.Errorf("%w: recursion cycle %s", ErrInvalidFunction, strings.Join(cycle, " -> "))
+	}
+	if _, err := expr.Inline(body, lookupIn(defs)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFunction, err)
+	}
+
+	versions, err := o.db.GetFunctionVersions(tenant, name)
+	if err != nil {
+		return nil, err
+	}
+
+	function := &task.Function{
+		Tenant:     tenant,
+		Name:       name,
+		Version:    len(versions) + 1,
+		Params:     params,
+		Body:       body.String(),
+		Definition: definition,
+		Created:    time.Now(),
+	}
+	if err := o.db.NewFunction(function); err != nil {
+		return nil, err
+	}
+
+	return function, nil
+}
+
+// GetFunctions возвращает последние версии всех функций арендатора
+func (o *Orchestrator) GetFunctions(tenant string) ([]*task.Function, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.db.GetFunctions(tenant)
+}
+
+// GetFunctionVersions возвращает все версии функции арендатора
+func (o *Orchestrator) GetFunctionVersions(tenant, name string) ([]*task.Function, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.db.GetFunctionVersions(tenant, name)
+}
+
+// expandExpression разбирает выражение и раскрывает в нем вызовы пользовательских
+// функций арендатора. Возвращает дерево раскрытого выражения и его запись;
+// если выражение не содержит вызовов, запись пустая.
+func (o *Orchestrator) expandExpression(tenant, expression string) (expr.Node, string, error) {
+	node, err := expr.Parse(expression)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidExpression, err)
+	}
+	if idents := expr.Idents(node); len(idents) > 0 {
+		return nil, "", fmt.Errorf("%w: unknown variable %q", ErrInvalidExpression, idents[0])
+	}
+	if len(expr.Calls(node)) == 0 {
+		return node, "", nil
+	}
+
+	defs, err := o.loadDefinitions(tenant)
+	if err != nil {
+		return nil, "", err
+	}
+	expanded, err := expr.Inline(node, lookupIn(defs))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidExpression, err)
+	}
+
+	return expanded, expanded.String(), nil
+}
+
+// loadDefinitions загружает последние версии функций арендатора из базы данных.
+func (o *Orchestrator) loadDefinitions(tenant string) (map[string]*expr.Definition, error) {
+	functions, err := o.db.GetFunctions(tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	defs := make(map[string]*expr.Definition, len(functions))
+	for _, function := range functions {
+		body, err := expr.Parse(function.Body)
+		if err != nil {
+			return nil, fmt.Errorf("stored function %q is corrupted: %v", function.Name, err)
+		}
+		defs[function.Name] = &expr.Definition{Name: function.Name, Params: function.Params, Body: body}
+	}
+
+	return defs, nil
+}
+
+// lookupIn возвращает функцию поиска определения по имени в defs.
+func lookupIn(defs map[string]*expr.Definition) func(string) (*expr.Definition, bool) {
+	return func(name string) (*expr.Definition, bool) {
+		def, ok := defs[name]
+		return def, ok
+	}
+}
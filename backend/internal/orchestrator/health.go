@@ -0,0 +1,198 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"calcflow/backend/internal/task"
+	"calcflow/backend/internal/taskresult"
+)
+
+// ReadyBacklogRatio - доля наибольшего числа ожидающих задач, начиная с которой
+// оркестратор не готов принимать выражения: балансировщик успевает направить
+// запросы другим экземплярам раньше, чем они начнут отклоняться с ErrBacklogFull.
+const ReadyBacklogRatio = 0.9
+
+// Результаты проверок работоспособности.
+const (
+	HealthOK      = "ok"
+	HealthFailing = "failing"
+)
+
+// HealthCheck - результат одной проверки работоспособности.
+type HealthCheck struct {
+	Status string `json:"status"`          // ok или failing
+	Error  string `json:"error,omitempty"` // Причина отказа
+}
+
+// Health - результат проверок работоспособности; Status равен failing,
+// если не прошла хотя бы одна проверка.
+type Health struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks"`
+}
+
+// TenantQueue описывает очередь задач арендатора.
+type TenantQueue struct {
+	Tenant     string        `json:"tenant"`
+	Queued     int           `json:"queued"`      // Задач, ожидающих отправки агентам
+	Running    int           `json:"running"`     // Задач, отправленных агентам
+	Capacity   int           `json:"capacity"`    // Доля мощности агентов арендатора в задачах
+	OldestWait time.Duration `json:"oldest_wait"` // Сколько ждет отправки первая задача очереди
+}
+
+// DebugState - внутреннее состояние оркестратора и агентов для диагностики
+// зависших задач.
+type DebugState struct {
+	Agents            []taskresult.AgentState            `json:"agents"`
+	Queue             QueueStats                         `json:"queue"`
+	Tenants           []TenantQueue                      `json:"tenants"`            // Арендаторы с ожидающими или отправленными задачами
+	Coalesced         int                                `json:"coalesced"`          // Задачи, ожидающие результата идентичной выполняющейся задачи
+	Canceled          int                                `json:"canceled"`           // Отмененные задачи, результат которых агент еще не вернул
+	Watchers          int                                `json:"watchers"`           // Задачи, изменения которых ожидают клиенты
+	Timings           task.CalculationRequest            `json:"timings"`            // Общее время выполнения операций
+	TenantTimings     map[string]task.CalculationRequest `json:"tenant_timings"`     // Время выполнения операций, заданное арендаторами
+	EvaluateThreshold time.Duration                      `json:"evaluate_threshold"` // Порог вычисления при запросе
+	DailyQuota        time.Duration                      `json:"daily_quota"`        // Суточная квота клиентов
+}
+
+// RegisterAgent добавляет агента в проверки работоспособности и диагностику.
+func (o *Orchestrator) RegisterAgent(agent taskresult.AgentReporter) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.agents = append(o.agents, agent)
+}
+
+// CheckHealth проверяет соединение с базой данных и то, что работает хотя бы
+// один агент. С ready дополнительно проверяется, что очередь ожидающих задач
+// заполнена меньше чем на ReadyBacklogRatio.
+func (o *Orchestrator) CheckHealth(ctx context.Context, ready bool) Health {
+	health := Health{Status: HealthOK, Checks: make(map[string]HealthCheck)}
+	check := func(name string, err error) {
+		if err != nil {
+			health.Status = HealthFailing
+			health.Checks[name] = HealthCheck{Status: HealthFailing, Error: err.Error()}
+			return
+		}
+		health.Checks[name] = HealthCheck{Status: HealthOK}
+	}
+
+	check("database", o.db.Ping(ctx))
+	check("agents", o.checkAgents())
+	if ready {
+		check("queue", o.checkSaturation())
+	}
+	return health
+}
+
+// checkAgents проверяет, что хотя бы один зарегистрированный агент работает.
+func (o *Orchestrator) checkAgents() error {
+	var stopped []string
+	for _, agent := range o.registeredAgents() {
+		state := agent.State()
+		if state.Alive {
+			return nil
+		}
+		stopped = append(stopped, state.Name)
+	}
+	if len(stopped) == 0 {
+		return errors.New("no agents registered")
+	}
+	return fmt.Errorf("agents stopped: %s", strings.Join(stopped, ", "))
+}
+
+// checkSaturation проверяет, что очередь ожидающих задач не близка к заполнению.
+func (o *Orchestrator) checkSaturation() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	depth := o.backlog()
+	if o.maxBacklog > 0 && float64(depth) >= ReadyBacklogRatio*float64(o.maxBacklog) {
+		return fmt.Errorf("queue is saturated: %d of %d tasks waiting", depth, o.maxBacklog)
+	}
+	return nil
+}
+
+// registeredAgents возвращает копию списка зарегистрированных агентов.
+func (o *Orchestrator) registeredAgents() []taskresult.AgentReporter {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]taskresult.AgentReporter(nil), o.agents...)
+}
+
+// GetDebugState возвращает состояние агентов, очередей арендаторов и текущие
+// настройки времени выполнения операций.
+func (o *Orchestrator) GetDebugState() (*DebugState, error) {
+	// Состояние агентов собирается без блокировки оркестратора
+	agents := o.registeredAgents()
+	state := &DebugState{
+		Agents:        make([]taskresult.AgentState, len(agents)),
+		TenantTimings: make(map[string]task.CalculationRequest),
+	}
+	for i, agent := range agents {
+		state.Agents[i] = agent.State()
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	timings, err := o.operationTimings(task.DefaultTenant)
+	if err != nil {
+		return nil, err
+	}
+	state.Timings = timings
+	for id, tenant := range o.tenants {
+		if len(tenant.Timings) > 0 {
+			state.TenantTimings[id] = tenant.Timings
+		}
+	}
+
+	state.Queue = QueueStats{
+		Queued:     o.backlog(),
+		Running:    o.dispatched,
+		Capacity:   o.capacity,
+		MaxBacklog: o.maxBacklog,
+	}
+	now := time.Now()
+	state.Tenants = make([]TenantQueue, 0, len(o.queues)+len(o.running))
+	for tenant := range o.running {
+		if _, ok := o.queues[tenant]; !ok {
+			state.Tenants = append(state.Tenants, o.tenantQueue(tenant, now))
+		}
+	}
+	for tenant := range o.queues {
+		state.Tenants = append(state.Tenants, o.tenantQueue(tenant, now))
+	}
+	sort.Slice(state.Tenants, func(i, j int) bool {
+		return state.Tenants[i].Tenant < state.Tenants[j].Tenant
+	})
+
+	for _, followers := range o.inflight {
+		state.Coalesced += len(followers)
+	}
+	state.Canceled = len(o.canceled)
+	state.Watchers = len(o.watchers)
+	state.EvaluateThreshold = o.evaluateThreshold
+	state.DailyQuota = o.dailyQuota
+	return state, nil
+}
+
+// tenantQueue возвращает состояние очереди арендатора. Вызывается под o.mu.
+func (o *Orchestrator) tenantQueue(tenant string, now time.Time) TenantQueue {
+	queue := TenantQueue{
+		Tenant:   tenant,
+		Queued:   len(o.queues[tenant]),
+		Running:  o.running[tenant],
+		Capacity: o.tenantCapacity(tenant),
+	}
+	if queue.Queued > 0 {
+		queue.OldestWait = now.Sub(o.queues[tenant][0].Created)
+	}
+	return queue
+}
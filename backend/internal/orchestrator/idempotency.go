@@ -0,0 +1,92 @@
+package orchestrator
+
+import (
+	"errors"
+	"time"
+
+	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/task"
+)
+
+// IdempotencyTTL - время хранения ключей идемпотентности и ответов на запросы с ними.
+const IdempotencyTTL = 24 * time.Hour
+
+// IdempotencyLease - время, на которое резервируется ключ выполняемого запроса.
+// Если за это время ответ не сохранен и ключ не освобожден (например, процесс
+// остановился посреди запроса), ключ снова свободен и запрос можно повторить.
+const IdempotencyLease = time.Minute
+
+var (
+	// ErrIdempotencyMismatch возвращается, если ключ идемпотентности
+	// повторно использован с другим запросом.
+	ErrIdempotencyMismatch = errors.New("idempotency key was used with a different request")
+
+	// ErrIdempotencyInProgress возвращается, если запрос с тем же ключом еще выполняется.
+	ErrIdempotencyInProgress = errors.New("request with this idempotency key is in progress")
+)
+
+// BeginIdempotent резервирует ключ идемпотентности запроса с отпечатком fingerprint.
+// Если ключ новый, возвращается nil: запрос нужно выполнить и затем вызвать
+// CompleteIdempotent или AbortIdempotent. Если запрос с этим ключом уже выполнен,
+// возвращается сохраненный ответ на него. Резерв без ответа старше IdempotencyLease
+// считается брошенным и передается новому запросу.
+func (o *Orchestrator) BeginIdempotent(tenant, key, fingerprint string) (*task.IdempotencyRecord, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	if err := o.db.DeleteExpiredIdempotencyKeys(now.Add(-IdempotencyTTL)); err != nil {
+		return nil, err
+	}
+
+	// Уникальность ключа обеспечивается первичным ключом таблицы
+	reservation := &task.IdempotencyRecord{
+		Tenant:      tenant,
+		Key:         key,
+		Fingerprint: fingerprint,
+		Created:     now,
+	}
+	err := o.db.ReserveIdempotencyKey(reservation)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, database.ErrDuplicate) {
+		return nil, err
+	}
+
+	record, err := o.db.GetIdempotencyRecord(tenant, key)
+	if err != nil {
+		return nil, err
+	}
+	if !record.Completed && record.Created.Before(now.Add(-IdempotencyLease)) {
+		if err := o.db.DeleteIdempotencyKey(tenant, key); err != nil {
+			return nil, err
+		}
+		return nil, o.db.ReserveIdempotencyKey(reservation)
+	}
+	if record.Fingerprint != fingerprint {
+		return nil, ErrIdempotencyMismatch
+	}
+	if !record.Completed {
+		return nil, ErrIdempotencyInProgress
+	}
+	return record, nil
+}
+
+// CompleteIdempotent сохраняет ответ на запрос с зарезервированным ключом.
+func (o *Orchestrator) CompleteIdempotent(record *task.IdempotencyRecord) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	record.Completed = true
+	return o.db.CompleteIdempotencyKey(record)
+}
+
+// AbortIdempotent освобождает ключ запроса, который не удалось выполнить,
+// чтобы его можно было повторить.
+func (o *Orchestrator) AbortIdempotent(tenant, key string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.db.DeleteIdempotencyKey(tenant, key)
+}
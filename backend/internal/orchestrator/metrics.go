@@ -0,0 +1,102 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"calcflow/backend/internal/metrics"
+	"calcflow/backend/internal/task"
+)
+
+// Способы получения результата добавленного выражения в метрике submittedTotal.
+const (
+	sourceAgent     = "agent"     // Отправлено агенту
+	sourceCache     = "cache"     // Результат найден в кэше
+	sourceCoalesced = "coalesced" // Присоединено к идентичной выполняющейся задаче
+	sourceInline    = "inline"    // Вычислено сразу в Evaluate
+)
+
+var (
+	submittedTotal = metrics.Default.Counter("calcflow_expressions_submitted_total",
+		"Добавленные выражения по арендатору и способу получения результата: agent, cache, coalesced или inline", "tenant", "source")
+	expressionErrors = metrics.Default.Counter("calcflow_expression_errors_total",
+		"Отклоненные выражения по арендатору и коду ошибки", "tenant", "code")
+	finishedTotal = metrics.Default.Counter("calcflow_expressions_finished_total",
+		"Завершенные выражения по арендатору и статусу: completed, error или canceled", "tenant", "status")
+	queueWait = metrics.Default.Histogram("calcflow_queue_wait_seconds",
+		"Время от добавления выражения до отправки задачи агенту", metrics.DefaultBuckets, "tenant")
+	taskDuration = metrics.Default.Histogram("calcflow_task_duration_seconds",
+		"Время от добавления выражения до завершения вычисления (Task.Duration)", metrics.DefaultBuckets, "tenant", "status")
+	queueDepth = metrics.Default.Gauge("calcflow_queue_depth",
+		"Задачи арендатора, ожидающие отправки агентам", "tenant")
+	runningTasks = metrics.Default.Gauge("calcflow_tasks_running",
+		"Задачи арендатора, отправленные агентам", "tenant")
+)
+
+// errorCode возвращает код ошибки добавления выражения для метрики expressionErrors.
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidExpression):
+		return "invalid_expression"
+	case errors.Is(err, ErrDuplicateRequest):
+		return "duplicate_request"
+	case errors.Is(err, ErrQuotaExceeded):
+		return "quota_exceeded"
+	case errors.Is(err, ErrBacklogFull):
+		return "backlog_full"
+	}
+	return "internal"
+}
+
+// rejected учитывает отклоненное выражение арендатора и возвращает err.
+// Внутренние ошибки записываются в журнал с уровнем ERROR, отказы по вине
+// клиента или из-за ограничений - с уровнем DEBUG.
+func rejected(ctx context.Context, tenant string, err error) error {
+	code := errorCode(err)
+	expressionErrors.Inc(tenant, code)
+	level := slog.LevelDebug
+	if code == "internal" {
+		level = slog.LevelError
+	}
+	slog.Log(ctx, level, "expression rejected", "tenant", tenant, "code", code, "error", err)
+	return err
+}
+
+// submitted учитывает добавленное выражение, результат которого получен способом source,
+// в метриках, журнале и участке трассировки из ctx.
+func submitted(ctx context.Context, t *task.Task, source string, cost time.Duration) {
+	submittedTotal.Inc(t.Tenant, source)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("source", source))
+	taskLogger(t).DebugContext(ctx, "expression added", "source", source, "cost", cost)
+}
+
+// taskLogger возвращает журнал с идентификатором задачи и ее арендатором.
+func taskLogger(t *task.Task) *slog.Logger {
+	return slog.With("task_id", t.ID, "tenant", t.Tenant)
+}
+
+// observeFinished учитывает завершение вычисления выражения в метриках и журнале.
+func observeFinished(t *task.Task) {
+	finishedTotal.Inc(t.Tenant, t.Status)
+	taskDuration.Observe(t.Duration.Seconds(), t.Tenant, t.Status)
+	taskLogger(t).Info("expression finished", "status", t.Status, "duration", t.Duration, "cached", t.Cached)
+}
+
+// observeDispatched учитывает отправку задачи агенту.
+func observeDispatched(t *task.Task) {
+	wait := time.Since(t.Created)
+	queueWait.Observe(wait.Seconds(), t.Tenant)
+	taskLogger(t).Debug("task dispatched", "wait", wait)
+}
+
+// updateQueueMetrics обновляет число ожидающих и отправленных агентам задач
+// арендатора. Вызывается под o.mu.
+func (o *Orchestrator) updateQueueMetrics(tenant string) {
+	queueDepth.Set(float64(len(o.queues[tenant])), tenant)
+	runningTasks.Set(float64(o.running[tenant]), tenant)
+}
@@ -1,59 +1,254 @@
 package orchestrator
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"calcflow/backend/internal/auth"
 	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/expr"
+	"calcflow/backend/internal/operation"
 	"calcflow/backend/internal/task"
 	"calcflow/backend/internal/taskresult"
+	"calcflow/backend/internal/tracing"
 )
 
+// ErrInvalidOperation возвращается при попытке задать время выполнения
+// неизвестной операции или некорректную длительность.
+var ErrInvalidOperation = errors.New("invalid operation timing")
+
+// ErrDuplicateRequest возвращается при попытке добавить выражение с уже использованным requestID.
+var ErrDuplicateRequest = errors.New("request ID already exists")
+
+// ErrTaskFinished возвращается при попытке отменить уже завершенную задачу.
+var ErrTaskFinished = errors.New("task already finished")
+
+// CalculationOptions задает параметры добавления выражения для вычисления.
+type CalculationOptions struct {
+	Tenant  string // Арендатор: его функции, время выполнения операций и ограничения
+	Explain bool   // Записывать ли пошаговую трассировку вычисления
+	NoCache bool   // Вычислить выражение заново, не используя кэш результатов
+
+	APIKeyID string // Ключ API, с которым добавлено выражение
+	Owner    string // Пользователь, добавивший выражение
+	Client   string // Клиент, расходующий суточную квоту; пустой не ограничен квотой
+}
+
 // Orchestrator представляет оркестратор, управляющий задачами.
 type Orchestrator struct {
 	tasks     map[string]*task.Task // Мапа для хранения задач
 	mu        sync.Mutex
 	processor taskresult.TaskProcessor
 	db        *database.Store // Ссылка на сущность базы данных
+
+	cacheConfig CacheConfig
+	cacheStats  CacheStats
+	inflight    map[string][]*task.Task // Задачи, ожидающие результата выполняющейся задачи с тем же выражением
+	memo        *expr.Memo              // Общая таблица результатов операций выполняющихся задач
+
+	canceled          map[string]struct{} // Отмененные задачи, результат которых еще не получен
+	watchers          map[string]*watcher // Подписки на изменение задач
+	evaluateThreshold time.Duration       // Порог оценки времени вычисления при запросе в Evaluate
+	tokens            *auth.Signer        // Подпись токенов доступа пользователей
+	dailyQuota        time.Duration       // Суточная квота клиентов
+
+	tenants    map[string]*task.Tenant // Сохраненные настройки арендаторов
+	queues     map[string][]*task.Task // Задачи арендаторов, ожидающие отправки агентам
+	rotation   []string                // Арендаторы с ожидающими задачами в порядке обхода
+	running    map[string]int          // Задачи арендаторов, отправленные агентам
+	dispatched int                     // Всего задач, отправленных агентам
+	capacity   int                     // Наибольшее число задач, одновременно отправленных агентам
+	maxBacklog int                     // Наибольшее число задач, ожидающих отправки агентам
+
+	queued map[string]trace.Span      // Участки трассировки ожидания задач в очередях
+	agents []taskresult.AgentReporter // Агенты для проверок работоспособности и диагностики
 }
 
 // NewOrchestrator создает новый экземпляр оркестратора.
 func NewOrchestrator(db *database.Store, processor taskresult.TaskProcessor) (*Orchestrator, error) {
-	return &Orchestrator{
-		tasks:     make(map[string]*task.Task),
-		db:        db,
-		processor: processor,
-	}, nil
+	o := &Orchestrator{
+		tasks:       make(map[string]*task.Task),
+		db:          db,
+		processor:   processor,
+		cacheConfig: DefaultCacheConfig,
+		inflight:    make(map[string][]*task.Task),
+		memo:        expr.NewMemo(),
+		canceled:    make(map[string]struct{}),
+		watchers:    make(map[string]*watcher),
+
+		evaluateThreshold: DefaultEvaluateThreshold,
+		tokens:            newSigner(),
+		dailyQuota:        DefaultDailyQuota,
+
+		tenants:    make(map[string]*task.Tenant),
+		queues:     make(map[string][]*task.Task),
+		running:    make(map[string]int),
+		capacity:   DefaultCapacity,
+		maxBacklog: DefaultMaxBacklog,
+
+		queued: make(map[string]trace.Span),
+	}
+	if err := o.loadTenants(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// AddCalculation добавляет новое арифметическое выражение для вычисления и возвращает задачу.
+// Вызовы пользовательских функций арендатора раскрываются перед отправкой агенту.
+// Если результат выражения есть в кэше или такое же выражение уже вычисляется,
+// задача не отправляется агенту. Задачи с трассировкой всегда вычисляются заново.
+// Задачи отправляются агентам в пределах доли мощности арендатора; если очередь
+// ожидающих задач заполнена, выражение отклоняется с ErrBacklogFull.
+// Оценка времени вычисления выражения расходует суточную квоту клиента.
+// Контекст трассировки из ctx сохраняется в задаче и передается агенту.
+func (o *Orchestrator) AddCalculation(ctx context.Context, expression, taskID, requestID string, opts CalculationOptions) (*task.Task, error) {
+	if opts.Tenant == "" {
+		opts.Tenant = task.DefaultTenant
+	}
+	ctx, span := tracer.Start(ctx, "Orchestrator.AddCalculation", trace.WithAttributes(
+		attribute.String("task.id", taskID),
+		attribute.String("tenant", opts.Tenant),
+	))
+	defer span.End()
+
+	created, err := o.addCalculation(ctx, expression, taskID, requestID, opts)
+	if err != nil {
+		tracing.SetError(span, err)
+		return nil, rejected(ctx, opts.Tenant, err)
+	}
+	span.SetAttributes(attribute.String("task.status", created.Status))
+	return created, nil
 }
 
-// AddCalculation добавляет новое арифметическое выражение для вычисления
-func (o *Orchestrator) AddCalculation(expression, taskID, requestID string) error {
+func (o *Orchestrator) addCalculation(ctx context.Context, expression, taskID, requestID string, opts CalculationOptions) (*task.Task, error) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
+	if err := o.checkBacklog(); err != nil {
+		return nil, err
+	}
+	if err := o.checkPendingQuota(opts.Tenant); err != nil {
+		return nil, err
+	}
+
+	node, expanded, err := o.expandExpression(opts.Tenant, expression)
+	if err != nil {
+		return nil, err
+	}
+
+	// Построение графа операций с устранением общих подвыражений
+	graph, err := expr.BuildGraph(node)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExpression, err)
+	}
+	cost, err := o.estimate(opts.Tenant, graph)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.checkQuota(opts.Client, cost); err != nil {
+		return nil, err
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Stringer("cost", cost))
+	cacheKey := task.CacheKeyFor(opts.Tenant, expr.Canonical(node))
+
 	task := &task.Task{
 		ID:         taskID,
 		RequestID:  requestID,
 		Expression: expression,
+		Expanded:   expanded,
 		Status:     "pending",
 		Created:    time.Now(),
+		Explain:    opts.Explain,
+		APIKeyID:   opts.APIKeyID,
+		Owner:      opts.Owner,
+		Tenant:     opts.Tenant,
+
+		TraceParent: tracing.TraceParent(ctx),
+	}
+
+	resolved := false
+	if !opts.NoCache && !opts.Explain && o.cacheConfig.TTL > 0 {
+		task.CacheKey = cacheKey
+		resolved, err = o.resolveFromCache(task)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !resolved {
+		task.Graph = graph
 	}
 
 	// Сохранение задачи в базе данных
-	err := o.db.NewTask(task)
+	// Уникальность requestID обеспечивается ограничением таблицы
+	err = traceDB(ctx, "database.NewTask", func() error { return o.db.NewTask(task) })
+	if errors.Is(err, database.ErrDuplicate) {
+		o.forgetInflight(task)
+		return nil, ErrDuplicateRequest
+	}
 	if err != nil {
-		return err
+		o.forgetInflight(task)
+		return nil, err
+	}
+	// Выражение уже сохранено, поэтому ошибка учета расхода квоты не мешает его вычислению
+	if err := o.chargeQuota(opts.Client, graph, cost); err != nil {
+		taskLogger(task).Error("can't charge quota", "client", opts.Client, "error", err)
 	}
 
-	// Отправка задачи на выполнение агенту
-	o.processor.EnqueueTask(task)
+	// Копия задачи для ответа, так как исходную задачу изменяют агент
+	// или завершение идентичной задачи
+	created := *task
+	if resolved {
+		if task.Cached {
+			submitted(ctx, task, sourceCache, cost)
+			observeFinished(task)
+		} else {
+			submitted(ctx, task, sourceCoalesced, cost)
+		}
+		return &created, nil
+	}
 
-	// Возвращаем ID задачи
-	return nil
+	// Постановка задачи в очередь арендатора для отправки агенту
+	submitted(ctx, task, sourceAgent, cost)
+	o.enqueue(task)
+
+	// Возвращаем задачу
+	return &created, nil
 }
 
-// GetExpressionByID возвращает значение арифметического выражения по его идентификатору
-func (o *Orchestrator) GetExpressionByID(requestID string) (*task.Task, error) {
+// EnqueueTask ставит задачу в очередь ее арендатора для выполнения агентом
+func (o *Orchestrator) EnqueueTask(task *task.Task) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.enqueue(task)
+}
+
+// Memo возвращает общую таблицу результатов операций, через которую агенты
+// разделяют результаты одинаковых операций одновременно выполняющихся задач
+func (o *Orchestrator) Memo() *expr.Memo {
+	return o.memo
+}
+
+// GetExpression возвращает арифметическое выражение по идентификатору задачи,
+// если оно доступно access.
+func (o *Orchestrator) GetExpression(access Access, taskID string) (*task.Task, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.accessibleTask(access, taskID)
+}
+
+// GetExpressionByID возвращает значение арифметического выражения по его идентификатору,
+// если оно доступно access.
+func (o *Orchestrator) GetExpressionByID(access Access, requestID string) (*task.Task, error) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
@@ -61,43 +256,117 @@ func (o *Orchestrator) GetExpressionByID(requestID string) (*task.Task, error) {
 	if err != nil {
 		return nil, err
 	}
+	if !access.allows(task) {
+		return nil, database.ErrNotFound
+	}
 
 	return task, nil
 }
 
-// GetExpressionsWithStatus возвращает список арифметических выражений с их статусами
-func (o *Orchestrator) GetExpressions() ([]*task.Task, error) {
+// CancelExpression отменяет вычисление задачи, которое еще не завершено, если
+// задача доступна access. Задача, еще не отправленная агенту, удаляется из очереди,
+// и выражение вычисляет первая присоединенная к ней задача; иначе агент может
+// закончить вычисление, но его результат будет отброшен.
+func (o *Orchestrator) CancelExpression(access Access, taskID string) (*task.Task, error) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
-	expressions, err := o.db.GetAllTasks()
+	t, err := o.accessibleTask(access, taskID)
 	if err != nil {
 		return nil, err
 	}
+	if t.Done() {
+		return t, ErrTaskFinished
+	}
+
+	t.Status = "canceled"
+	t.Finished = time.Now()
+	t.Duration = t.Finished.Sub(t.Created)
+	if err := o.db.UpdateTask(t); err != nil {
+		return nil, err
+	}
+	if o.unqueue(t) {
+		o.promoteFollower(t)
+	} else {
+		o.canceled[taskID] = struct{}{}
+	}
+	o.notify(t)
+	observeFinished(t)
+
+	return t, nil
+}
+
+// ListExpressions возвращает страницу арифметических выражений с учетом фильтров
+// и сортировки, а также курсор следующей страницы. Фильтры query.Tenant и
+// query.Owner ограничивают выборку выражениями арендатора и пользователя
+func (o *Orchestrator) ListExpressions(query database.TaskQuery) ([]*task.Task, string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 
-	return expressions, nil
+	return o.db.ListTasks(query)
 }
 
-// GetAvailableOperations возвращает список доступных операций и времени их выполнения
-func (o *Orchestrator) GetAvailableOperations() (*task.CalculationRequest, error) {
+// GetAvailableOperations возвращает список зарегистрированных операций и времени их
+// выполнения для арендатора. Время, заданное арендатором, заменяет общее; для операций,
+// время которых не задано, возвращается время по умолчанию из реестра.
+func (o *Orchestrator) GetAvailableOperations(tenant string) (task.CalculationRequest, error) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
-	calcRequest, err := o.db.GetCalculateTime()
+	return o.operationTimings(tenant)
+}
+
+// operationTimings возвращает время выполнения всех операций для арендатора. Вызывается под o.mu.
+func (o *Orchestrator) operationTimings(tenant string) (task.CalculationRequest, error) {
+	stored, err := o.db.GetCalculateTime()
 	if err != nil {
 		return nil, err
 	}
 
-	return calcRequest, nil
+	calcRequest := make(task.CalculationRequest)
+	for _, op := range operation.Default.All() {
+		calcRequest[op.Name] = op.DefaultCost.String()
+		if duration, ok := stored[op.Name]; ok {
+			calcRequest[op.Name] = duration
+		}
+		if duration, ok := o.tenant(tenant).Timings[op.Name]; ok {
+			calcRequest[op.Name] = duration
+		}
+	}
 
+	return calcRequest, nil
 }
 
-// UpdateCalculateTim обновляет значений времени выполнения для каждой операции
-func (o *Orchestrator) UpdateCalculateTime(newRequestTime task.CalculationRequest) error {
+// UpdateCalculateTim обновляет значений времени выполнения для переданных операций.
+// Для арендатора по умолчанию меняется общее время выполнения, для остальных -
+// время, заданное арендатором. Названия операций не зависят от регистра.
+func (o *Orchestrator) UpdateCalculateTime(tenant string, newRequestTime task.CalculationRequest) error {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
-	err := o.db.UpdateCalculateTime(newRequestTime)
+	normalized, err := normalizeTimings(newRequestTime)
+	if err != nil {
+		return err
+	}
+
+	if tenant != "" && tenant != task.DefaultTenant {
+		t := *o.tenant(tenant)
+		t.Timings = make(task.CalculationRequest, len(t.Timings)+len(normalized))
+		for name, duration := range o.tenant(tenant).Timings {
+			t.Timings[name] = duration
+		}
+		for name, duration := range normalized {
+			t.Timings[name] = duration
+		}
+		t.Updated = time.Now()
+		if err := o.db.SaveTenant(&t); err != nil {
+			return err
+		}
+		o.tenants[tenant] = &t
+		return nil
+	}
+
+	err = o.db.UpdateCalculateTime(normalized)
 	if err != nil {
 		return err
 	}
@@ -108,30 +377,77 @@ func (o *Orchestrator) UpdateCalculateTime(newRequestTime task.CalculationReques
 
 // ReceiveResult принимает результат обработки данных от агента
 func (o *Orchestrator) ReceiveResult(task *task.Task) error {
+	ctx, span := tracer.Start(tracing.ContextFrom(task.TraceParent), "Orchestrator.ReceiveResult", trace.WithAttributes(
+		attribute.String("task.id", task.ID),
+		attribute.String("task.status", task.Status),
+	))
+	defer span.End()
+
+	err := o.receiveResult(ctx, task)
+	tracing.SetError(span, err)
+	return err
+}
+
+func (o *Orchestrator) receiveResult(ctx context.Context, task *task.Task) (err error) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
+	// Результат передается в кэш и присоединенным задачам, даже если его не удалось
+	// сохранить в задаче: иначе они навсегда останутся невычисленными
+	if task.CacheKey != "" {
+		defer func() {
+			err = errors.Join(err, o.completeCached(task))
+		}()
+	}
+
 	task.Finished = time.Now()               // Время окончания вычисления операции
 	task.Duration = time.Since(task.Created) // Время вычисления выражения
 
-	err := o.db.UpdateTask(task)
-	if err != nil {
-		return err
+	// Агент освободился: отправляем ему следующую задачу
+	o.release(task.Tenant)
+
+	// Результат отмененной задачи не сохраняется, но передается
+	// присоединенным к ней задачам и в кэш
+	_, canceled := o.canceled[task.ID]
+	delete(o.canceled, task.ID)
+
+	if !canceled {
+		err := traceDB(ctx, "database.UpdateTask", func() error { return o.db.UpdateTask(task) })
+		if err != nil {
+			return err
+		}
+		o.notify(task)
+		observeFinished(task)
+	} else {
+		taskLogger(task).Debug("result of canceled expression discarded", "status", task.Status)
+	}
+
+	// Сохранение трассировки, если она запрашивалась
+	if task.Explain && !canceled {
+		err := traceDB(ctx, "database.SaveTrace", func() error { return o.db.SaveTrace(task.ID, task.Trace) })
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// isDuplicateRequest проверяет, что такой requestID уникальный
-func (o *Orchestrator) AlreadyExistsRequest(requestID string) (bool, error) {
+// GetTrace возвращает задачу и пошаговую трассировку ее вычисления,
+// если она доступна access
+func (o *Orchestrator) GetTrace(access Access, taskID string) (*task.Task, []task.TraceStep, error) {
 	o.mu.Lock()
 	defer o.mu.Unlock()
 
-	unique, err := o.db.AlreadyExistsRequest(requestID)
+	task, err := o.accessibleTask(access, taskID)
 	if err != nil {
-		return false, err
+		return nil, nil, err
 	}
 
-	return unique, nil
+	steps, err := o.db.GetTrace(taskID)
+	if err != nil {
+		return nil, nil, err
+	}
 
+	return task, steps, nil
 }
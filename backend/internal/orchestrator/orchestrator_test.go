@@ -0,0 +1,517 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/task"
+	"calcflow/backend/internal/taskresult"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// fakeProcessor принимает задачи вместо агента, пока accept равен true.
+type fakeProcessor struct {
+	mu       sync.Mutex
+	accept   bool
+	accepted []*task.Task
+}
+
+func (p *fakeProcessor) EnqueueTask(t *task.Task) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.accept {
+		return taskresult.ErrQueueFull
+	}
+	p.accepted = append(p.accepted, t)
+	return nil
+}
+
+func (p *fakeProcessor) setAccept(accept bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.accept = accept
+}
+
+func (p *fakeProcessor) taken() []*task.Task {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]*task.Task(nil), p.accepted...)
+}
+
+func newTestOrchestrator(t *testing.T, processor *fakeProcessor) *Orchestrator {
+	t.Helper()
+	return newTestOrchestratorAt(t, filepath.Join(t.TempDir(), "test.db"), processor)
+}
+
+func newTestOrchestratorAt(t *testing.T, path string, processor *fakeProcessor) *Orchestrator {
+	t.Helper()
+	db, err := database.New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := NewOrchestrator(db, processor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func addExpression(t *testing.T, o *Orchestrator, expression string, opts CalculationOptions) *task.Task {
+	t.Helper()
+	id := task.NewID()
+	created, err := o.AddCalculation(context.Background(), expression, id, id, opts)
+	if err != nil {
+		t.Fatalf("AddCalculation(%q): %v", expression, err)
+	}
+	return created
+}
+
+func TestCancelQueuedLeaderPromotesFollower(t *testing.T) {
+	processor := &fakeProcessor{}
+	o := newTestOrchestrator(t, processor)
+	access := Access{Tenant: task.DefaultTenant}
+
+	leader := addExpression(t, o, "2+2", CalculationOptions{})
+	first := addExpression(t, o, "2+2", CalculationOptions{})
+	second := addExpression(t, o, "2 + 2", CalculationOptions{})
+	if got := o.GetQueueStats().Queued; got != 1 {
+		t.Fatalf("queued = %d, want 1", got)
+	}
+
+	if _, err := o.CancelExpression(access, leader.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := o.GetQueueStats().Queued; got != 1 {
+		t.Fatalf("queued after cancel = %d, want 1 (promoted follower)", got)
+	}
+
+	processor.setAccept(true)
+	o.ConfigureCapacity(DefaultCapacity)
+	taken := processor.taken()
+	if len(taken) != 1 || taken[0].ID != first.ID {
+		t.Fatalf("dispatched %v, want the first follower %s", taken, first.ID)
+	}
+
+	result := taken[0]
+	result.Status = "completed"
+	result.Result = "4"
+	if err := o.ReceiveResult(result); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{first.ID, second.ID} {
+		got, err := o.GetExpression(access, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != "completed" || got.Result != "4" {
+			t.Errorf("task %s: status %q result %q, want completed 4", id, got.Status, got.Result)
+		}
+	}
+}
+
+func TestCancelQueuedLeaderWithoutFollowers(t *testing.T) {
+	processor := &fakeProcessor{}
+	o := newTestOrchestrator(t, processor)
+	access := Access{Tenant: task.DefaultTenant}
+
+	leader := addExpression(t, o, "3*3", CalculationOptions{})
+	if _, err := o.CancelExpression(access, leader.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := o.GetQueueStats().Queued; got != 0 {
+		t.Fatalf("queued after cancel = %d, want 0", got)
+	}
+
+	// Новое такое же выражение не должно присоединяться к отмененной задаче
+	again := addExpression(t, o, "3*3", CalculationOptions{})
+	if got := o.GetQueueStats().Queued; got != 1 {
+		t.Fatalf("queued = %d, want 1: task %s was coalesced onto the canceled one", got, again.ID)
+	}
+}
+
+func TestCacheIsolatesTenants(t *testing.T) {
+	processor := &fakeProcessor{accept: true}
+	o := newTestOrchestrator(t, processor)
+
+	a := addExpression(t, o, "5+5", CalculationOptions{Tenant: "a"})
+	b := addExpression(t, o, "5+5", CalculationOptions{Tenant: "b"})
+	if taken := processor.taken(); len(taken) != 2 {
+		t.Fatalf("dispatched %d tasks, want 2: tenant b coalesced onto tenant a", len(taken))
+	}
+
+	result := processor.taken()[0]
+	result.Status = "completed"
+	result.Result = "10"
+	if err := o.ReceiveResult(result); err != nil {
+		t.Fatal(err)
+	}
+	got, err := o.GetExpression(Access{Tenant: "b"}, b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "pending" {
+		t.Errorf("tenant b task status %q after tenant a finished, want pending", got.Status)
+	}
+
+	c := addExpression(t, o, "5+5", CalculationOptions{Tenant: "c"})
+	if c.Cached {
+		t.Errorf("tenant c got tenant a's cached result")
+	}
+	again := addExpression(t, o, "5+5", CalculationOptions{Tenant: "a"})
+	if !again.Cached || again.Result != "10" {
+		t.Errorf("tenant a task %s: cached %v result %q, want its own cached result", a.ID, again.Cached, again.Result)
+	}
+}
+
+func TestFollowersCompleteWhenLeaderUpdateFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	processor := &fakeProcessor{accept: true}
+	o := newTestOrchestratorAt(t, path, processor)
+	access := Access{Tenant: task.DefaultTenant}
+
+	leader := addExpression(t, o, "7-2", CalculationOptions{})
+	follower := addExpression(t, o, "7-2", CalculationOptions{})
+
+	// Сохранение результата ведущей задачи завершается ошибкой
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(`CREATE TRIGGER fail_leader BEFORE UPDATE ON tasks WHEN NEW.id = '` + leader.ID + `'
+		BEGIN SELECT RAISE(FAIL, 'update failed'); END`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := processor.taken()[0]
+	result.Status = "completed"
+	result.Result = "5"
+	if err := o.ReceiveResult(result); err == nil {
+		t.Fatal("ReceiveResult succeeded, want the update error")
+	}
+
+	got, err := o.GetExpression(access, follower.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "completed" || got.Result != "5" {
+		t.Errorf("follower status %q result %q, want completed 5", got.Status, got.Result)
+	}
+	again := addExpression(t, o, "7-2", CalculationOptions{})
+	if !again.Cached {
+		t.Errorf("new task %s was not resolved from the cache", again.ID)
+	}
+}
+
+func TestExpandedExpressionLimit(t *testing.T) {
+	o := newTestOrchestrator(t, &fakeProcessor{accept: true})
+
+	// Каждая следующая функция раскрывается в дерево вдвое больше предыдущей
+	definitions := []string{"f1(x) = x + x"}
+	for i := 2; i <= 12; i++ {
+		definitions = append(definitions, fmt.Sprintf("f%d(x) = f%d(x) + f%d(x)", i, i-1, i-1))
+	}
+	for _, definition := range definitions {
+		if _, err := o.DefineFunction(task.DefaultTenant, definition); err != nil {
+			t.Fatalf("DefineFunction(%q): %v", definition, err)
+		}
+	}
+	if _, err := o.DefineFunction(task.DefaultTenant, "f13(x) = f12(x) + f12(x)"); !errors.Is(err, ErrInvalidFunction) {
+		t.Errorf("DefineFunction f13: %v, want ErrInvalidFunction", err)
+	}
+
+	addExpression(t, o, "f12(1)", CalculationOptions{})
+	id := task.NewID()
+	_, err := o.AddCalculation(context.Background(), "f12(1) * f12(2)", id, id, CalculationOptions{})
+	if !errors.Is(err, ErrInvalidExpression) {
+		t.Errorf("AddCalculation: %v, want ErrInvalidExpression", err)
+	}
+}
+
+func TestUsersAreScopedToTenant(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// Прежние версии создавали уникальный индекс имен без арендатора
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(`CREATE TABLE users (id text PRIMARY KEY, username text, password_hash text, tenant text, created datetime)`).Error
+	if err == nil {
+		err = db.Exec(`CREATE UNIQUE INDEX idx_users_username ON users (username)`).Error
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := newTestOrchestratorAt(t, path, &fakeProcessor{})
+
+	alice, err := o.Register("a", "alice", "password-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := o.Register("b", "alice", "password-b")
+	if err != nil {
+		t.Fatalf("Register the same name in another tenant: %v", err)
+	}
+	if _, err := o.Register("a", "alice", "password-c"); !errors.Is(err, ErrUserExists) {
+		t.Errorf("Register a duplicate name: %v, want ErrUserExists", err)
+	}
+
+	tests := []struct {
+		tenant   string
+		password string
+		want     *task.User
+	}{
+		{"a", "password-a", alice},
+		{"b", "password-b", other},
+		{"a", "password-b", nil},
+		{"b", "password-a", nil},
+		{task.DefaultTenant, "password-a", nil},
+	}
+	for _, tt := range tests {
+		token, err := o.Login(tt.tenant, "alice", tt.password)
+		if tt.want == nil {
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Login(%q, %q): %v, want ErrInvalidCredentials", tt.tenant, tt.password, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Login(%q, %q): %v", tt.tenant, tt.password, err)
+			continue
+		}
+		if token.User.ID != tt.want.ID {
+			t.Errorf("Login(%q, %q) returned user %s, want %s", tt.tenant, tt.password, token.User.ID, tt.want.ID)
+		}
+	}
+}
+
+func TestBeginIdempotentReservesKeyOnce(t *testing.T) {
+	o := newTestOrchestrator(t, &fakeProcessor{})
+
+	// Ключ резервирует только один из одновременных запросов
+	const requests = 20
+	errs := make([]error, requests)
+	records := make([]*task.IdempotencyRecord, requests)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			records[i], errs[i] = o.BeginIdempotent("a", "key", "fingerprint")
+		}(i)
+	}
+	wg.Wait()
+
+	reserved := 0
+	for i, err := range errs {
+		switch {
+		case err == nil && records[i] == nil:
+			reserved++
+		case !errors.Is(err, ErrIdempotencyInProgress):
+			t.Errorf("BeginIdempotent: %v, want ErrIdempotencyInProgress", err)
+		}
+	}
+	if reserved != 1 {
+		t.Fatalf("%d requests reserved the key, want 1", reserved)
+	}
+
+	// Тот же ключ другого арендатора свободен
+	if record, err := o.BeginIdempotent("b", "key", "fingerprint"); err != nil || record != nil {
+		t.Errorf("BeginIdempotent in another tenant: %v %v, want a new reservation", record, err)
+	}
+
+	err := o.CompleteIdempotent(&task.IdempotencyRecord{Tenant: "a", Key: "key", StatusCode: 201, Body: []byte("created")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := o.BeginIdempotent("a", "key", "fingerprint")
+	if err != nil || record == nil || record.StatusCode != 201 || string(record.Body) != "created" {
+		t.Errorf("BeginIdempotent after completion: %+v %v, want the saved response", record, err)
+	}
+	if _, err := o.BeginIdempotent("a", "key", "other"); !errors.Is(err, ErrIdempotencyMismatch) {
+		t.Errorf("BeginIdempotent with another fingerprint: %v, want ErrIdempotencyMismatch", err)
+	}
+
+	// Освобожденный ключ можно зарезервировать снова
+	if err := o.AbortIdempotent("b", "key"); err != nil {
+		t.Fatal(err)
+	}
+	if record, err := o.BeginIdempotent("b", "key", "other"); err != nil || record != nil {
+		t.Errorf("BeginIdempotent after abort: %v %v, want a new reservation", record, err)
+	}
+}
+
+func TestBeginIdempotentTakesOverExpiredLease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	o := newTestOrchestratorAt(t, path, &fakeProcessor{})
+
+	for _, key := range []string{"stale", "fresh"} {
+		if _, err := o.BeginIdempotent("a", key, "fingerprint"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Запрос с ключом stale не завершился: процесс остановился, не освободив ключ
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Exec(`UPDATE idempotency_records SET created = ? WHERE key = 'stale'`,
+		time.Now().Add(-IdempotencyLease-time.Second)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := o.BeginIdempotent("a", "fresh", "fingerprint"); !errors.Is(err, ErrIdempotencyInProgress) {
+		t.Errorf("BeginIdempotent fresh: %v, want ErrIdempotencyInProgress", err)
+	}
+	if record, err := o.BeginIdempotent("a", "stale", "other"); err != nil || record != nil {
+		t.Fatalf("BeginIdempotent stale: %v %v, want a new reservation", record, err)
+	}
+	if _, err := o.BeginIdempotent("a", "stale", "other"); !errors.Is(err, ErrIdempotencyInProgress) {
+		t.Errorf("BeginIdempotent after taking over: %v, want ErrIdempotencyInProgress", err)
+	}
+}
+
+func evaluate(o *Orchestrator, expression, requestID string, opts CalculationOptions) (*Evaluation, error) {
+	return o.Evaluate(context.Background(), expression, task.NewID(), requestID, opts)
+}
+
+func TestEvaluate(t *testing.T) {
+	processor := &fakeProcessor{accept: true}
+	o := newTestOrchestrator(t, processor)
+
+	first, err := evaluate(o, "2 * 3", "first", CalculationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Mode != EvaluateInline || first.Task.Status != "completed" || first.Task.Result != "6" || first.Task.Cached {
+		t.Fatalf("Evaluate = %s %+v, want inline 6", first.Mode, first.Task)
+	}
+	if first.Estimate != 20*time.Millisecond {
+		t.Errorf("estimate %v, want the default cost of multiplication", first.Estimate)
+	}
+
+	// Вычисленный результат сохраняется в кэш и используется повторно
+	again, err := evaluate(o, "3*2", "again", CalculationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Mode != EvaluateInline || !again.Task.Cached || again.Task.Result != "6" {
+		t.Errorf("repeated Evaluate = %s %+v, want the cached result", again.Mode, again.Task)
+	}
+	uncached, err := evaluate(o, "3*2", "uncached", CalculationOptions{NoCache: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uncached.Task.Cached || uncached.Task.Result != "6" {
+		t.Errorf("Evaluate with NoCache = %+v, want a computed result", uncached.Task)
+	}
+	stats, err := o.GetCacheStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("cache hits %d misses %d, want 1 and 1", stats.Hits, stats.Misses)
+	}
+	queued := addExpression(t, o, "2*3", CalculationOptions{})
+	if !queued.Cached || queued.Result != "6" {
+		t.Errorf("AddCalculation after Evaluate = %+v, want the cached result", queued)
+	}
+
+	// Дорогое выражение и выражение с трассировкой отправляются агенту
+	o.ConfigureEvaluate(10 * time.Millisecond)
+	for _, opts := range []CalculationOptions{{}, {Explain: true}} {
+		evaluation, err := evaluate(o, "7 * 8", task.NewID(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if evaluation.Mode != EvaluateQueued || evaluation.Task.Status != "pending" {
+			t.Errorf("Evaluate with %+v = %s %+v, want queued", opts, evaluation.Mode, evaluation.Task)
+		}
+	}
+	if got := len(processor.taken()); got != 2 {
+		t.Errorf("%d tasks dispatched, want 2", got)
+	}
+}
+
+func TestEvaluateRejectsDuplicateBeforeEvaluating(t *testing.T) {
+	o := newTestOrchestrator(t, &fakeProcessor{accept: true})
+	o.ConfigureEvaluate(10 * time.Second)
+	if err := o.UpdateCalculateTime(task.DefaultTenant, task.CalculationRequest{"summation": "2s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := evaluate(o, "1", "taken", CalculationOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	_, err := evaluate(o, "1 + 1", "taken", CalculationOptions{Client: "c"})
+	if !errors.Is(err, ErrDuplicateRequest) {
+		t.Fatalf("Evaluate: %v, want ErrDuplicateRequest", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("duplicate rejected after %v, want before evaluation", elapsed)
+	}
+	usage, err := o.GetUsage("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Used != 0 || usage.Expressions != 0 {
+		t.Errorf("usage %+v after a rejected expression, want none", usage)
+	}
+}
+
+func TestEvaluateQuotaConcurrent(t *testing.T) {
+	o := newTestOrchestrator(t, &fakeProcessor{accept: true})
+	o.ConfigureEvaluate(time.Second)
+	o.ConfigureQuota(100 * time.Millisecond)
+	if err := o.UpdateCalculateTime(task.DefaultTenant, task.CalculationRequest{"summation": "40ms"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Квота вмещает две оценки по 40ms; одновременные запросы не превышают ее
+	const requests = 6
+	errs := make([]error, requests)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = evaluate(o, fmt.Sprintf("%d + 1", i), task.NewID(), CalculationOptions{Client: "c", NoCache: true})
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrQuotaExceeded):
+			t.Errorf("Evaluate: %v, want ErrQuotaExceeded", err)
+		}
+	}
+	if succeeded != 2 {
+		t.Errorf("%d expressions evaluated, want 2", succeeded)
+	}
+	usage, err := o.GetUsage("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Used != 80*time.Millisecond || usage.Expressions != 2 {
+		t.Errorf("usage %+v, want 2 expressions and 80ms", usage)
+	}
+}
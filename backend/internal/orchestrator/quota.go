@@ -0,0 +1,157 @@
+package orchestrator
+
+import (
+	"fmt"
+	"time"
+
+	"calcflow/backend/internal/expr"
+	"calcflow/backend/internal/operation"
+	"calcflow/backend/internal/task"
+)
+
+// DefaultDailyQuota - суточная квота клиента по умолчанию: оценка суммарного
+// времени вычисления выражений, добавленных за сутки по UTC.
+const DefaultDailyQuota = time.Hour
+
+// QuotaError возвращается, если выражение превышает суточную квоту клиента.
+// Соответствует ErrQuotaExceeded в errors.Is.
+type QuotaError struct {
+	Used  time.Duration // Израсходовано за сутки
+	Cost  time.Duration // Оценка времени вычисления выражения
+	Limit time.Duration // Суточная квота
+	Reset time.Time     // Начало следующих суток, когда квота восстанавливается
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("daily quota exceeded: %v used of %v, expression needs %v", e.Used, e.Limit, e.Cost)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// QuotaUsage описывает расход суточной квоты клиента.
+type QuotaUsage struct {
+	Day         string        `json:"day"`         // Сутки по UTC в формате 2006-01-02
+	Expressions int           `json:"expressions"` // Число добавленных выражений
+	Operations  int           `json:"operations"`  // Число операций в добавленных выражениях
+	Used        time.Duration `json:"used"`        // Оценка суммарного времени вычисления добавленных выражений
+	Limit       time.Duration `json:"limit"`       // Суточная квота; 0 - без ограничения
+	Remaining   time.Duration `json:"remaining"`   // Остаток квоты
+	Reset       time.Time     `json:"reset"`       // Начало следующих суток, когда квота восстанавливается
+}
+
+// ConfigureQuota задает суточную квоту клиентов; 0 отключает квоту.
+func (o *Orchestrator) ConfigureQuota(daily time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.dailyQuota = max(daily, 0)
+}
+
+// GetUsage возвращает расход суточной квоты клиента за текущие сутки.
+func (o *Orchestrator) GetUsage(client string) (*QuotaUsage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	day, reset := quotaDay(time.Now())
+	usage, err := o.db.GetUsage(client, day)
+	if err != nil {
+		return nil, err
+	}
+	u := &QuotaUsage{
+		Day:         day,
+		Expressions: usage.Expressions,
+		Operations:  usage.Operations,
+		Used:        usage.Cost,
+		Limit:       o.dailyQuota,
+		Reset:       reset,
+	}
+	if o.dailyQuota > 0 {
+		u.Remaining = max(o.dailyQuota-usage.Cost, 0)
+	}
+	return u, nil
+}
+
+// quotaDay возвращает сутки по UTC, в которые попадает now, и начало следующих суток.
+func quotaDay(now time.Time) (string, time.Time) {
+	now = now.UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return start.Format(time.DateOnly), start.AddDate(0, 0, 1)
+}
+
+// estimate оценивает время вычисления графа по времени выполнения операций
+// арендатора. Вызывается под o.mu.
+func (o *Orchestrator) estimate(tenant string, graph *expr.Graph) (time.Duration, error) {
+	cost, err := o.costFunc(tenant)
+	if err != nil {
+		return 0, err
+	}
+	return graph.Estimate(cost), nil
+}
+
+// costFunc возвращает время выполнения операций арендатора. Вызывается под o.mu.
+func (o *Orchestrator) costFunc(tenant string) (func(op *operation.Operation) time.Duration, error) {
+	timings, err := o.operationTimings(tenant)
+	if err != nil {
+		return nil, err
+	}
+	return func(op *operation.Operation) time.Duration {
+		duration, err := time.ParseDuration(timings[op.Name])
+		if err != nil {
+			return op.DefaultCost
+		}
+		return duration
+	}, nil
+}
+
+// checkQuota проверяет, что выражение с оценкой cost не превышает суточную
+// квоту клиента. Клиенты без идентификатора не ограничены. Вызывается под o.mu.
+func (o *Orchestrator) checkQuota(client string, cost time.Duration) error {
+	if client == "" || o.dailyQuota <= 0 {
+		return nil
+	}
+	day, reset := quotaDay(time.Now())
+	usage, err := o.db.GetUsage(client, day)
+	if err != nil {
+		return err
+	}
+	if usage.Cost+cost > o.dailyQuota {
+		return &QuotaError{Used: usage.Cost, Cost: cost, Limit: o.dailyQuota, Reset: reset}
+	}
+	return nil
+}
+
+// chargeQuota учитывает добавленное выражение в расходе квоты клиента.
+// Вызывается под o.mu.
+func (o *Orchestrator) chargeQuota(client string, graph *expr.Graph, cost time.Duration) error {
+	return o.addUsage(client, graph, cost, 1)
+}
+
+// refundQuota возвращает расход квоты, списанный chargeQuota за выражение,
+// которое не удалось сохранить. Вызывается под o.mu.
+func (o *Orchestrator) refundQuota(client string, graph *expr.Graph, cost time.Duration) error {
+	return o.addUsage(client, graph, cost, -1)
+}
+
+// addUsage добавляет к расходу квоты клиента sign выражений графа graph.
+// Вызывается под o.mu.
+func (o *Orchestrator) addUsage(client string, graph *expr.Graph, cost time.Duration, sign int) error {
+	if client == "" {
+		return nil
+	}
+	operations := 0
+	for _, node := range graph.Nodes {
+		if node.Op != nil {
+			operations++
+		}
+	}
+	day, _ := quotaDay(time.Now())
+	return o.db.AddUsage(&task.Usage{
+		Client:      client,
+		Day:         day,
+		Expressions: sign,
+		Operations:  sign * operations,
+		Cost:        time.Duration(sign) * cost,
+	})
+}
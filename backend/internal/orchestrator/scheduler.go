@@ -0,0 +1,207 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"calcflow/backend/internal/task"
+)
+
+// DefaultCapacity - число задач, одновременно отправленных агентам, по умолчанию.
+const DefaultCapacity = 100
+
+// DefaultMaxBacklog - наибольшее число задач, ожидающих отправки агентам, по умолчанию.
+const DefaultMaxBacklog = 10000
+
+// ErrBacklogFull возвращается, если очередь задач, ожидающих отправки агентам, заполнена.
+var ErrBacklogFull = errors.New("task backlog is full")
+
+// BacklogError описывает заполненную очередь задач. Соответствует ErrBacklogFull в errors.Is.
+type BacklogError struct {
+	Depth int // Задач, ожидающих отправки агентам
+	Limit int // Наибольшее число ожидающих задач
+}
+
+func (e *BacklogError) Error() string {
+	return fmt.Sprintf("task backlog is full: %d tasks waiting, limit %d", e.Depth, e.Limit)
+}
+
+func (e *BacklogError) Unwrap() error {
+	return ErrBacklogFull
+}
+
+// QueueStats описывает очереди задач оркестратора.
+type QueueStats struct {
+	Queued     int `json:"queued"`      // Задач, ожидающих отправки агентам
+	Running    int `json:"running"`     // Задач, отправленных агентам
+	Capacity   int `json:"capacity"`    // Наибольшее число задач, одновременно отправленных агентам
+	MaxBacklog int `json:"max_backlog"` // Наибольшее число ожидающих задач; 0 - без ограничения
+}
+
+// ConfigureCapacity задает число задач, одновременно отправленных агентам;
+// обычно это размер очереди агента. Доля мощности арендатора отсчитывается от этого числа.
+func (o *Orchestrator) ConfigureCapacity(capacity int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.capacity = max(capacity, 1)
+	o.dispatch()
+}
+
+// ConfigureBacklog задает наибольшее число задач, ожидающих отправки агентам;
+// сверх него новые выражения отклоняются с ErrBacklogFull. 0 снимает ограничение.
+func (o *Orchestrator) ConfigureBacklog(limit int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.maxBacklog = max(limit, 0)
+}
+
+// GetQueueStats возвращает состояние очередей задач.
+func (o *Orchestrator) GetQueueStats() QueueStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return QueueStats{
+		Queued:     o.backlog(),
+		Running:    o.dispatched,
+		Capacity:   o.capacity,
+		MaxBacklog: o.maxBacklog,
+	}
+}
+
+// RecoverPending ставит в очереди задачи, оставшиеся невычисленными после
+// перезапуска, в порядке их создания, и возвращает их число. Задачи с одинаковым
+// выражением снова присоединяются к первой из них. Вызывается после запуска агентов.
+func (o *Orchestrator) RecoverPending() (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	tasks, err := o.db.GetPendingTasks()
+	if err != nil {
+		return 0, err
+	}
+	for _, t := range tasks {
+		if t.CacheKey != "" {
+			if followers, ok := o.inflight[t.CacheKey]; ok {
+				o.inflight[t.CacheKey] = append(followers, t)
+				continue
+			}
+			o.inflight[t.CacheKey] = nil
+		}
+		o.enqueue(t)
+	}
+	return len(tasks), nil
+}
+
+// checkBacklog проверяет, что очередь задач, ожидающих отправки агентам,
+// не заполнена. Вызывается под o.mu.
+func (o *Orchestrator) checkBacklog() error {
+	if depth := o.backlog(); o.maxBacklog > 0 && depth >= o.maxBacklog {
+		return &BacklogError{Depth: depth, Limit: o.maxBacklog}
+	}
+	return nil
+}
+
+// backlog возвращает число задач, ожидающих отправки агентам. Вызывается под o.mu.
+func (o *Orchestrator) backlog() int {
+	depth := 0
+	for _, queue := range o.queues {
+		depth += len(queue)
+	}
+	return depth
+}
+
+// enqueue ставит задачу в очередь ее арендатора. Вызывается под o.mu.
+func (o *Orchestrator) enqueue(t *task.Task) {
+	if len(o.queues[t.Tenant]) == 0 {
+		o.rotation = append(o.rotation, t.Tenant)
+	}
+	o.startQueued(t)
+	o.queues[t.Tenant] = append(o.queues[t.Tenant], t)
+	o.updateQueueMetrics(t.Tenant)
+	o.dispatch()
+}
+
+// dispatch отправляет агентам задачи из очередей арендаторов по кругу, пока есть
+// свободная мощность. Арендатору доступна доля мощности из его настроек, но не
+// меньше одной задачи. Если агент не принимает задачу, она остается первой
+// в очереди до освобождения мощности. Вызывается под o.mu.
+func (o *Orchestrator) dispatch() {
+	// skipped - число арендаторов подряд, чья доля мощности исчерпана
+	for skipped := 0; o.dispatched < o.capacity && skipped < len(o.rotation); {
+		tenant := o.rotation[0]
+		o.rotation = o.rotation[1:]
+		if o.running[tenant] >= o.tenantCapacity(tenant) {
+			o.rotation = append(o.rotation, tenant)
+			skipped++
+			continue
+		}
+		skipped = 0
+
+		t := o.queues[tenant][0]
+		if err := o.processor.EnqueueTask(t); err != nil {
+			taskLogger(t).Warn("agent did not accept task, it stays queued", "error", err)
+			o.rotation = append([]string{tenant}, o.rotation...)
+			return
+		}
+		o.queues[tenant] = o.queues[tenant][1:]
+		if len(o.queues[tenant]) > 0 {
+			o.rotation = append(o.rotation, tenant)
+		} else {
+			delete(o.queues, tenant)
+		}
+		o.running[tenant]++
+		o.dispatched++
+		o.updateQueueMetrics(tenant)
+		o.endQueued(t, "dispatched")
+		observeDispatched(t)
+	}
+}
+
+// release освобождает мощность, занятую задачей арендатора, и отправляет
+// агентам следующие задачи. Вызывается под o.mu.
+func (o *Orchestrator) release(tenant string) {
+	if o.running[tenant] == 0 {
+		return
+	}
+	o.running[tenant]--
+	if o.running[tenant] == 0 {
+		delete(o.running, tenant)
+	}
+	o.dispatched--
+	o.updateQueueMetrics(tenant)
+	o.dispatch()
+}
+
+// unqueue удаляет задачу, еще не отправленную агенту, из очереди арендатора
+// и сообщает, была ли она там. Вызывается под o.mu.
+func (o *Orchestrator) unqueue(t *task.Task) bool {
+	queue := o.queues[t.Tenant]
+	for i, queued := range queue {
+		if queued.ID != t.ID {
+			continue
+		}
+		o.queues[t.Tenant] = append(queue[:i], queue[i+1:]...)
+		o.updateQueueMetrics(t.Tenant)
+		o.endQueued(t, "canceled")
+		if len(o.queues[t.Tenant]) == 0 {
+			delete(o.queues, t.Tenant)
+			for j, tenant := range o.rotation {
+				if tenant == t.Tenant {
+					o.rotation = append(o.rotation[:j], o.rotation[j+1:]...)
+					break
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// tenantCapacity возвращает число задач арендатора, которые могут одновременно
+// выполняться агентами. Вызывается под o.mu.
+func (o *Orchestrator) tenantCapacity(tenant string) int {
+	return max(1, int(math.Floor(o.tenant(tenant).Share*float64(o.capacity))))
+}
@@ -0,0 +1,170 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/operation"
+	"calcflow/backend/internal/task"
+)
+
+var (
+	// ErrInvalidTenant возвращается при сохранении некорректных настроек арендатора.
+	ErrInvalidTenant = errors.New("invalid tenant settings")
+
+	// ErrQuotaExceeded возвращается, если запрос превышает ограничения арендатора.
+	ErrQuotaExceeded = errors.New("tenant quota exceeded")
+)
+
+// Access ограничивает выражения, доступные запросу. Пустые поля не ограничивают доступ.
+type Access struct {
+	Tenant   string // Арендатор, выражения которого доступны
+	Owner    string // Пользователь, выражения которого доступны
+	APIKeyID string // Ключ API, с которым добавлены доступные выражения
+}
+
+// TenantSettings задает изменяемые настройки арендатора.
+type TenantSettings struct {
+	Timings      task.CalculationRequest `json:"timings"`
+	Share        float64                 `json:"share"`
+	MaxPending   int                     `json:"max_pending"`
+	MaxFunctions int                     `json:"max_functions"`
+}
+
+// GetTenant возвращает настройки арендатора; для арендатора без сохраненных
+// настроек возвращаются настройки по умолчанию.
+func (o *Orchestrator) GetTenant(id string) *task.Tenant {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	t := *o.tenant(id)
+	return &t
+}
+
+// ListTenants возвращает арендаторов с сохраненными настройками.
+func (o *Orchestrator) ListTenants() ([]*task.Tenant, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.db.GetTenants()
+}
+
+// UpdateTenant сохраняет настройки арендатора. Время выполнения операций
+// заменяет общее для перечисленных операций; названия не зависят от регистра.
+// Новая доля мощности агентов применяется к задачам, еще не отправленным агентам.
+func (o *Orchestrator) UpdateTenant(id string, settings TenantSettings) (*task.Tenant, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if id == "" {
+		return nil, fmt.Errorf("%w: tenant ID is required", ErrInvalidTenant)
+	}
+	if settings.Share <= 0 || settings.Share > 1 {
+		return nil, fmt.Errorf("%w: share must be greater than 0 and at most 1", ErrInvalidTenant)
+	}
+	if settings.MaxPending < 0 || settings.MaxFunctions < 0 {
+		return nil, fmt.Errorf("%w: quotas must not be negative", ErrInvalidTenant)
+	}
+	timings, err := normalizeTimings(settings.Timings)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &task.Tenant{
+		ID:           id,
+		Timings:      timings,
+		Share:        settings.Share,
+		MaxPending:   settings.MaxPending,
+		MaxFunctions: settings.MaxFunctions,
+		Updated:      time.Now(),
+	}
+	if err := o.db.SaveTenant(t); err != nil {
+		return nil, err
+	}
+	o.tenants[id] = t
+	o.dispatch()
+
+	updated := *t
+	return &updated, nil
+}
+
+// loadTenants загружает сохраненные настройки арендаторов.
+func (o *Orchestrator) loadTenants() error {
+	tenants, err := o.db.GetTenants()
+	if err != nil {
+		return err
+	}
+	for _, t := range tenants {
+		o.tenants[t.ID] = t
+	}
+	return nil
+}
+
+// tenant возвращает настройки арендатора. Вызывается под o.mu.
+func (o *Orchestrator) tenant(id string) *task.Tenant {
+	if t, ok := o.tenants[id]; ok {
+		return t
+	}
+	return task.NewTenant(id)
+}
+
+// checkPendingQuota проверяет, что у арендатора можно добавить еще одно
+// невычисленное выражение. Вызывается под o.mu.
+func (o *Orchestrator) checkPendingQuota(tenant string) error {
+	limit := o.tenant(tenant).MaxPending
+	if limit == 0 {
+		return nil
+	}
+	pending, err := o.db.CountPendingTasks(tenant)
+	if err != nil {
+		return err
+	}
+	if pending >= int64(limit) {
+		return fmt.Errorf("%w: %d expressions are pending, limit is %d", ErrQuotaExceeded, pending, limit)
+	}
+	return nil
+}
+
+// normalizeTimings проверяет время выполнения операций и приводит их названия
+// к нижнему регистру. Название, заданное несколько раз в разном регистре, отклоняется.
+func normalizeTimings(timings task.CalculationRequest) (task.CalculationRequest, error) {
+	normalized := make(task.CalculationRequest, len(timings))
+	for name, duration := range timings {
+		name = strings.ToLower(name)
+		if _, ok := operation.Default.ByName(name); !ok {
+			return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidOperation, name)
+		}
+		if _, ok := normalized[name]; ok {
+			return nil, fmt.Errorf("%w: operation %q is given more than once", ErrInvalidOperation, name)
+		}
+		d, err := time.ParseDuration(duration)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("%w: invalid duration %q for %q", ErrInvalidOperation, duration, name)
+		}
+		normalized[name] = duration
+	}
+	return normalized, nil
+}
+
+// accessibleTask возвращает задачу, если она доступна запросу. Чужие задачи
+// не отличаются от несуществующих, чтобы не раскрывать их идентификаторы.
+// Вызывается под o.mu.
+func (o *Orchestrator) accessibleTask(access Access, taskID string) (*task.Task, error) {
+	t, err := o.db.GetTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if !access.allows(t) {
+		return nil, database.ErrNotFound
+	}
+	return t, nil
+}
+
+// allows сообщает, доступна ли задача.
+func (a Access) allows(t *task.Task) bool {
+	return (a.Tenant == "" || t.Tenant == a.Tenant) && (a.Owner == "" || t.Owner == a.Owner) &&
+		(a.APIKeyID == "" || t.APIKeyID == a.APIKeyID)
+}
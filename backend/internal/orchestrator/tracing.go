@@ -0,0 +1,51 @@
+package orchestrator
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+
+	"calcflow/backend/internal/task"
+	"calcflow/backend/internal/tracing"
+)
+
+// tracer создает участки трассировки оркестратора.
+var tracer = otel.Tracer("calcflow/backend/internal/orchestrator")
+
+// traceDB выполняет запрос к базе данных query в участке трассировки name,
+// дочернем к участку из ctx.
+func traceDB(ctx context.Context, name string, query func() error) error {
+	_, span := tracer.Start(ctx, name)
+	err := query()
+	tracing.SetError(span, err)
+	span.End()
+	return err
+}
+
+// startQueued начинает участок ожидания задачи в очереди до отправки агенту.
+// Вызывается под o.mu.
+func (o *Orchestrator) startQueued(t *task.Task) {
+	_, span := tracer.Start(tracing.ContextFrom(t.TraceParent), "Orchestrator.enqueue")
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		attribute.String("task.id", t.ID),
+		attribute.String("tenant", t.Tenant),
+		attribute.Int("queue.depth", len(o.queues[t.Tenant])),
+	)
+	o.queued[t.ID] = span
+}
+
+// endQueued завершает участок ожидания задачи в очереди; outcome - чем оно
+// закончилось: dispatched или canceled. Вызывается под o.mu.
+func (o *Orchestrator) endQueued(t *task.Task, outcome string) {
+	span, ok := o.queued[t.ID]
+	if !ok {
+		return
+	}
+	delete(o.queued, t.ID)
+	span.SetAttributes(attribute.String("outcome", outcome))
+	span.End()
+}
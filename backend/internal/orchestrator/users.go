@@ -0,0 +1,158 @@
+package orchestrator
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"calcflow/backend/internal/auth"
+	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/task"
+)
+
+// MinPasswordLength - наименьшая длина пароля пользователя в символах.
+const MinPasswordLength = 8
+
+var (
+	// ErrInvalidUser возвращается при регистрации с некорректным именем или паролем.
+	ErrInvalidUser = errors.New("invalid user")
+
+	// ErrUserExists возвращается при регистрации с именем, уже занятым в арендаторе.
+	ErrUserExists = errors.New("username already taken")
+
+	// ErrInvalidCredentials возвращается при входе с неизвестным именем или неверным паролем.
+	ErrInvalidCredentials = errors.New("invalid username or password")
+
+	// ErrInvalidToken возвращается для недействительного или просроченного токена доступа.
+	ErrInvalidToken = errors.New("invalid access token")
+)
+
+// usernamePattern - допустимые имена пользователей.
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,64}$`)
+
+// Token представляет выданный пользователю токен доступа.
+type Token struct {
+	Token   string     `json:"token"`
+	Type    string     `json:"token_type"`
+	Expires time.Time  `json:"expires"`
+	User    *task.User `json:"user"`
+}
+
+// ConfigureTokens задает ключ подписи и время действия токенов доступа.
+// Токены, выданные с прежним ключом, перестают действовать.
+func (o *Orchestrator) ConfigureTokens(key []byte, ttl time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.tokens = auth.NewSigner(key, ttl)
+}
+
+// newSigner создает Signer со случайным ключом подписи.
+func newSigner() *auth.Signer {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("orchestrator: can't generate token key: %v", err))
+	}
+	return auth.NewSigner(key, auth.DefaultTokenTTL)
+}
+
+// Register создает учетную запись пользователя арендатора.
+func (o *Orchestrator) Register(tenant, username, password string) (*task.User, error) {
+	if !usernamePattern.MatchString(username) {
+		return nil, fmt.Errorf("%w: username must be 3-64 letters, digits, '_', '.' or '-'", ErrInvalidUser)
+	}
+	if utf8.RuneCountInString(password) < MinPasswordLength {
+		return nil, fmt.Errorf("%w: password must be at least %d characters", ErrInvalidUser, MinPasswordLength)
+	}
+
+	// Хеширование пароля занимает заметное время, поэтому выполняется без блокировки
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	user := &task.User{
+		ID:           task.NewID(),
+		Username:     username,
+		PasswordHash: hash,
+		Tenant:       tenant,
+		Created:      time.Now(),
+	}
+	err = o.db.NewUser(user)
+	if errors.Is(err, database.ErrDuplicate) {
+		return nil, ErrUserExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// dummyPasswordHash проверяется при входе неизвестного пользователя, чтобы время
+// ответа не выдавало, существует ли имя.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := auth.HashPassword("calcflow")
+	return hash
+})
+
+// Login проверяет пароль пользователя арендатора и выдает ему токен доступа.
+// Имена пользователей уникальны только в пределах арендатора.
+func (o *Orchestrator) Login(tenant, username, password string) (*Token, error) {
+	o.mu.Lock()
+	user, err := o.db.GetUserByName(tenant, username)
+	signer := o.tokens
+	o.mu.Unlock()
+
+	var hash string
+	if err == nil {
+		hash = user.PasswordHash
+	} else if errors.Is(err, database.ErrNotFound) {
+		hash = dummyPasswordHash()
+	} else {
+		return nil, err
+	}
+
+	ok, err := auth.CheckPassword(hash, password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || user == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	token, expires, err := signer.Issue(user.ID, user.Username, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &Token{Token: token, Type: "Bearer", Expires: expires, User: user}, nil
+}
+
+// AuthenticateToken возвращает пользователя, которому выдан действующий токен доступа.
+func (o *Orchestrator) AuthenticateToken(token string) (*task.User, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	claims, err := o.tokens.Parse(token, time.Now())
+	if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrTokenExpired) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := o.db.GetUser(claims.Subject)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,97 @@
+package orchestrator
+
+import (
+	"context"
+
+	"calcflow/backend/internal/task"
+)
+
+// watcher представляет подписку на изменение одной задачи, общую для всех подписчиков.
+type watcher struct {
+	changed chan struct{} // Закрывается при изменении задачи
+	task    *task.Task    // Состояние задачи после изменения; доступно после закрытия changed
+	refs    int           // Количество подписчиков
+}
+
+// Watch подписывается на изменение задачи с идентификатором taskID. Канал
+// закрывается при следующем изменении задачи: получении результата или отмене.
+// Подписка одноразовая; после изменения задачу нужно получить заново и при
+// необходимости подписаться снова. Функция stop снимает подписку.
+func (o *Orchestrator) Watch(taskID string) (changed <-chan struct{}, stop func()) {
+	w, stop := o.watch(taskID)
+	return w.changed, stop
+}
+
+// WaitExpression ожидает завершения вычисления задачи или отмены контекста
+// и возвращает последнее известное состояние задачи. Ожидание не опрашивает
+// базу данных: все ожидающие задачу получают ее состояние из общего оповещения.
+// Ожидать можно только выражение, доступное access.
+func (o *Orchestrator) WaitExpression(ctx context.Context, access Access, taskID string) (*task.Task, error) {
+	w, stop := o.watch(taskID)
+	defer func() { stop() }()
+
+	t, err := o.GetExpression(access, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	for !t.Done() {
+		select {
+		case <-ctx.Done():
+			return t, nil
+		case <-w.changed:
+		}
+
+		stop()
+		t = w.task
+		w, stop = o.watch(taskID)
+	}
+	return t, nil
+}
+
+// watch возвращает подписку на изменение задачи, создавая ее для первого подписчика.
+func (o *Orchestrator) watch(taskID string) (*watcher, func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	w, ok := o.watchers[taskID]
+	if !ok {
+		w = &watcher{changed: make(chan struct{})}
+		o.watchers[taskID] = w
+	}
+	w.refs++
+
+	stopped := false
+	return w, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		if stopped {
+			return
+		}
+		stopped = true
+		// После оповещения подписка уже удалена
+		if o.watchers[taskID] != w {
+			return
+		}
+		w.refs--
+		if w.refs == 0 {
+			delete(o.watchers, taskID)
+		}
+	}
+}
+
+// notify оповещает подписчиков об изменении задачи. Вызывается под o.mu.
+func (o *Orchestrator) notify(t *task.Task) {
+	w, ok := o.watchers[t.ID]
+	if !ok {
+		return
+	}
+	snapshot := *t
+	snapshot.Trace = nil
+	snapshot.Graph = nil
+	w.task = &snapshot
+
+	close(w.changed)
+	delete(o.watchers, t.ID)
+}
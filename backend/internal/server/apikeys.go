@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/orchestrator"
+	"calcflow/backend/internal/task"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateAPIKeyRequest представляет тело запроса на создание ключа API.
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Tenant string   `json:"tenant,omitempty"` // Арендатор, к которому привязывается ключ
+}
+
+// APIKeySecret представляет ключ API вместе с его значением, которое
+// возвращается только при создании и замене ключа.
+type APIKeySecret struct {
+	Key    *task.APIKey `json:"key"`
+	Secret string       `json:"secret"`
+}
+
+// Создание ключа API: POST /api/v2/keys.
+func (s *Server) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var request CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	key, secret, err := s.orchestrator.CreateAPIKey(request.Name, request.Tenant, request.Scopes)
+	if errors.Is(err, orchestrator.ErrInvalidScope) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", APIPrefix+"/keys/"+key.ID)
+	writeJSON(w, http.StatusCreated, APIKeySecret{Key: key, Secret: secret})
+}
+
+// Получение списка ключей API: GET /api/v2/keys.
+func (s *Server) ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.orchestrator.ListAPIKeys()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if keys == nil {
+		keys = []*task.APIKey{}
+	}
+
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// Замена ключа API новым: POST /api/v2/keys/{id}/rotate.
+func (s *Server) RotateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	key, secret, err := s.orchestrator.RotateAPIKey(mux.Vars(r)["id"])
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if errors.Is(err, orchestrator.ErrAPIKeyRevoked) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, APIKeySecret{Key: key, Secret: secret})
+}
+
+// Отзыв ключа API: DELETE /api/v2/keys/{id}.
+func (s *Server) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	key, err := s.orchestrator.RevokeAPIKey(mux.Vars(r)["id"])
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, key)
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"calcflow/backend/internal/openapi"
+	"calcflow/backend/internal/orchestrator"
+	"calcflow/backend/internal/task"
+
+	"github.com/gorilla/mux"
+)
+
+// contextKey - тип ключей значений, которые сервер сохраняет в контексте запроса.
+type contextKey int
+
+const principalContextKey contextKey = iota
+
+// userScopes - области доступа пользователей, вошедших по паролю.
+var userScopes = task.Scopes{task.ScopeSubmit, task.ScopeRead}
+
+// principal представляет того, кто выполняет запрос: ключ API или пользователя.
+type principal struct {
+	key    *task.APIKey
+	user   *task.User
+	scopes task.Scopes
+}
+
+// tenant возвращает арендатора, к которому привязаны учетные данные;
+// пустая строка означает, что арендатор выбирается заголовком X-Tenant-ID.
+func (p *principal) tenant() string {
+	switch {
+	case p.key != nil:
+		return p.key.Tenant
+	case p.user != nil:
+		return p.user.Tenant
+	}
+	return ""
+}
+
+// authenticate возвращает промежуточный обработчик, который проверяет учетные
+// данные запроса и наличие у них области доступа, указанной для маршрута
+// в документе OpenAPI. Ключ API передается в заголовке X-API-Key или
+// Authorization: Bearer, токен пользователя - в заголовке Authorization: Bearer.
+func (s *Server) authenticate(doc *openapi.Document) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op := operationFor(doc, r)
+			if op == nil || op.Scope == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			secret := credentials(r)
+			if secret == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="calcflow"`)
+				writeRouteError(w, r, http.StatusUnauthorized, "API key or access token is required")
+				return
+			}
+			p, err := s.principal(secret)
+			if errors.Is(err, orchestrator.ErrUnauthorized) || errors.Is(err, orchestrator.ErrInvalidToken) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="calcflow", error="invalid_token"`)
+				writeRouteError(w, r, http.StatusUnauthorized, err.Error())
+				return
+			}
+			if err != nil {
+				writeRouteError(w, r, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if !p.scopes.Has(op.Scope) {
+				writeRouteError(w, r, http.StatusForbidden, "credentials lack scope "+op.Scope)
+				return
+			}
+			if tenant, header := p.tenant(), r.Header.Get("X-Tenant-ID"); tenant != "" && header != "" && header != tenant {
+				writeRouteError(w, r, http.StatusForbidden, fmt.Sprintf("credentials are bound to tenant %q", tenant))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalContextKey, p)))
+		})
+	}
+}
+
+// principal проверяет учетные данные: ключи API отличаются префиксом,
+// остальные значения считаются токенами пользователей.
+func (s *Server) principal(secret string) (*principal, error) {
+	if strings.HasPrefix(secret, orchestrator.APIKeyPrefix) {
+		key, err := s.orchestrator.Authenticate(secret)
+		if err != nil {
+			return nil, err
+		}
+		return &principal{key: key, scopes: key.Scopes}, nil
+	}
+
+	user, err := s.orchestrator.AuthenticateToken(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &principal{user: user, scopes: userScopes}, nil
+}
+
+// credentials извлекает ключ API или токен доступа из заголовков запроса.
+func credentials(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// principalFrom возвращает учетные данные, с которыми выполнен запрос.
+func principalFrom(r *http.Request) *principal {
+	p, ok := r.Context().Value(principalContextKey).(*principal)
+	if !ok {
+		return &principal{}
+	}
+	return p
+}
+
+// apiKeyID возвращает идентификатор ключа API, с которым выполнен запрос.
+func apiKeyID(r *http.Request) string {
+	if p := principalFrom(r); p.key != nil {
+		return p.key.ID
+	}
+	return ""
+}
+
+// access возвращает ограничение выражений, доступных запросу. Пользователю
+// доступны только его выражения, ключу API - только добавленные с ним. Ключ
+// с областью admin, как администратор арендатора, видит все выражения арендатора.
+func access(r *http.Request) orchestrator.Access {
+	a := orchestrator.Access{Tenant: tenantFromRequest(r), Owner: owner(r)}
+	if p := principalFrom(r); p.key != nil && !p.scopes.Has(task.ScopeAdmin) {
+		a.APIKeyID = p.key.ID
+	}
+	return a
+}
+
+// instanceAdmin пропускает к next только запросы с учетными данными, не привязанными
+// к арендатору: управление ключами и арендаторами затрагивает всех арендаторов.
+func instanceAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if tenant := principalFrom(r).tenant(); tenant != "" {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("credentials are bound to tenant %q", tenant))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// owner возвращает идентификатор пользователя, выполняющего запрос, или пустую
+// строку для запросов с ключом API.
+func owner(r *http.Request) string {
+	if p := principalFrom(r); p.user != nil {
+		return p.user.ID
+	}
+	return ""
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"calcflow/backend/internal/orchestrator"
+	"calcflow/backend/internal/task"
+)
+
+// createKey создает ключ API с областями scopes и возвращает его значение.
+func createKey(t *testing.T, ts *testServer, body string) string {
+	t.Helper()
+	resp := ts.do(t, "POST", APIPrefix+"/keys", body, nil)
+	if resp.status != http.StatusCreated {
+		t.Fatalf("create key %s: status %d: %s", body, resp.status, resp.body)
+	}
+	var created APIKeySecret
+	resp.decode(t, &created)
+	return created.Secret
+}
+
+// as возвращает заголовки запроса с ключом API или токеном доступа secret.
+func as(secret string) http.Header {
+	return http.Header{"X-Api-Key": {""}, "Authorization": {"Bearer " + secret}}
+}
+
+func TestAuthenticate(t *testing.T) {
+	ts := newTestServer(t)
+	read := createKey(t, ts, `{"name": "reader", "scopes": ["read"]}`)
+	bound := createKey(t, ts, `{"name": "bound", "scopes": ["admin"], "tenant": "acme"}`)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		header http.Header
+		status int
+	}{
+		{"no credentials", "GET", APIPrefix + "/expressions", "", http.Header{"X-Api-Key": {""}}, http.StatusUnauthorized},
+		{"unknown key", "GET", APIPrefix + "/expressions", "", as(orchestrator.APIKeyPrefix + "unknown"), http.StatusUnauthorized},
+		{"bad token", "GET", APIPrefix + "/expressions", "", as("not.a.token"), http.StatusUnauthorized},
+		{"key in X-API-Key", "GET", APIPrefix + "/expressions", "", http.Header{"X-Api-Key": {read}}, http.StatusOK},
+		{"key in Authorization", "GET", APIPrefix + "/expressions", "", as(read), http.StatusOK},
+		{"missing scope", "POST", APIPrefix + "/expressions", `{"expression": "1"}`, as(read), http.StatusForbidden},
+		{"missing admin scope", "PUT", APIPrefix + "/operations", `{"summation": 10}`, as(read), http.StatusForbidden},
+		{"public route", "GET", APIPrefix + "/openapi.json", "", http.Header{"X-Api-Key": {""}}, http.StatusOK},
+		{"bound key in its tenant", "GET", APIPrefix + "/expressions", "", http.Header{"X-Api-Key": {bound}, "X-Tenant-Id": {"acme"}}, http.StatusOK},
+		{"bound key in another tenant", "GET", APIPrefix + "/expressions", "", http.Header{"X-Api-Key": {bound}, "X-Tenant-Id": {"other"}}, http.StatusForbidden},
+		{"bound key manages keys", "GET", APIPrefix + "/keys", "", http.Header{"X-Api-Key": {bound}}, http.StatusForbidden},
+		{"bound key manages tenants", "GET", APIPrefix + "/tenants", "", http.Header{"X-Api-Key": {bound}}, http.StatusForbidden},
+		{"unbound admin manages keys", "GET", APIPrefix + "/keys", "", nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp := ts.do(t, tt.method, tt.path, tt.body, tt.header)
+		if resp.status != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, resp.status, tt.status, resp.body)
+		}
+		if resp.status == http.StatusUnauthorized && resp.header.Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: 401 without WWW-Authenticate", tt.name)
+		}
+	}
+}
+
+func TestAPIKeyAccess(t *testing.T) {
+	ts := newTestServer(t)
+	alice := createKey(t, ts, `{"name": "alice", "scopes": ["submit", "read"]}`)
+	bob := createKey(t, ts, `{"name": "bob", "scopes": ["submit", "read"]}`)
+
+	var created task.Task
+	resp := ts.do(t, "POST", APIPrefix+"/expressions", `{"expression": "1 + 1"}`, as(alice))
+	if resp.status != http.StatusCreated {
+		t.Fatalf("status %d: %s", resp.status, resp.body)
+	}
+	resp.decode(t, &created)
+
+	// Ключ без области admin не видит чужие выражения
+	var list ExpressionList
+	ts.do(t, "GET", APIPrefix+"/expressions", "", as(bob)).decode(t, &list)
+	if len(list.Items) != 0 {
+		t.Errorf("bob lists %d expressions, want 0", len(list.Items))
+	}
+	if resp := ts.do(t, "GET", APIPrefix+"/expressions/"+created.ID, "", as(bob)); resp.status != http.StatusNotFound {
+		t.Errorf("bob gets alice's expression: status %d, want 404", resp.status)
+	}
+	if resp := ts.do(t, "DELETE", APIPrefix+"/expressions/"+created.ID, "", as(bob)); resp.status != http.StatusNotFound {
+		t.Errorf("bob cancels alice's expression: status %d, want 404", resp.status)
+	}
+
+	ts.do(t, "GET", APIPrefix+"/expressions", "", as(alice)).decode(t, &list)
+	if len(list.Items) != 1 || list.Items[0].ID != created.ID {
+		t.Errorf("alice lists %d expressions, want her own", len(list.Items))
+	}
+	if resp := ts.do(t, "GET", APIPrefix+"/expressions/"+created.ID, "", as(alice)); resp.status != http.StatusOK {
+		t.Errorf("alice gets her expression: status %d, want 200", resp.status)
+	}
+
+	// Ключ администратора видит все выражения арендатора
+	if got := len(listExpressions(t, ts)); got != 1 {
+		t.Errorf("admin lists %d expressions, want 1", got)
+	}
+
+	// Ключ идемпотентности другого ключа API не возвращает чужой ответ
+	header := func(secret string) http.Header {
+		h := as(secret)
+		h.Set("Idempotency-Key", "shared")
+		return h
+	}
+	ts.do(t, "POST", APIPrefix+"/expressions", `{"expression": "2 + 2"}`, header(alice))
+	if resp := ts.do(t, "POST", APIPrefix+"/expressions", `{"expression": "2 + 2"}`, header(bob)); resp.status != http.StatusConflict {
+		t.Errorf("bob reuses alice's idempotency key: status %d, want 409: %s", resp.status, resp.body)
+	}
+}
+
+func TestUserAccess(t *testing.T) {
+	ts := newTestServer(t)
+
+	login := func(username string) string {
+		t.Helper()
+		body := `{"username": "` + username + `", "password": "secret-password"}`
+		if resp := ts.do(t, "POST", APIPrefix+"/users", body, http.Header{"X-Api-Key": {""}}); resp.status != http.StatusCreated {
+			t.Fatalf("register %s: status %d: %s", username, resp.status, resp.body)
+		}
+		resp := ts.do(t, "POST", APIPrefix+"/login", body, http.Header{"X-Api-Key": {""}})
+		if resp.status != http.StatusOK {
+			t.Fatalf("login %s: status %d: %s", username, resp.status, resp.body)
+		}
+		var token orchestrator.Token
+		resp.decode(t, &token)
+		return token.Token
+	}
+	alice, bob := login("alice"), login("bob")
+
+	wrong := ts.do(t, "POST", APIPrefix+"/login", `{"username": "alice", "password": "wrong-password"}`, http.Header{"X-Api-Key": {""}})
+	if wrong.status != http.StatusUnauthorized {
+		t.Errorf("login with a wrong password: status %d, want 401", wrong.status)
+	}
+
+	var created task.Task
+	ts.do(t, "POST", APIPrefix+"/expressions", `{"expression": "3 * 3"}`, as(alice)).decode(t, &created)
+
+	var list ExpressionList
+	ts.do(t, "GET", APIPrefix+"/expressions", "", as(bob)).decode(t, &list)
+	if len(list.Items) != 0 {
+		t.Errorf("bob lists %d expressions, want 0", len(list.Items))
+	}
+	if resp := ts.do(t, "GET", APIPrefix+"/expressions/"+created.ID, "", as(bob)); resp.status != http.StatusNotFound {
+		t.Errorf("bob gets alice's expression: status %d, want 404", resp.status)
+	}
+	if resp := ts.do(t, "GET", APIPrefix+"/expressions/"+created.ID, "", as(alice)); resp.status != http.StatusOK {
+		t.Errorf("alice gets her expression: status %d, want 200", resp.status)
+	}
+
+	// Пользователю не доступны операции администратора
+	if resp := ts.do(t, "PUT", APIPrefix+"/operations", `{"summation": 10}`, as(alice)); resp.status != http.StatusForbidden {
+		t.Errorf("user changes operations: status %d, want 403", resp.status)
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"calcflow/backend/internal/orchestrator"
+)
+
+// Вычисление выражения при запросе: POST /evaluate.
+// Дешевое выражение вычисляется сразу и возвращается с кодом 200, остальные
+// добавляются в очередь агента, и ответ с кодом 202 содержит созданную задачу.
+// Поле mode ответа сообщает принятое решение.
+func (s *Server) EvaluateHandler(w http.ResponseWriter, r *http.Request) {
+	var request CreateExpressionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeRouteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if request.Expression == "" {
+		writeRouteError(w, r, http.StatusBadRequest, "expression is required")
+		return
+	}
+
+	taskID := generateTaskID()
+	if request.RequestID == "" {
+		request.RequestID = r.Header.Get("X-Request-ID")
+	}
+	if request.RequestID == "" {
+		request.RequestID = taskID
+	}
+
+	evaluation, err := s.orchestrator.Evaluate(r.Context(), request.Expression, taskID, request.RequestID, orchestrator.CalculationOptions{
+		Tenant:   tenantFromRequest(r),
+		Explain:  request.Explain,
+		NoCache:  request.NoCache,
+		APIKeyID: apiKeyID(r),
+		Owner:    owner(r),
+		Client:   client(r),
+	})
+	if errors.Is(err, orchestrator.ErrInvalidExpression) {
+		writeRouteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, orchestrator.ErrDuplicateRequest) {
+		writeRouteError(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, orchestrator.ErrQuotaExceeded) {
+		writeQuotaError(w, r, err)
+		return
+	}
+	if errors.Is(err, orchestrator.ErrBacklogFull) {
+		writeBacklogError(w, r, err)
+		return
+	}
+	if err != nil {
+		writeRouteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	slog.InfoContext(r.Context(), "expression added", "task_id", evaluation.Task.ID,
+		"status", evaluation.Task.Status, "mode", evaluation.Mode)
+
+	w.Header().Set("Location", APIPrefix+"/expressions/"+evaluation.Task.ID)
+	if evaluation.Mode == orchestrator.EvaluateQueued {
+		writeJSON(w, http.StatusAccepted, evaluation)
+		return
+	}
+	writeJSON(w, http.StatusOK, evaluation)
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"calcflow/backend/internal/orchestrator"
+)
+
+func TestEvaluateHandler(t *testing.T) {
+	ts := newTestServer(t)
+	ts.orchestrator.ConfigureEvaluate(50 * time.Millisecond)
+
+	tests := []struct {
+		path, body string
+		status     int
+		mode       string
+		result     string
+	}{
+		{"/evaluate", `{"expression": "2 + 3"}`, http.StatusOK, orchestrator.EvaluateInline, "5"},
+		{APIPrefix + "/evaluate", `{"expression": "2 ^ 10"}`, http.StatusOK, orchestrator.EvaluateInline, "1024"},
+		{APIPrefix + "/evaluate", `{"expression": "2 ^ 10 ^ 2"}`, http.StatusAccepted, orchestrator.EvaluateQueued, ""},
+		{APIPrefix + "/evaluate", `{"expression": "2 + 3", "explain": true}`, http.StatusAccepted, orchestrator.EvaluateQueued, ""},
+	}
+	for _, tt := range tests {
+		resp := ts.do(t, "POST", tt.path, tt.body, nil)
+		if resp.status != tt.status {
+			t.Errorf("%s %s: status %d, want %d: %s", tt.path, tt.body, resp.status, tt.status, resp.body)
+			continue
+		}
+		var evaluation orchestrator.Evaluation
+		resp.decode(t, &evaluation)
+		if evaluation.Mode != tt.mode || evaluation.Task.Result != tt.result {
+			t.Errorf("%s %s: mode %s result %q, want %s %q", tt.path, tt.body, evaluation.Mode, evaluation.Task.Result, tt.mode, tt.result)
+		}
+		if location := resp.header.Get("Location"); location != APIPrefix+"/expressions/"+evaluation.Task.ID {
+			t.Errorf("%s %s: Location %q", tt.path, tt.body, location)
+		}
+	}
+
+	invalid := []struct {
+		body   string
+		status int
+	}{
+		{`{"expression": "2 +"}`, http.StatusBadRequest},
+		{`{}`, http.StatusBadRequest},
+		{`{"request_id": "taken", "expression": "1"}`, http.StatusOK},
+		{`{"request_id": "taken", "expression": "2"}`, http.StatusConflict},
+	}
+	for _, tt := range invalid {
+		if resp := ts.do(t, "POST", APIPrefix+"/evaluate", tt.body, nil); resp.status != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.body, resp.status, tt.status, resp.body)
+		}
+	}
+}
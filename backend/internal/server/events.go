@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"calcflow/backend/internal/database"
+
+	"github.com/gorilla/mux"
+)
+
+// eventsHeartbeat - интервал комментариев, поддерживающих соединение потока событий.
+const eventsHeartbeat = 15 * time.Second
+
+// Поток изменений выражения: GET /api/v2/expressions/{id}/events.
+// Отправляет события Server-Sent Events "task" с текущим состоянием задачи
+// и каждым его изменением; поток закрывается после завершения вычисления.
+func (s *Server) ExpressionEventsV2Handler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	taskID := mux.Vars(r)["id"]
+
+	// Подписка оформляется до чтения задачи, чтобы не пропустить изменение между ними
+	changed, stop := s.orchestrator.Watch(taskID)
+	t, err := s.orchestrator.GetExpression(access(r), taskID)
+	if errors.Is(err, database.ErrNotFound) {
+		stop()
+		writeError(w, http.StatusNotFound, "expression not found")
+		return
+	}
+	if err != nil {
+		stop()
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		data, err := json.Marshal(t)
+		if err != nil {
+			stop()
+			return
+		}
+		fmt.Fprintf(w, "event: task\ndata: %s\n\n", data)
+		flusher.Flush()
+		if t.Done() {
+			stop()
+			return
+		}
+
+	wait:
+		for {
+			select {
+			case <-r.Context().Done():
+				stop()
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+				flusher.Flush()
+			case <-changed:
+				break wait
+			}
+		}
+
+		changed, stop = s.orchestrator.Watch(taskID)
+		t, err = s.orchestrator.GetExpression(access(r), taskID)
+		if err != nil {
+			stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"calcflow/backend/internal/task"
+)
+
+// readEvent читает следующее событие потока и разбирает его данные в задачу;
+// комментарии пропускаются. Возвращает false, если поток закрыт.
+func readEvent(t *testing.T, r *bufio.Reader) (*task.Task, bool) {
+	t.Helper()
+	var event, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, false
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && data != "":
+			if event != "task" {
+				t.Fatalf("event %q, want task", event)
+			}
+			var got task.Task
+			if err := json.Unmarshal([]byte(data), &got); err != nil {
+				t.Fatal(err)
+			}
+			return &got, true
+		}
+	}
+}
+
+func TestExpressionEvents(t *testing.T) {
+	ts := newTestServer(t)
+	var created task.Task
+	ts.do(t, "POST", APIPrefix+"/expressions", `{"expression": "6 * 7"}`, nil).decode(t, &created)
+
+	req, err := http.NewRequest("GET", ts.url+APIPrefix+"/expressions/"+created.ID+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-API-Key", ts.adminKey)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, Content-Type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	events := bufio.NewReader(resp.Body)
+
+	// Первое событие передает текущее состояние, последнее - результат,
+	// после чего поток закрывается
+	first, ok := readEvent(t, events)
+	if !ok || first.ID != created.ID || first.Status != "pending" {
+		t.Fatalf("first event %+v, want the pending task", first)
+	}
+	ts.complete(t, created.ID, "42")
+	var last *task.Task
+	for {
+		event, ok := readEvent(t, events)
+		if !ok {
+			break
+		}
+		last = event
+	}
+	if last == nil || last.Status != "completed" || last.Result != "42" {
+		t.Errorf("last event %+v, want the completed task", last)
+	}
+
+	// Поток завершенной задачи состоит из одного события
+	resp2 := ts.do(t, "GET", APIPrefix+"/expressions/"+created.ID+"/events", "", nil)
+	if n := strings.Count(resp2.body, "event: task"); n != 1 {
+		t.Errorf("%d events for a finished task, want 1: %s", n, resp2.body)
+	}
+
+	if resp := ts.do(t, "GET", APIPrefix+"/expressions/unknown/events", "", nil); resp.status != http.StatusNotFound {
+		t.Errorf("unknown expression: status %d, want 404", resp.status)
+	}
+}
+
+func TestGetExpressionWait(t *testing.T) {
+	ts := newTestServer(t)
+	var created task.Task
+	ts.do(t, "POST", APIPrefix+"/expressions", `{"expression": "6 * 7"}`, nil).decode(t, &created)
+	path := APIPrefix + "/expressions/" + created.ID
+
+	// По истечении ожидания возвращается незавершенная задача
+	start := time.Now()
+	var got task.Task
+	resp := ts.do(t, "GET", path+"?wait=100ms", "", nil)
+	resp.decode(t, &got)
+	if resp.status != http.StatusOK || got.Status != "pending" {
+		t.Errorf("status %d, task %s; want 200 and pending", resp.status, got.Status)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("returned after %v, want to wait 100ms", elapsed)
+	}
+
+	// Ответ приходит, как только вычисление завершается
+	done := created
+	done.Status, done.Result = "completed", "42"
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		if err := ts.orchestrator.ReceiveResult(&done); err != nil {
+			t.Error(err)
+		}
+	}()
+	start = time.Now()
+	ts.do(t, "GET", path+"?wait=10s", "", nil).decode(t, &got)
+	if got.Status != "completed" || got.Result != "42" {
+		t.Errorf("task %+v, want completed 42", got)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("returned after %v, want right after completion", elapsed)
+	}
+
+	for _, wait := range []string{"soon", "-1s"} {
+		if resp := ts.do(t, "GET", path+"?wait="+wait, "", nil); resp.status != http.StatusBadRequest {
+			t.Errorf("wait=%s: status %d, want 400", wait, resp.status)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"calcflow/backend/internal/orchestrator"
+)
+
+// Регистрация новой версии пользовательской функции.
+func (s *Server) AddFunctionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Извлечение определения функции из JSON-тела запроса
+	var requestBody map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	definition := requestBody["definition"]
+	if definition == "" {
+		http.Error(w, "Function definition is required", http.StatusBadRequest)
+		return
+	}
+
+	function, err := s.orchestrator.DefineFunction(tenantFromRequest(r), definition)
+	if errors.Is(err, orchestrator.ErrInvalidFunction) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, orchestrator.ErrQuotaExceeded) {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Отправляем сохраненную версию функции
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(function)
+}
+
+// Получение последних версий всех пользовательских функций.
+func (s *Server) GetFunctionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	functions, err := s.orchestrator.GetFunctions(tenantFromRequest(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(functions)
+}
+
+// Получение всех версий пользовательской функции по ее имени.
+func (s *Server) GetFunctionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Получаем имя функции из query параметра
+	name := r.URL.Query().Get("name")
+
+	versions, err := s.orchestrator.GetFunctionVersions(tenantFromRequest(r), name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(versions) == 0 {
+		http.Error(w, "Function not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
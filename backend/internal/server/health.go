@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"calcflow/backend/internal/orchestrator"
+)
+
+// healthTimeout ограничивает время проверок работоспособности, чтобы зависшая
+// база данных не задерживала ответ дольше ожидания балансировщика.
+const healthTimeout = 2 * time.Second
+
+// probeRoute сообщает, что маршрут - проверка работоспособности или готовности.
+// Такие маршруты доступны без учетных данных и не ограничены по частоте.
+func probeRoute(path string) bool {
+	return path == "/healthz" || path == "/readyz"
+}
+
+// Проверка работоспособности: GET /healthz. Отвечает 503, если нет соединения
+// с базой данных или не работает ни один агент.
+func (s *Server) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	s.writeHealth(w, r, false)
+}
+
+// Проверка готовности принимать выражения: GET /readyz. Дополнительно к /healthz
+// отвечает 503, если очередь ожидающих задач почти заполнена.
+func (s *Server) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	s.writeHealth(w, r, true)
+}
+
+func (s *Server) writeHealth(w http.ResponseWriter, r *http.Request, ready bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthTimeout)
+	defer cancel()
+
+	health := s.orchestrator.CheckHealth(ctx, ready)
+	w.Header().Set("Cache-Control", "no-store")
+	if health.Status != orchestrator.HealthOK {
+		writeJSON(w, http.StatusServiceUnavailable, health)
+		return
+	}
+	writeJSON(w, http.StatusOK, health)
+}
+
+// Состояние агентов и очередей для диагностики: GET /debug/state
+func (s *Server) DebugStateHandler(w http.ResponseWriter, r *http.Request) {
+	state, err := s.orchestrator.GetDebugState()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
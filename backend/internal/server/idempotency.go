@@ -0,0 +1,169 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"calcflow/backend/internal/orchestrator"
+	"calcflow/backend/internal/task"
+)
+
+// idempotent делает создающий обработчик идемпотентным. Ключ запроса берется
+// из заголовка Idempotency-Key или X-Request-ID, а без них - из поля bodyField тела.
+// Повтор запроса с тем же ключом и телом возвращает сохраненный ответ с заголовком
+// Idempotent-Replayed, а с другим телом отклоняется с кодом 409. Сохраняются только
+// успешные ответы (2xx); после любого другого ответа ключ освобождается.
+func (s *Server) idempotent(bodyField string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+		if err != nil {
+			writeRouteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key := idempotencyKey(r, body, bodyField)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		tenant := tenantFromRequest(r)
+
+		record, err := s.orchestrator.BeginIdempotent(tenant, key, fingerprint(r, body))
+		if errors.Is(err, orchestrator.ErrIdempotencyMismatch) || errors.Is(err, orchestrator.ErrIdempotencyInProgress) {
+			writeRouteError(w, r, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			writeRouteError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if record != nil {
+			replay(w, record)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			// Ключ освобождается и при панике обработчика, иначе повторы получали
+			// бы 409 до истечения резерва
+			if !completed {
+				s.abortIdempotent(r, tenant, key)
+			}
+		}()
+		next(recorder, r)
+
+		// Сохраняется только успешный ответ. Ответ с ошибкой запроса, сервера
+		// или превышением ограничений не сохраняется, чтобы запрос можно было
+		// исправить и повторить с тем же ключом
+		if recorder.status < 200 || recorder.status >= 300 {
+			return
+		}
+		completed = true
+		err = s.orchestrator.CompleteIdempotent(&task.IdempotencyRecord{
+			Tenant:      tenant,
+			Key:         key,
+			StatusCode:  recorder.status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Location:    recorder.Header().Get("Location"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err != nil {
+			// Ответ уже отправлен; без сохраненного ответа ключ нужно освободить,
+			// чтобы повтор не ждал окончания резерва
+			slog.ErrorContext(r.Context(), "failed to save idempotent response", "key", key, "error", err)
+			s.abortIdempotent(r, tenant, key)
+		}
+	}
+}
+
+// abortIdempotent освобождает ключ идемпотентности запроса. Если это не удалось,
+// ключ освободится по истечении orchestrator.IdempotencyLease.
+func (s *Server) abortIdempotent(r *http.Request, tenant, key string) {
+	if err := s.orchestrator.AbortIdempotent(tenant, key); err != nil {
+		slog.ErrorContext(r.Context(), "failed to release idempotency key", "key", key, "error", err)
+	}
+}
+
+// idempotencyKey возвращает ключ идемпотентности запроса или пустую строку.
+func idempotencyKey(r *http.Request, body []byte, bodyField string) string {
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		return key
+	}
+	if key := r.Header.Get("X-Request-ID"); key != "" {
+		return key
+	}
+	var fields map[string]interface{}
+	if json.Unmarshal(body, &fields) != nil {
+		return ""
+	}
+	key, _ := fields[bodyField].(string)
+	return key
+}
+
+// fingerprint вычисляет отпечаток запроса по методу, пути, учетным данным и телу.
+// Тело в формате JSON нормализуется, чтобы порядок полей и пробелы не влияли
+// на отпечаток. Из-за учетных данных в отпечатке повтор чужого ключа отклоняется
+// и не раскрывает сохраненный ответ.
+func fingerprint(r *http.Request, body []byte) string {
+	var value interface{}
+	if json.Unmarshal(body, &value) == nil {
+		if normalized, err := json.Marshal(value); err == nil {
+			body = normalized
+		}
+	}
+
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	io.WriteString(h, client(r)+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replay отправляет сохраненный ответ на запрос.
+func replay(w http.ResponseWriter, record *task.IdempotencyRecord) {
+	if record.ContentType != "" {
+		w.Header().Set("Content-Type", record.ContentType)
+	}
+	if record.Location != "" {
+		w.Header().Set("Location", record.Location)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(record.StatusCode)
+	w.Write(record.Body)
+}
+
+// writeRouteError отправляет ошибку в формате маршрута: JSON для API v2
+// и текстом для прежних маршрутов.
+func writeRouteError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if strings.HasPrefix(r.URL.Path, APIPrefix) {
+		writeError(w, status, message)
+		return
+	}
+	http.Error(w, message, status)
+}
+
+// responseRecorder передает ответ клиенту и сохраняет его статус и тело.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"calcflow/backend/internal/task"
+)
+
+// listExpressions возвращает все выражения арендатора по умолчанию.
+func listExpressions(t *testing.T, ts *testServer) []*task.Task {
+	t.Helper()
+	var list ExpressionList
+	ts.do(t, "GET", APIPrefix+"/expressions?limit=100", "", nil).decode(t, &list)
+	return list.Items
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	ts := newTestServer(t)
+	header := http.Header{"Idempotency-Key": {"key-1"}}
+
+	first := ts.do(t, "POST", APIPrefix+"/expressions", `{"expression": "1 + 2"}`, header)
+	if first.status != http.StatusCreated {
+		t.Fatalf("status %d: %s", first.status, first.body)
+	}
+
+	// Порядок полей и пробелы не меняют отпечаток запроса
+	again := ts.do(t, "POST", APIPrefix+"/expressions", `{"expression":"1 + 2"}`, header)
+	if again.status != first.status || again.body != first.body || again.header.Get("Location") != first.header.Get("Location") {
+		t.Errorf("replay = %d %s, want %d %s", again.status, again.body, first.status, first.body)
+	}
+	if again.header.Get("Idempotent-Replayed") != "true" {
+		t.Error("replay has no Idempotent-Replayed header")
+	}
+	if first.header.Get("Idempotent-Replayed") != "" {
+		t.Error("first response has the Idempotent-Replayed header")
+	}
+
+	other := ts.do(t, "POST", APIPrefix+"/expressions", `{"expression": "1 + 3"}`, header)
+	if other.status != http.StatusConflict {
+		t.Errorf("different body: status %d, want 409: %s", other.status, other.body)
+	}
+
+	// Ключ из тела запроса действует так же, как заголовок
+	body := `{"request_id": "body-key", "expression": "2 * 2"}`
+	first = ts.do(t, "POST", APIPrefix+"/expressions", body, nil)
+	again = ts.do(t, "POST", APIPrefix+"/expressions", body, nil)
+	if again.status != http.StatusCreated || again.body != first.body {
+		t.Errorf("replay by request_id = %d %s, want %s", again.status, again.body, first.body)
+	}
+
+	if got := len(listExpressions(t, ts)); got != 2 {
+		t.Errorf("%d expressions, want 2", got)
+	}
+}
+
+func TestIdempotentKeyIsReleasedAfterError(t *testing.T) {
+	ts := newTestServer(t)
+	header := http.Header{"Idempotency-Key": {"key-1"}}
+
+	// Ответы с ошибкой не сохраняются: исправленный запрос выполняется с тем же ключом
+	for _, body := range []string{`{"expression": "2 +"}`, `{"expression": 2}`, `{}`} {
+		if resp := ts.do(t, "POST", APIPrefix+"/expressions", body, header); resp.status != http.StatusBadRequest {
+			t.Fatalf("%s: status %d, want 400: %s", body, resp.status, resp.body)
+		}
+	}
+	resp := ts.do(t, "POST", APIPrefix+"/expressions", `{"expression": "2 + 1"}`, header)
+	if resp.status != http.StatusCreated || resp.header.Get("Idempotent-Replayed") != "" {
+		t.Fatalf("fixed request: status %d replayed %q: %s", resp.status, resp.header.Get("Idempotent-Replayed"), resp.body)
+	}
+
+	// Конфликт уникального идентификатора запроса тоже не сохраняется
+	header = http.Header{"Idempotency-Key": {"key-2"}}
+	ts.do(t, "POST", APIPrefix+"/expressions", `{"request_id": "taken", "expression": "1"}`, nil)
+	resp = ts.do(t, "POST", APIPrefix+"/expressions", `{"request_id": "taken", "expression": "2"}`, header)
+	if resp.status != http.StatusConflict {
+		t.Fatalf("duplicate request ID: status %d, want 409: %s", resp.status, resp.body)
+	}
+	resp = ts.do(t, "POST", APIPrefix+"/expressions", `{"request_id": "free", "expression": "2"}`, header)
+	if resp.status != http.StatusCreated {
+		t.Errorf("request with a new request ID: status %d, want 201: %s", resp.status, resp.body)
+	}
+}
+
+func TestIdempotentConcurrent(t *testing.T) {
+	ts := newTestServer(t)
+	header := http.Header{"Idempotency-Key": {"key-1"}}
+
+	const requests = 20
+	statuses := make([]int, requests)
+	var wg sync.WaitGroup
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statuses[i] = ts.do(t, "POST", APIPrefix+"/expressions", `{"expression": "5 - 1"}`, header).status
+		}(i)
+	}
+	wg.Wait()
+
+	// Одновременные повторы получают либо сохраненный ответ, либо 409,
+	// пока первый запрос выполняется; задача создается одна
+	created := 0
+	for _, status := range statuses {
+		switch status {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+		default:
+			t.Errorf("status %d, want 201 or 409", status)
+		}
+	}
+	if created == 0 {
+		t.Error("no request succeeded")
+	}
+	if got := len(listExpressions(t, ts)); got != 1 {
+		t.Errorf("%d expressions, want 1", got)
+	}
+}
+
+func TestIdempotentKeyIsReleasedAfterPanic(t *testing.T) {
+	ts := newTestServer(t)
+
+	calls := 0
+	handler := ts.server.idempotent("id", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			panic(http.ErrAbortHandler)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	request := func() *http.Request {
+		r := httptest.NewRequest("POST", "/add-calculation", nil)
+		r.Header.Set("Idempotency-Key", "key-1")
+		return r
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("handler did not panic")
+			}
+		}()
+		handler(httptest.NewRecorder(), request())
+	}()
+
+	w := httptest.NewRecorder()
+	handler(w, request())
+	if w.Code != http.StatusOK || calls != 2 {
+		t.Errorf("retry after panic: status %d, handler calls %d; want 200 and 2", w.Code, calls)
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"calcflow/backend/internal/logging"
+	"calcflow/backend/internal/task"
+)
+
+// maxRequestIDLength - наибольшая длина идентификатора запроса клиента,
+// который попадает в журнал; более длинный заменяется новым.
+const maxRequestIDLength = 128
+
+// maxLoggedError - сколько первых байт тела ответа 5xx записывается в журнал как текст ошибки.
+const maxLoggedError = 512
+
+// logRequests присваивает запросу идентификатор и записывает его в журнал доступа
+// после ответа. Идентификатор берется из заголовка X-Request-ID или создается,
+// возвращается клиенту в том же заголовке и добавляется ко всем записям журнала,
+// сделанным с контекстом запроса. Ответы 5xx записываются вместе с текстом ошибки
+// с уровнем ERROR, а отказы из-за перегрузки (503) - с уровнем WARN.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > maxRequestIDLength {
+			id = task.NewID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		ctx := logging.WithRequestID(r.Context(), id)
+
+		started := time.Now()
+		lw := &logWriter{statusWriter: statusWriter{ResponseWriter: w, status: http.StatusOK}}
+		next.ServeHTTP(lw, r.WithContext(ctx))
+
+		// Успешные проверки работоспособности повторяются часто и записываются с уровнем DEBUG
+		level := slog.LevelInfo
+		if probeRoute(r.URL.Path) && lw.status == http.StatusOK {
+			level = slog.LevelDebug
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", routeTemplate(r)),
+			slog.Int("status", lw.status),
+			slog.Int("bytes", lw.size),
+			slog.Duration("duration", time.Since(started)),
+			slog.String("remote", r.RemoteAddr),
+		}
+		if lw.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+			if lw.status == http.StatusServiceUnavailable {
+				level = slog.LevelWarn
+			}
+			attrs = append(attrs, slog.String("error", strings.TrimSpace(string(lw.body))))
+		}
+		slog.LogAttrs(ctx, level, "http request", attrs...)
+	})
+}
+
+// logWriter дополнительно считает размер ответа и запоминает начало тела ответов 5xx.
+type logWriter struct {
+	statusWriter
+	size int
+	body []byte
+}
+
+func (w *logWriter) Write(b []byte) (int, error) {
+	if w.status >= http.StatusInternalServerError && len(w.body) < maxLoggedError {
+		w.body = append(w.body, b[:min(len(b), maxLoggedError-len(w.body))]...)
+	}
+	n, err := w.statusWriter.Write(b)
+	w.size += n
+	return n, err
+}
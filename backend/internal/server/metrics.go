@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"calcflow/backend/internal/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	httpRequests = metrics.Default.Counter("calcflow_http_requests_total",
+		"HTTP-запросы по маршруту, методу и коду ответа", "route", "method", "code")
+	httpDuration = metrics.Default.Histogram("calcflow_http_request_duration_seconds",
+		"Время обработки HTTP-запросов по маршруту и методу", metrics.DefaultBuckets, "route", "method")
+)
+
+// instrument учитывает запросы в метриках HTTP. Маршрут учитывается по шаблону
+// пути, например /api/v2/expressions/{id}, чтобы число рядов не зависело от идентификаторов.
+func instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		started := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		httpRequests.Inc(route, r.Method, strconv.Itoa(sw.status))
+		httpDuration.Observe(time.Since(started).Seconds(), route, r.Method)
+	})
+}
+
+// routeTemplate возвращает шаблон пути маршрута запроса или, если маршрут
+// не найден, путь запроса.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
+
+// statusWriter запоминает код ответа и передает ответ клиенту без изменений.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush передает клиенту буферизованные данные, например события потока изменений.
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap возвращает исходный ResponseWriter для http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Получение метрик в текстовом формате Prometheus
+func (s *Server) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.Default.WriteText(w)
+}
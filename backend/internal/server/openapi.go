@@ -0,0 +1,747 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/openapi"
+	"calcflow/backend/internal/operation"
+	"calcflow/backend/internal/orchestrator"
+	"calcflow/backend/internal/task"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBodySize ограничивает размер тела запроса, проверяемого по схеме.
+const maxBodySize = 1 << 20
+
+// buildOpenAPI строит документ OpenAPI по маршрутам router. Каждый маршрут должен
+// быть описан в describeRoutes, а каждое описание - соответствовать маршруту.
+func (s *Server) buildOpenAPI(router *mux.Router) (*openapi.Document, error) {
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "calcflow",
+			Description: "Распределенное вычисление арифметических выражений",
+			Version:     "2.0.0",
+		},
+		Paths: make(map[string]*openapi.PathItem),
+	}
+	doc.Components.SecuritySchemes = map[string]*openapi.SecurityScheme{
+		"apiKey": {Type: "apiKey", In: "header", Name: "X-API-Key", Description: "Ключ API"},
+		"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "Ключ API или токен доступа пользователя в заголовке Authorization"},
+	}
+	described := describeRoutes(doc)
+
+	var problems []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Маршрут без методов - префикс подмаршрутизатора
+			return nil
+		}
+		for _, method := range methods {
+			key := method + " " + path
+			op, ok := described[key]
+			if !ok {
+				problems = append(problems, "route "+key+" is not described")
+				continue
+			}
+			delete(described, key)
+			if op.Scope = routeScope(method, path); op.Scope != "" {
+				op.Security = []openapi.SecurityRequirement{{"apiKey": {}}, {"bearer": {}}}
+				op.Responses["401"] = &openapi.Response{Description: "Ключ API не передан или недействителен"}
+				op.Responses["403"] = &openapi.Response{Description: "У ключа API нет области доступа " + op.Scope}
+			}
+			if !probeRoute(path) {
+				limitedResponse(op)
+			}
+			// Маршруты с учетными данными, кроме управления ключами и арендаторами, выполняются от имени арендатора
+			if op.Scope != "" && !instanceRoute(path) && !hasParameter(op, tenantParameter.Name) {
+				op.Parameters = append(op.Parameters, tenantParameter)
+			}
+			item, ok := doc.Paths[path]
+			if !ok {
+				item = &openapi.PathItem{}
+				doc.Paths[path] = item
+			}
+			(*item)[strings.ToLower(method)] = op
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for key := range described {
+		problems = append(problems, "description "+key+" has no route")
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("openapi document does not match routes: %s", strings.Join(problems, "; "))
+	}
+
+	return doc, nil
+}
+
+// limitedResponse описывает ответ 429 с заголовком Retry-After: частота запросов
+// ограничена на всех маршрутах.
+func limitedResponse(op *openapi.Operation) {
+	response, ok := op.Responses["429"]
+	if !ok {
+		response = &openapi.Response{Description: "Превышен предел частоты запросов"}
+		op.Responses["429"] = response
+	}
+	response.Headers = map[string]*openapi.Parameter{"Retry-After": retryAfter}
+}
+
+// retryAfter описывает заголовок ответа 429.
+var retryAfter = &openapi.Parameter{
+	Name: "Retry-After", In: "header",
+	Description: "Через сколько секунд повторить запрос",
+	Schema:      &openapi.Schema{Type: "integer"},
+}
+
+// instanceRoute сообщает, что маршрут управляет ключами или арендаторами
+// либо отдает метрики или диагностику и не относится к отдельному арендатору.
+func instanceRoute(path string) bool {
+	return strings.HasPrefix(path, APIPrefix+"/keys") || strings.HasPrefix(path, APIPrefix+"/tenants") ||
+		path == "/metrics" || path == "/debug/state"
+}
+
+// hasParameter сообщает, описан ли у операции параметр name.
+func hasParameter(op *openapi.Operation, name string) bool {
+	for _, p := range op.Parameters {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// routeScope возвращает область доступа, необходимую для вызова маршрута:
+// изменение времени выполнения операций, управление ключами и арендаторами, метрики
+// и диагностика требуют admin, остальные изменяющие запросы - submit, чтение - read.
+// Описание API, проверки работоспособности, регистрация и вход пользователей
+// доступны без учетных данных.
+func routeScope(method, path string) string {
+	switch {
+	case strings.HasSuffix(path, "/openapi.json"), probeRoute(path),
+		path == APIPrefix+"/users", path == APIPrefix+"/login":
+		return ""
+	case path == "/update-operations",
+		method == http.MethodPut && path == APIPrefix+"/operations",
+		instanceRoute(path):
+		return task.ScopeAdmin
+	case method == http.MethodGet:
+		return task.ScopeRead
+	}
+	return task.ScopeSubmit
+}
+
+// tenantParameter описывает заголовок арендатора запроса.
+var tenantParameter = &openapi.Parameter{
+	Name: "X-Tenant-ID", In: "header",
+	Description: "Арендатор; по умолчанию default. Учетные данные, привязанные к арендатору, могут указывать только его",
+	Schema:      &openapi.Schema{Type: "string"},
+}
+
+// describeRoutes возвращает описания операций API по ключу "МЕТОД путь".
+func describeRoutes(doc *openapi.Document) map[string]*openapi.Operation {
+	taskSchema := doc.SchemaOf(task.Task{})
+	tasksSchema := &openapi.Schema{Type: "array", Items: taskSchema}
+	functionsSchema := doc.SchemaOf([]task.Function{})
+	errorSchema := doc.SchemaOf(ErrorResponse{})
+	statsSchema := doc.SchemaOf(orchestrator.CacheStats{})
+	healthSchema := doc.SchemaOf(orchestrator.Health{})
+	evaluationSchema := doc.SchemaOf(orchestrator.Evaluation{})
+	doc.Resolve(evaluationSchema).Properties["mode"].Enum = []string{orchestrator.EvaluateInline, orchestrator.EvaluateQueued}
+	traceSchema := doc.SchemaOf(TraceResponse{})
+	listSchema := doc.SchemaOf(ExpressionList{})
+	keySchema := doc.SchemaOf(task.APIKey{})
+	keySecretSchema := doc.SchemaOf(APIKeySecret{})
+	createKeySchema := doc.SchemaOf(CreateAPIKeyRequest{})
+	createKey := doc.Resolve(createKeySchema)
+	createKey.Closed = true
+	createKey.Required = []string{"name", "scopes"}
+	createKey.Properties["scopes"].Items.Enum = []string{task.ScopeSubmit, task.ScopeRead, task.ScopeAdmin}
+	credentialsSchema := doc.SchemaOf(Credentials{})
+	credentials := doc.Resolve(credentialsSchema)
+	credentials.Closed = true
+	credentials.Required = []string{"username", "password"}
+	keyID := &openapi.Parameter{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}
+
+	// Время выполнения операций: в прежнем API допускаются любые названия,
+	// в API v2 - только зарегистрированные операции. Регистр названий не важен
+	timingsSchema := doc.SchemaOf(task.CalculationRequest{})
+	doc.Resolve(timingsSchema).AdditionalProperties.Format = "duration"
+	doc.Components.Schemas["OperationTimings"] = operationTimingsSchema()
+	operationTimings := openapi.Ref("OperationTimings")
+
+	tenantSchema := doc.SchemaOf(task.Tenant{})
+	tenantSettingsSchema := doc.SchemaOf(orchestrator.TenantSettings{})
+	tenantSettings := doc.Resolve(tenantSettingsSchema)
+	tenantSettings.Closed = true
+	tenantSettings.Required = []string{"share"}
+	tenantSettings.Properties["timings"] = operationTimings
+	usageSchema := doc.SchemaOf(UsageResponse{})
+	queueSchema := doc.SchemaOf(orchestrator.QueueStats{})
+	backlogFull := &openapi.Response{
+		Description: "Очередь задач, ожидающих отправки агентам, заполнена",
+		Headers: map[string]*openapi.Parameter{
+			"X-Queue-Depth": {Name: "X-Queue-Depth", In: "header", Description: "Задач в очереди", Schema: &openapi.Schema{Type: "integer"}},
+			"X-Queue-Limit": {Name: "X-Queue-Limit", In: "header", Description: "Наибольшее число задач в очереди", Schema: &openapi.Schema{Type: "integer"}},
+			"Retry-After":   retryAfter,
+		},
+	}
+	backlogFullV2 := *backlogFull
+	backlogFullV2.Content = openapi.JSON(errorSchema)
+	tenantID := &openapi.Parameter{Name: "id", In: "path", Required: true, Description: "Идентификатор арендатора", Schema: &openapi.Schema{Type: "string"}}
+
+	minLength := 1
+	createSchema := doc.SchemaOf(CreateExpressionRequest{})
+	create := doc.Resolve(createSchema)
+	create.Closed = true
+	create.Required = []string{"expression"}
+	create.Properties["expression"].MinLength = &minLength
+
+	legacyCreate := &openapi.Schema{
+		Type:   "object",
+		Closed: true,
+		Properties: map[string]*openapi.Schema{
+			"id":         {Type: "string", MinLength: &minLength, Description: "Уникальный идентификатор запроса"},
+			"expression": {Type: "string", MinLength: &minLength},
+			"explain":    {Type: "boolean"},
+			"no_cache":   {Type: "boolean"},
+		},
+		Required: []string{"id", "expression"},
+	}
+	functionSchema := &openapi.Schema{
+		Type:       "object",
+		Closed:     true,
+		Properties: map[string]*openapi.Schema{"definition": {Type: "string", MinLength: &minLength}},
+		Required:   []string{"definition"},
+	}
+
+	tenant := tenantParameter
+	idempotencyKey := &openapi.Parameter{
+		Name: "Idempotency-Key", In: "header",
+		Description: "Ключ идемпотентности: повтор с тем же телом возвращает исходный ответ, с другим - 409",
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+	requestIDHeader := &openapi.Parameter{
+		Name: "X-Request-ID", In: "header",
+		Description: "Ключ идемпотентности, если не задан Idempotency-Key",
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+	replayed := &openapi.Parameter{
+		Name: "Idempotent-Replayed", In: "header",
+		Description: "Ответ повторен по ключу идемпотентности",
+		Schema:      &openapi.Schema{Type: "string", Enum: []string{"true"}},
+	}
+	taskID := &openapi.Parameter{Name: "id", In: "path", Required: true, Description: "Идентификатор задачи", Schema: &openapi.Schema{Type: "string"}}
+	traceFormat := &openapi.Parameter{Name: "format", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []string{"json", "text"}}}
+	listParams := listParameters()
+
+	traceResponses := map[string]*openapi.Response{
+		"200": {Description: "Трассировка вычисления", Content: map[string]openapi.MediaType{
+			"application/json": {Schema: traceSchema},
+			"text/plain":       {Schema: &openapi.Schema{Type: "string"}},
+		}},
+		"404": {Description: "Задача не найдена или трассировка не записывалась"},
+	}
+	pagedHeader := map[string]*openapi.Parameter{
+		"X-Next-Cursor": {Name: "X-Next-Cursor", In: "header", Description: "Курсор следующей страницы", Schema: &openapi.Schema{Type: "string"}},
+	}
+	location := map[string]*openapi.Parameter{
+		"Location": {Name: "Location", In: "header", Description: "Адрес созданного ресурса", Schema: &openapi.Schema{Type: "string"}},
+	}
+	openapiResponses := map[string]*openapi.Response{
+		"200": {Description: "Документ OpenAPI", Content: openapi.JSON(&openapi.Schema{Type: "object"})},
+	}
+
+	evaluate := &openapi.Operation{
+		Summary:     "Вычисление выражения при запросе",
+		Description: "Выражение с оценкой времени вычисления не выше порога вычисляется сразу, остальные добавляются в очередь агента",
+		Tags:        []string{"expressions"},
+		Parameters:  []*openapi.Parameter{tenant, idempotencyKey, requestIDHeader},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(createSchema)},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "Выражение вычислено (mode=inline)", Headers: location, Content: openapi.JSON(evaluationSchema)},
+			"202": {Description: "Выражение добавлено в очередь (mode=queued)", Headers: location, Content: openapi.JSON(evaluationSchema)},
+			"400": {Description: "Некорректное выражение", Content: openapi.JSON(errorSchema)},
+			"409": {Description: "Идентификатор запроса уже использован", Content: openapi.JSON(errorSchema)},
+			"429": {Description: "Превышено число невычисленных выражений арендатора, суточная квота клиента или предел частоты запросов", Content: openapi.JSON(errorSchema)},
+			"503": &backlogFullV2,
+		},
+	}
+
+	return map[string]*openapi.Operation{
+		// Прежние маршруты
+		"POST /add-calculation": {
+			Summary: "Добавление выражения", Tags: []string{"legacy"}, Deprecated: true,
+			Parameters:  []*openapi.Parameter{tenant, idempotencyKey, requestIDHeader},
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(legacyCreate)},
+			Responses: map[string]*openapi.Response{
+				"200": {
+					Description: "Идентификатор задачи",
+					Headers:     map[string]*openapi.Parameter{"Idempotent-Replayed": replayed},
+					Content: openapi.JSON(&openapi.Schema{
+						Type: "object", Properties: map[string]*openapi.Schema{"task_id": {Type: "string"}},
+					}),
+				},
+				"400": {Description: "Некорректное выражение"},
+				"409": {Description: "Ключ идемпотентности использован с другим запросом"},
+				"429": {Description: "Превышено число невычисленных выражений арендатора, суточная квота клиента или предел частоты запросов"},
+				"503": backlogFull,
+			},
+		},
+		"GET /get-expressions": {
+			Summary: "Страница списка выражений", Tags: []string{"legacy"}, Deprecated: true,
+			Parameters: listParams,
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Выражения", Headers: pagedHeader, Content: openapi.JSON(tasksSchema)},
+				"400": {Description: "Некорректные параметры выборки"},
+			},
+		},
+		"GET /get-expression": {
+			Summary: "Выражение по идентификатору запроса", Tags: []string{"legacy"}, Deprecated: true,
+			Parameters: []*openapi.Parameter{{Name: "requestID", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+			Responses:  map[string]*openapi.Response{"200": {Description: "Выражение", Content: openapi.JSON(taskSchema)}},
+		},
+		"POST /update-operations": {
+			Summary: "Обновление времени выполнения операций", Tags: []string{"legacy"}, Deprecated: true,
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(timingsSchema)},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Время выполнения обновлено"},
+				"400": {Description: "Неизвестная операция или некорректная длительность"},
+			},
+		},
+		"GET /get-available-operations": {
+			Summary: "Время выполнения операций", Tags: []string{"legacy"}, Deprecated: true,
+			Responses: map[string]*openapi.Response{"200": {Description: "Время выполнения операций", Content: openapi.JSON(operationTimings)}},
+		},
+		"POST /add-function": {
+			Summary: "Регистрация пользовательской функции", Tags: []string{"legacy"}, Deprecated: true,
+			Parameters:  []*openapi.Parameter{tenant},
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(functionSchema)},
+			Responses: map[string]*openapi.Response{
+				"201": {Description: "Новая версия функции", Content: openapi.JSON(doc.SchemaOf(task.Function{}))},
+				"400": {Description: "Некорректное определение"},
+				"429": {Description: "Превышено число пользовательских функций арендатора или предел частоты запросов"},
+			},
+		},
+		"GET /get-functions": {
+			Summary: "Последние версии пользовательских функций", Tags: []string{"legacy"}, Deprecated: true,
+			Parameters: []*openapi.Parameter{tenant},
+			Responses:  map[string]*openapi.Response{"200": {Description: "Функции", Content: openapi.JSON(functionsSchema)}},
+		},
+		"GET /get-function": {
+			Summary: "Все версии пользовательской функции", Tags: []string{"legacy"}, Deprecated: true,
+			Parameters: []*openapi.Parameter{tenant, {Name: "name", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Версии функции", Content: openapi.JSON(functionsSchema)},
+				"404": {Description: "Функция не найдена"},
+			},
+		},
+		"GET /expressions/{id}/trace": {
+			Summary: "Трассировка вычисления", Tags: []string{"legacy"}, Deprecated: true,
+			Parameters: []*openapi.Parameter{taskID, traceFormat},
+			Responses:  traceResponses,
+		},
+		"GET /get-cache-stats": {
+			Summary: "Статистика кэша результатов", Tags: []string{"legacy"}, Deprecated: true,
+			Responses: map[string]*openapi.Response{"200": {Description: "Статистика", Content: openapi.JSON(statsSchema)}},
+		},
+		"POST /evaluate":                  evaluate,
+		"POST " + APIPrefix + "/evaluate": evaluate,
+		"GET /openapi.json": {
+			Summary: "Документ OpenAPI", Tags: []string{"meta"},
+			Responses: openapiResponses,
+		},
+		"GET /metrics": {
+			Summary:     "Метрики Prometheus",
+			Description: "Выражения, очереди задач, агенты и HTTP-запросы в текстовом формате Prometheus",
+			Tags:        []string{"meta"},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Метрики", Content: map[string]openapi.MediaType{
+					"text/plain": {Schema: &openapi.Schema{Type: "string"}},
+				}},
+			},
+		},
+		"GET /healthz": {
+			Summary:     "Проверка работоспособности",
+			Description: "Соединение с базой данных и работа агентов; без учетных данных и ограничения частоты",
+			Tags:        []string{"meta"},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Все проверки пройдены", Content: openapi.JSON(healthSchema)},
+				"503": {Description: "Хотя бы одна проверка не пройдена", Content: openapi.JSON(healthSchema)},
+			},
+		},
+		"GET /readyz": {
+			Summary:     "Проверка готовности",
+			Description: "Проверки /healthz и заполненность очереди ожидающих задач меньше 90% от CALCFLOW_MAX_BACKLOG",
+			Tags:        []string{"meta"},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Сервер готов принимать выражения", Content: openapi.JSON(healthSchema)},
+				"503": {Description: "Сервер не готов принимать выражения", Content: openapi.JSON(healthSchema)},
+			},
+		},
+		"GET /debug/state": {
+			Summary:     "Диагностика",
+			Description: "Агенты и вычисляемые ими задачи, очереди арендаторов и время выполнения операций",
+			Tags:        []string{"meta"},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Состояние", Content: openapi.JSON(doc.SchemaOf(orchestrator.DebugState{}))},
+			},
+		},
+
+		// API v2
+		"POST " + APIPrefix + "/expressions": {
+			Summary: "Добавление выражения", Tags: []string{"expressions"},
+			Parameters:  []*openapi.Parameter{tenant, idempotencyKey, requestIDHeader},
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(createSchema)},
+			Responses: map[string]*openapi.Response{
+				"201": {
+					Description: "Задача создана",
+					Headers:     map[string]*openapi.Parameter{"Location": location["Location"], "Idempotent-Replayed": replayed},
+					Content:     openapi.JSON(taskSchema),
+				},
+				"400": {Description: "Некорректное выражение", Content: openapi.JSON(errorSchema)},
+				"409": {Description: "Идентификатор запроса уже использован или ключ идемпотентности использован с другим запросом", Content: openapi.JSON(errorSchema)},
+				"429": {Description: "Превышено число невычисленных выражений арендатора, суточная квота клиента или предел частоты запросов", Content: openapi.JSON(errorSchema)},
+				"503": &backlogFullV2,
+			},
+		},
+		"GET " + APIPrefix + "/expressions": {
+			Summary: "Страница списка выражений", Tags: []string{"expressions"},
+			Parameters: listParams,
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Выражения", Content: openapi.JSON(listSchema)},
+				"400": {Description: "Некорректные параметры выборки", Content: openapi.JSON(errorSchema)},
+			},
+		},
+		"GET " + APIPrefix + "/expressions/{id}": {
+			Summary: "Выражение по идентификатору задачи", Tags: []string{"expressions"},
+			Parameters: []*openapi.Parameter{taskID, {
+				Name: "wait", In: "query",
+				Description: fmt.Sprintf("Ожидать завершения вычисления не дольше заданного времени, например 30s; не более %v", MaxWait),
+				Schema:      &openapi.Schema{Type: "string", Format: "duration"},
+			}},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Выражение", Content: openapi.JSON(taskSchema)},
+				"404": {Description: "Выражение не найдено", Content: openapi.JSON(errorSchema)},
+			},
+		},
+		"DELETE " + APIPrefix + "/expressions/{id}": {
+			Summary: "Отмена вычисления выражения", Tags: []string{"expressions"},
+			Parameters: []*openapi.Parameter{taskID},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Отмененное выражение", Content: openapi.JSON(taskSchema)},
+				"404": {Description: "Выражение не найдено", Content: openapi.JSON(errorSchema)},
+				"409": {Description: "Вычисление уже завершено", Content: openapi.JSON(errorSchema)},
+			},
+		},
+		"GET " + APIPrefix + "/expressions/{id}/events": {
+			Summary:     "Поток изменений выражения",
+			Description: "События Server-Sent Events \"task\" с состоянием задачи; поток закрывается после завершения вычисления",
+			Tags:        []string{"expressions"},
+			Parameters:  []*openapi.Parameter{taskID},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Поток событий", Content: map[string]openapi.MediaType{
+					"text/event-stream": {Schema: &openapi.Schema{Type: "string"}},
+				}},
+				"404": {Description: "Выражение не найдено", Content: openapi.JSON(errorSchema)},
+			},
+		},
+		"GET " + APIPrefix + "/expressions/{id}/trace": {
+			Summary: "Трассировка вычисления", Tags: []string{"expressions"},
+			Parameters: []*openapi.Parameter{taskID, traceFormat},
+			Responses:  traceResponses,
+		},
+		"GET " + APIPrefix + "/operations": {
+			Summary: "Время выполнения операций", Tags: []string{"operations"},
+			Responses: map[string]*openapi.Response{"200": {Description: "Время выполнения операций", Content: openapi.JSON(operationTimings)}},
+		},
+		"PUT " + APIPrefix + "/operations": {
+			Summary: "Обновление времени выполнения операций", Tags: []string{"operations"},
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(operationTimings)},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Время выполнения всех операций после обновления", Content: openapi.JSON(operationTimings)},
+				"400": {Description: "Неизвестная операция или некорректная длительность", Content: openapi.JSON(errorSchema)},
+			},
+		},
+		"POST " + APIPrefix + "/functions": {
+			Summary: "Регистрация пользовательской функции", Tags: []string{"functions"},
+			Parameters:  []*openapi.Parameter{tenant},
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(functionSchema)},
+			Responses: map[string]*openapi.Response{
+				"201": {Description: "Новая версия функции", Headers: location, Content: openapi.JSON(doc.SchemaOf(task.Function{}))},
+				"400": {Description: "Некорректное определение", Content: openapi.JSON(errorSchema)},
+				"429": {Description: "Превышено число пользовательских функций арендатора или предел частоты запросов", Content: openapi.JSON(errorSchema)},
+			},
+		},
+		"GET " + APIPrefix + "/functions": {
+			Summary: "Последние версии пользовательских функций", Tags: []string{"functions"},
+			Parameters: []*openapi.Parameter{tenant},
+			Responses:  map[string]*openapi.Response{"200": {Description: "Функции", Content: openapi.JSON(functionsSchema)}},
+		},
+		"GET " + APIPrefix + "/functions/{name}": {
+			Summary: "Все версии пользовательской функции", Tags: []string{"functions"},
+			Parameters: []*openapi.Parameter{tenant, {Name: "name", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Версии функции", Content: openapi.JSON(functionsSchema)},
+				"404": {Description: "Функция не найдена", Content: openapi.JSON(errorSchema)},
+			},
+		},
+		"GET " + APIPrefix + "/cache/stats": {
+			Summary: "Статистика кэша результатов", Tags: []string{"cache"},
+			Responses: map[string]*openapi.Response{"200": {Description: "Статистика", Content: openapi.JSON(statsSchema)}},
+		},
+		"POST " + APIPrefix + "/users": {
+			Summary:     "Регистрация пользователя",
+			Description: "Без учетных данных пользователь регистрируется только в арендаторе default; в другом арендаторе нужны учетные данные, привязанные к нему, или ключ администратора, не привязанный к арендатору",
+			Tags:        []string{"users"},
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(credentialsSchema)},
+			Responses: map[string]*openapi.Response{
+				"201": {Description: "Пользователь", Content: openapi.JSON(doc.SchemaOf(task.User{}))},
+				"400": {Description: "Некорректное имя или слишком короткий пароль", Content: openapi.JSON(errorSchema)},
+				"401": {Description: "Регистрация в арендаторе, кроме default, без учетных данных или с недействительными", Content: openapi.JSON(errorSchema)},
+				"403": {Description: "Учетные данные не позволяют регистрировать пользователей в арендаторе", Content: openapi.JSON(errorSchema)},
+				"409": {Description: "Имя уже занято в арендаторе", Content: openapi.JSON(errorSchema)},
+			},
+		},
+		"POST " + APIPrefix + "/login": {
+			Summary:     "Вход пользователя",
+			Description: "Пользователь ищется в арендаторе из заголовка X-Tenant-ID. Токен доступа передается в заголовке Authorization: Bearer и дает области доступа submit и read к собственным выражениям пользователя",
+			Tags:        []string{"users"},
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(credentialsSchema)},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Токен доступа", Content: openapi.JSON(doc.SchemaOf(orchestrator.Token{}))},
+				"401": {Description: "Неверное имя или пароль", Content: openapi.JSON(errorSchema)},
+			},
+		},
+		"POST " + APIPrefix + "/keys": {
+			Summary:     "Создание ключа API",
+			Description: "Значение ключа возвращается только в ответе и не хранится на сервере",
+			Tags:        []string{"keys"},
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(createKeySchema)},
+			Responses: map[string]*openapi.Response{
+				"201": {Description: "Ключ и его значение; ключ с tenant дает доступ только к этому арендатору", Headers: location, Content: openapi.JSON(keySecretSchema)},
+				"400": {Description: "Неизвестная область доступа", Content: openapi.JSON(errorSchema)},
+			},
+		},
+		"GET " + APIPrefix + "/keys": {
+			Summary: "Список ключей API", Tags: []string{"keys"},
+			Responses: map[string]*openapi.Response{"200": {Description: "Ключи", Content: openapi.JSON(&openapi.Schema{Type: "array", Items: keySchema})}},
+		},
+		"POST " + APIPrefix + "/keys/{id}/rotate": {
+			Summary:     "Замена значения ключа API",
+			Description: "Прежнее значение ключа перестает действовать",
+			Tags:        []string{"keys"},
+			Parameters:  []*openapi.Parameter{keyID},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Ключ и его новое значение", Content: openapi.JSON(keySecretSchema)},
+				"404": {Description: "Ключ не найден", Content: openapi.JSON(errorSchema)},
+				"409": {Description: "Ключ отозван", Content: openapi.JSON(errorSchema)},
+			},
+		},
+		"DELETE " + APIPrefix + "/keys/{id}": {
+			Summary: "Отзыв ключа API", Tags: []string{"keys"},
+			Parameters: []*openapi.Parameter{keyID},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Отозванный ключ", Content: openapi.JSON(keySchema)},
+				"404": {Description: "Ключ не найден", Content: openapi.JSON(errorSchema)},
+			},
+		},
+		"GET " + APIPrefix + "/tenants": {
+			Summary: "Арендаторы с сохраненными настройками", Tags: []string{"tenants"},
+			Responses: map[string]*openapi.Response{"200": {Description: "Арендаторы", Content: openapi.JSON(&openapi.Schema{Type: "array", Items: tenantSchema})}},
+		},
+		"GET " + APIPrefix + "/tenants/{id}": {
+			Summary:     "Настройки арендатора",
+			Description: "Для арендатора без сохраненных настроек возвращаются настройки по умолчанию",
+			Tags:        []string{"tenants"},
+			Parameters:  []*openapi.Parameter{tenantID},
+			Responses:   map[string]*openapi.Response{"200": {Description: "Настройки", Content: openapi.JSON(tenantSchema)}},
+		},
+		"PUT " + APIPrefix + "/tenants/{id}": {
+			Summary:     "Изменение настроек арендатора",
+			Description: "Время выполнения операций арендатора заменяет общее; доля мощности агентов от 0 до 1; ограничения 0 - без ограничения",
+			Tags:        []string{"tenants"},
+			Parameters:  []*openapi.Parameter{tenantID},
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(tenantSettingsSchema)},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Сохраненные настройки", Content: openapi.JSON(tenantSchema)},
+				"400": {Description: "Некорректные настройки", Content: openapi.JSON(errorSchema)},
+			},
+		},
+		"GET " + APIPrefix + "/usage": {
+			Summary:     "Предел частоты запросов и суточная квота",
+			Description: "Клиент - ключ API, пользователь или, без учетных данных, IP-адрес. Квота расходуется оценкой времени вычисления добавленных выражений и восстанавливается в начале суток по UTC",
+			Tags:        []string{"usage"},
+			Responses:   map[string]*openapi.Response{"200": {Description: "Расход квоты клиента", Content: openapi.JSON(usageSchema)}},
+		},
+		"GET " + APIPrefix + "/queue": {
+			Summary:     "Состояние очереди задач",
+			Description: "Задачи сохраняются невычисленными и отправляются агентам по мере освобождения мощности; при заполненной очереди новые выражения отклоняются с кодом 503",
+			Tags:        []string{"queue"},
+			Responses:   map[string]*openapi.Response{"200": {Description: "Очередь задач", Content: openapi.JSON(queueSchema)}},
+		},
+		"GET " + APIPrefix + "/openapi.json": {
+			Summary: "Документ OpenAPI", Tags: []string{"meta"},
+			Responses: openapiResponses,
+		},
+	}
+}
+
+// operationTimingsSchema строит схему времени выполнения по реестру операций.
+func operationTimingsSchema() *openapi.Schema {
+	s := &openapi.Schema{
+		Type:        "object",
+		Description: "Время выполнения операций, например \"250ms\"; названия операций не зависят от регистра",
+		Closed:      true,
+		FoldCase:    true,
+		Properties:  make(map[string]*openapi.Schema),
+	}
+	for _, op := range operation.Default.All() {
+		s.Properties[op.Name] = &openapi.Schema{
+			Type:        "string",
+			Format:      "duration",
+			Description: "Операторы: " + strings.Join(op.Symbols, ", "),
+		}
+	}
+	return s
+}
+
+// listParameters описывает параметры выборки списка выражений.
+func listParameters() []*openapi.Parameter {
+	minLimit, maxLimit := float64(1), float64(database.MaxPageSize)
+	return []*openapi.Parameter{
+		{Name: "status", In: "query", Description: "Статусы через запятую", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "created_after", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+		{Name: "created_before", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+		{Name: "request_id_prefix", In: "query", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "has_result", In: "query", Schema: &openapi.Schema{Type: "boolean"}},
+		{Name: "sort", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []string{
+			database.SortByCreated, database.SortByFinished, database.SortByDuration,
+		}}},
+		{Name: "order", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []string{"asc", "desc"}}},
+		{Name: "limit", In: "query", Schema: &openapi.Schema{Type: "integer", Minimum: &minLimit, Maximum: &maxLimit}},
+		{Name: "cursor", In: "query", Schema: &openapi.Schema{Type: "string"}},
+	}
+}
+
+// Получение документа OpenAPI с описанием всех маршрутов сервера.
+func (s *Server) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.openapi)
+}
+
+// validateRequests возвращает промежуточный обработчик, отклоняющий запросы,
+// параметры или тело которых не соответствуют документу OpenAPI.
+func validateRequests(doc *openapi.Document) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op := operationFor(doc, r)
+			if op == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := validateRequest(doc, op, r); err != nil {
+				writeRouteError(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// operationFor возвращает описание операции, соответствующей маршруту запроса.
+func operationFor(doc *openapi.Document, r *http.Request) *openapi.Operation {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return nil
+	}
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return nil
+	}
+	item, ok := doc.Paths[path]
+	if !ok {
+		return nil
+	}
+	return (*item)[strings.ToLower(r.Method)]
+}
+
+// validateRequest проверяет параметры запроса и тело на соответствие операции.
+// Прочитанное тело подставляется обратно в запрос.
+func validateRequest(doc *openapi.Document, op *openapi.Operation, r *http.Request) error {
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		if param.In != "query" {
+			continue
+		}
+		raw, ok := query[param.Name]
+		if !ok {
+			if param.Required {
+				return &openapi.ValidationError{Problems: []string{"query." + param.Name + ": is required"}}
+			}
+			continue
+		}
+		value, err := queryValue(param.Schema, raw[0])
+		if err != nil {
+			return &openapi.ValidationError{Problems: []string{"query." + param.Name + ": " + err.Error()}}
+		}
+		if err := doc.Validate(param.Schema, value, "query."+param.Name); err != nil {
+			return err
+		}
+	}
+
+	if op.RequestBody == nil {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxBodySize {
+		return fmt.Errorf("request body exceeds %d bytes", maxBodySize)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("request body is not valid JSON: %v", err)
+	}
+	return doc.Validate(op.RequestBody.Content["application/json"].Schema, value, "body")
+}
+
+// queryValue преобразует значение параметра запроса к типу его схемы.
+func queryValue(schema *openapi.Schema, raw string) (interface{}, error) {
+	switch schema.Type {
+	case "integer", "number":
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a %s", schema.Type)
+		}
+		return value, nil
+	case "boolean":
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be a boolean")
+		}
+		return value, nil
+	}
+	return raw, nil
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"calcflow/backend/internal/database"
+)
+
+// parseTaskQuery извлекает фильтры, сортировку и страницу выборки задач
+// из параметров запроса. Запросу доступны только задачи его арендатора,
+// а пользователю и ключу API без области admin - только их собственные задачи.
+func parseTaskQuery(r *http.Request) (database.TaskQuery, error) {
+	params := r.URL.Query()
+	access := access(r)
+	query := database.TaskQuery{
+		Owner:           access.Owner,
+		APIKeyID:        access.APIKeyID,
+		Tenant:          access.Tenant,
+		RequestIDPrefix: params.Get("request_id_prefix"),
+		SortBy:          params.Get("sort"),
+		Cursor:          params.Get("cursor"),
+	}
+
+	if status := params.Get("status"); status != "" {
+		query.Statuses = strings.Split(status, ",")
+	}
+
+	var err error
+	if value := params.Get("created_after"); value != "" {
+		if query.CreatedAfter, err = time.Parse(time.RFC3339, value); err != nil {
+			return query, fmt.Errorf("invalid created_after: %v", err)
+		}
+	}
+	if value := params.Get("created_before"); value != "" {
+		if query.CreatedBefore, err = time.Parse(time.RFC3339, value); err != nil {
+			return query, fmt.Errorf("invalid created_before: %v", err)
+		}
+	}
+
+	if value := params.Get("has_result"); value != "" {
+		hasResult, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, fmt.Errorf("invalid has_result: %v", err)
+		}
+		query.HasResult = &hasResult
+	}
+
+	switch order := params.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, fmt.Errorf("invalid order %q", order)
+	}
+
+	if value := params.Get("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit <= 0 {
+			return query, fmt.Errorf("invalid limit %q", value)
+		}
+	}
+
+	return query, nil
+}
+
+// MaxWait - наибольшее время ожидания результата, задаваемое параметром wait.
+const MaxWait = time.Minute
+
+// parseWait извлекает время ожидания результата из параметра wait, например "30s".
+// Значения больше MaxWait уменьшаются до MaxWait; без параметра возвращается 0.
+func parseWait(r *http.Request) (time.Duration, error) {
+	value := r.URL.Query().Get("wait")
+	if value == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(value)
+	if err != nil || wait < 0 {
+		return 0, fmt.Errorf("invalid wait: %q", value)
+	}
+	return min(wait, MaxWait), nil
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"calcflow/backend/internal/orchestrator"
+)
+
+// backlogRetryAfter - через сколько предлагается повторить запрос, отклоненный
+// из-за заполненной очереди задач.
+const backlogRetryAfter = 5 * time.Second
+
+// writeBacklogError отвечает 503 на заполненную очередь задач. Число ожидающих
+// задач и ограничение передаются в заголовках X-Queue-Depth и X-Queue-Limit.
+func writeBacklogError(w http.ResponseWriter, r *http.Request, err error) {
+	var backlog *orchestrator.BacklogError
+	if errors.As(err, &backlog) {
+		w.Header().Set("X-Queue-Depth", strconv.Itoa(backlog.Depth))
+		w.Header().Set("X-Queue-Limit", strconv.Itoa(backlog.Limit))
+	}
+	setRetryAfter(w, backlogRetryAfter)
+	writeRouteError(w, r, http.StatusServiceUnavailable, err.Error())
+}
+
+// Получение состояния очереди задач
+func (s *Server) GetQueueHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.orchestrator.GetQueueStats())
+}
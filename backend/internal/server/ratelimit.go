@@ -0,0 +1,205 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"calcflow/backend/internal/orchestrator"
+)
+
+// RateLimit задает предел частоты запросов клиента по алгоритму маркерной корзины:
+// корзина вмещает Burst запросов и пополняется со скоростью Rate запросов в секунду.
+type RateLimit struct {
+	Rate  float64 `json:"rate"`  // Запросов в секунду; 0 отключает ограничение
+	Burst int     `json:"burst"` // Наибольшее число запросов подряд
+}
+
+// DefaultRateLimit - предел частоты запросов клиента по умолчанию.
+var DefaultRateLimit = RateLimit{Rate: 10, Burst: 20}
+
+// sweepInterval - период удаления корзин клиентов, которые успели заполниться.
+const sweepInterval = time.Minute
+
+// bucket - маркерная корзина клиента.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimiter хранит маркерные корзины клиентов.
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   RateLimit
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{limit: limit, buckets: make(map[string]*bucket)}
+}
+
+// configure заменяет предел частоты; накопленные корзины сбрасываются.
+// Корзина вмещает хотя бы один запрос.
+func (l *rateLimiter) configure(limit RateLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit.Burst = max(limit.Burst, 1)
+	l.limit = limit
+	l.buckets = make(map[string]*bucket)
+}
+
+// take забирает маркер из корзины клиента. Если корзина пуста, возвращает
+// время до появления маркера.
+func (l *rateLimiter) take(client string, now time.Time) (status RateLimitStatus, retry time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	status = RateLimitStatus{Rate: l.limit.Rate, Burst: l.limit.Burst, Remaining: l.limit.Burst}
+	if l.limit.Rate <= 0 {
+		return status, 0, true
+	}
+	l.sweep(now)
+	b := l.refill(client, now)
+	if b.tokens < 1 {
+		status.Remaining = 0
+		return status, time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second)), false
+	}
+	b.tokens--
+	status.Remaining = int(b.tokens)
+	return status, 0, true
+}
+
+// status возвращает предел частоты и число маркеров в корзине клиента, не расходуя их.
+func (l *rateLimiter) status(client string, now time.Time) RateLimitStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	status := RateLimitStatus{Rate: l.limit.Rate, Burst: l.limit.Burst, Remaining: l.limit.Burst}
+	if l.limit.Rate > 0 {
+		status.Remaining = int(l.refill(client, now).tokens)
+	}
+	return status
+}
+
+// refill пополняет корзину клиента за время с последнего обращения. Вызывается под l.mu.
+func (l *rateLimiter) refill(client string, now time.Time) *bucket {
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), updated: now}
+		l.buckets[client] = b
+	}
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+elapsed*l.limit.Rate)
+	b.updated = now
+	return b
+}
+
+// sweep удаляет заполнившиеся корзины: они не отличаются от новых. Вызывается под l.mu.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	l.swept = now
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.limit.Rate >= float64(l.limit.Burst) {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// ConfigureRateLimit задает предел частоты запросов каждого клиента.
+func (s *Server) ConfigureRateLimit(limit RateLimit) {
+	s.limiter.configure(limit)
+}
+
+// limitRate ограничивает частоту запросов каждого клиента. Оставшееся число
+// запросов передается в заголовках X-RateLimit-Limit и X-RateLimit-Remaining,
+// а при превышении предела возвращается 429 с заголовком Retry-After.
+// Проверки работоспособности не ограничиваются.
+func (s *Server) limitRate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probeRoute(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		status, retry, ok := s.limiter.take(client(r), time.Now())
+		if status.Rate > 0 {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(status.Burst))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(status.Remaining))
+		}
+		if !ok {
+			setRetryAfter(w, retry)
+			writeRouteError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// client возвращает клиента, которому принадлежат предел частоты запросов
+// и суточная квота: ключ API, пользователя или, без учетных данных, IP-адрес.
+func client(r *http.Request) string {
+	p := principalFrom(r)
+	switch {
+	case p.key != nil:
+		return "key:" + p.key.ID
+	case p.user != nil:
+		return "user:" + p.user.ID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// setRetryAfter задает заголовок Retry-After в целых секундах, округляя вверх.
+func setRetryAfter(w http.ResponseWriter, retry time.Duration) {
+	seconds := int(math.Ceil(retry.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+}
+
+// writeQuotaError отвечает 429 на превышение ограничений арендатора или суточной
+// квоты клиента; для суточной квоты Retry-After указывает на начало следующих суток.
+func writeQuotaError(w http.ResponseWriter, r *http.Request, err error) {
+	var quota *orchestrator.QuotaError
+	if errors.As(err, &quota) {
+		setRetryAfter(w, time.Until(quota.Reset))
+	}
+	writeRouteError(w, r, http.StatusTooManyRequests, err.Error())
+}
+
+// UsageResponse описывает предел частоты запросов и расход суточной квоты клиента.
+type UsageResponse struct {
+	Client    string                   `json:"client"`
+	RateLimit RateLimitStatus          `json:"rate_limit"`
+	Quota     *orchestrator.QuotaUsage `json:"quota"`
+}
+
+// RateLimitStatus описывает предел частоты запросов клиента и остаток запросов.
+type RateLimitStatus struct {
+	Rate      float64 `json:"rate"`
+	Burst     int     `json:"burst"`
+	Remaining int     `json:"remaining"` // Запросов, которые можно выполнить сразу
+}
+
+// Получение предела частоты запросов и расхода суточной квоты клиента
+func (s *Server) UsageHandler(w http.ResponseWriter, r *http.Request) {
+	c := client(r)
+	usage, err := s.orchestrator.GetUsage(c)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, UsageResponse{
+		Client:    c,
+		RateLimit: s.limiter.status(c, time.Now()),
+		Quota:     usage,
+	})
+}
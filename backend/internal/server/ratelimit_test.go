@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"calcflow/backend/internal/task"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(RateLimit{Rate: 2, Burst: 3})
+	now := time.Unix(1700000000, 0)
+
+	for i := 3; i > 0; i-- {
+		status, _, ok := l.take("a", now)
+		if !ok || status.Remaining != i-1 {
+			t.Fatalf("take %d: ok %v remaining %d, want true and %d", 4-i, ok, status.Remaining, i-1)
+		}
+	}
+	status, retry, ok := l.take("a", now)
+	if ok || status.Remaining != 0 || retry != 500*time.Millisecond {
+		t.Errorf("empty bucket: ok %v remaining %d retry %v, want false, 0 and 500ms", ok, status.Remaining, retry)
+	}
+
+	// Корзины клиентов независимы
+	if _, _, ok := l.take("b", now); !ok {
+		t.Error("another client is limited")
+	}
+
+	// За секунду корзина пополняется на два маркера, но не больше Burst
+	if got := l.status("a", now.Add(time.Second)).Remaining; got != 2 {
+		t.Errorf("remaining after a second %d, want 2", got)
+	}
+	if got := l.status("a", now.Add(time.Hour)).Remaining; got != 3 {
+		t.Errorf("remaining after an hour %d, want 3", got)
+	}
+
+	// Заполнившиеся корзины удаляются
+	l.take("a", now.Add(time.Hour+sweepInterval))
+	if _, ok := l.buckets["b"]; ok {
+		t.Error("full bucket was not swept")
+	}
+
+	l.configure(RateLimit{})
+	for i := 0; i < 10; i++ {
+		if _, _, ok := l.take("a", now); !ok {
+			t.Fatal("disabled limit rejects requests")
+		}
+	}
+	if l.limit.Burst != 1 {
+		t.Errorf("burst %d, want at least 1", l.limit.Burst)
+	}
+}
+
+func TestLimitRate(t *testing.T) {
+	ts := newTestServer(t)
+	other := createKey(t, ts, `{"name": "other", "scopes": ["read"]}`)
+	ts.server.ConfigureRateLimit(RateLimit{Rate: 0.001, Burst: 2})
+
+	for i := 1; i >= 0; i-- {
+		resp := ts.do(t, "GET", APIPrefix+"/operations", "", nil)
+		if resp.status != http.StatusOK {
+			t.Fatalf("status %d, want 200: %s", resp.status, resp.body)
+		}
+		if resp.header.Get("X-RateLimit-Limit") != "2" || resp.header.Get("X-RateLimit-Remaining") != strconv.Itoa(i) {
+			t.Errorf("X-RateLimit-Limit %q, X-RateLimit-Remaining %q; want 2 and %d",
+				resp.header.Get("X-RateLimit-Limit"), resp.header.Get("X-RateLimit-Remaining"), i)
+		}
+	}
+	resp := ts.do(t, "GET", APIPrefix+"/operations", "", nil)
+	if resp.status != http.StatusTooManyRequests {
+		t.Fatalf("status %d, want 429: %s", resp.status, resp.body)
+	}
+	if retry, err := strconv.Atoi(resp.header.Get("Retry-After")); err != nil || retry < 1 {
+		t.Errorf("Retry-After %q, want positive seconds", resp.header.Get("Retry-After"))
+	}
+
+	// Проверки работоспособности и другие клиенты не ограничиваются
+	if resp := ts.do(t, "GET", "/readyz", "", nil); resp.status == http.StatusTooManyRequests {
+		t.Error("readiness probe is rate limited")
+	}
+	if resp := ts.do(t, "GET", APIPrefix+"/operations", "", as(other)); resp.status != http.StatusOK {
+		t.Errorf("another key: status %d, want 200", resp.status)
+	}
+}
+
+func TestDailyQuota(t *testing.T) {
+	ts := newTestServer(t)
+	ts.orchestrator.ConfigureQuota(50 * time.Millisecond)
+	if err := ts.orchestrator.UpdateCalculateTime(task.DefaultTenant, task.CalculationRequest{"summation": "20ms"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if resp := ts.do(t, "POST", APIPrefix+"/expressions", `{"expression": "1 + `+strconv.Itoa(i)+`"}`, nil); resp.status != http.StatusCreated {
+			t.Fatalf("status %d, want 201: %s", resp.status, resp.body)
+		}
+	}
+	resp := ts.do(t, "POST", APIPrefix+"/expressions", `{"expression": "1 + 2"}`, nil)
+	if resp.status != http.StatusTooManyRequests {
+		t.Fatalf("status %d, want 429: %s", resp.status, resp.body)
+	}
+	if retry, err := strconv.Atoi(resp.header.Get("Retry-After")); err != nil || retry < 1 || retry > 24*60*60 {
+		t.Errorf("Retry-After %q, want seconds until the next day", resp.header.Get("Retry-After"))
+	}
+
+	// Выражение дешевле остатка квоты принимается
+	if resp := ts.do(t, "POST", APIPrefix+"/expressions", `{"expression": "7"}`, nil); resp.status != http.StatusCreated {
+		t.Errorf("expression without operations: status %d, want 201: %s", resp.status, resp.body)
+	}
+
+	var usage UsageResponse
+	ts.do(t, "GET", APIPrefix+"/usage", "", nil).decode(t, &usage)
+	q := usage.Quota
+	if q.Expressions != 3 || q.Operations != 2 || q.Used != 40*time.Millisecond || q.Remaining != 10*time.Millisecond || q.Limit != 50*time.Millisecond {
+		t.Errorf("quota %+v, want 3 expressions, 2 operations, 40ms used of 50ms", q)
+	}
+	if usage.Client == "" || usage.Client[:4] != "key:" {
+		t.Errorf("client %q, want the API key", usage.Client)
+	}
+}
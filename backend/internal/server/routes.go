@@ -0,0 +1,72 @@
+package server
+
+import (
+	"github.com/gorilla/mux"
+)
+
+// Router возвращает маршрутизатор со всеми обработчиками сервера:
+// ресурсным API /api/v2 и прежними маршрутами для совместимости.
+// Запросы проверяются по документу OpenAPI, который отдается по /openapi.json;
+// области доступа ключей API к маршрутам также задаются в нем.
+// Частота запросов каждого клиента ограничена после проверки учетных данных.
+// Метрики учитывают все запросы, в том числе отклоненные.
+func (s *Server) Router() *mux.Router {
+	router := mux.NewRouter()
+
+	// Прежние маршруты, сохраненные для совместимости
+	router.HandleFunc("/add-calculation", s.idempotent("id", s.AddExpressionHandler)).Methods("POST")
+	router.HandleFunc("/get-expressions", s.GetExpressionsHandler).Methods("GET")
+	router.HandleFunc("/get-expression", s.GetExpressionByIDHandler).Methods("GET")
+	router.HandleFunc("/update-operations", s.UpdateOperationsHandler).Methods("POST")
+	router.HandleFunc("/get-available-operations", s.GetAvailableOperationsHandler).Methods("GET")
+	router.HandleFunc("/add-function", s.AddFunctionHandler).Methods("POST")
+	router.HandleFunc("/get-functions", s.GetFunctionsHandler).Methods("GET")
+	router.HandleFunc("/get-function", s.GetFunctionHandler).Methods("GET")
+	router.HandleFunc("/expressions/{id}/trace", s.GetTraceHandler).Methods("GET")
+	router.HandleFunc("/get-cache-stats", s.GetCacheStatsHandler).Methods("GET")
+	router.HandleFunc("/evaluate", s.idempotent("request_id", s.EvaluateHandler)).Methods("POST")
+	router.HandleFunc("/openapi.json", s.OpenAPIHandler).Methods("GET")
+	router.HandleFunc("/metrics", instanceAdmin(s.MetricsHandler)).Methods("GET")
+	router.HandleFunc("/healthz", s.HealthzHandler).Methods("GET")
+	router.HandleFunc("/readyz", s.ReadyzHandler).Methods("GET")
+	router.HandleFunc("/debug/state", instanceAdmin(s.DebugStateHandler)).Methods("GET")
+
+	// Ресурсное API
+	v2 := router.PathPrefix(APIPrefix).Subrouter()
+	v2.HandleFunc("/expressions", s.idempotent("request_id", s.CreateExpressionV2Handler)).Methods("POST")
+	v2.HandleFunc("/expressions", s.ListExpressionsV2Handler).Methods("GET")
+	v2.HandleFunc("/expressions/{id}", s.GetExpressionV2Handler).Methods("GET")
+	v2.HandleFunc("/expressions/{id}", s.CancelExpressionV2Handler).Methods("DELETE")
+	v2.HandleFunc("/expressions/{id}/events", s.ExpressionEventsV2Handler).Methods("GET")
+	v2.HandleFunc("/expressions/{id}/trace", s.GetTraceHandler).Methods("GET")
+	v2.HandleFunc("/operations", s.GetAvailableOperationsHandler).Methods("GET")
+	v2.HandleFunc("/operations", s.UpdateOperationsV2Handler).Methods("PUT")
+	v2.HandleFunc("/functions", s.CreateFunctionV2Handler).Methods("POST")
+	v2.HandleFunc("/functions", s.GetFunctionsHandler).Methods("GET")
+	v2.HandleFunc("/functions/{name}", s.GetFunctionV2Handler).Methods("GET")
+	v2.HandleFunc("/cache/stats", s.GetCacheStatsHandler).Methods("GET")
+	v2.HandleFunc("/users", s.RegisterHandler).Methods("POST")
+	v2.HandleFunc("/login", s.LoginHandler).Methods("POST")
+	v2.HandleFunc("/keys", instanceAdmin(s.CreateAPIKeyHandler)).Methods("POST")
+	v2.HandleFunc("/keys", instanceAdmin(s.ListAPIKeysHandler)).Methods("GET")
+	v2.HandleFunc("/keys/{id}/rotate", instanceAdmin(s.RotateAPIKeyHandler)).Methods("POST")
+	v2.HandleFunc("/keys/{id}", instanceAdmin(s.RevokeAPIKeyHandler)).Methods("DELETE")
+	v2.HandleFunc("/tenants", instanceAdmin(s.ListTenantsHandler)).Methods("GET")
+	v2.HandleFunc("/tenants/{id}", instanceAdmin(s.GetTenantHandler)).Methods("GET")
+	v2.HandleFunc("/tenants/{id}", instanceAdmin(s.UpdateTenantHandler)).Methods("PUT")
+	v2.HandleFunc("/usage", s.UsageHandler).Methods("GET")
+	v2.HandleFunc("/queue", s.GetQueueHandler).Methods("GET")
+	v2.HandleFunc("/evaluate", s.idempotent("request_id", s.EvaluateHandler)).Methods("POST")
+	v2.HandleFunc("/openapi.json", s.OpenAPIHandler).Methods("GET")
+
+	// Описание API строится по зарегистрированным маршрутам, поэтому
+	// незадокументированный маршрут обнаруживается при запуске сервера
+	doc, err := s.buildOpenAPI(router)
+	if err != nil {
+		panic(err)
+	}
+	s.openapi = doc
+	router.Use(traceRequests, logRequests, instrument, s.authenticate(doc), s.limitRate, validateRequests(doc))
+
+	return router
+}
@@ -2,25 +2,29 @@ package server
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
+	"log/slog"
 	"net/http"
-	"time"
 
+	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/expr"
+	"calcflow/backend/internal/openapi"
 	"calcflow/backend/internal/orchestrator"
 	"calcflow/backend/internal/task"
-
-	"github.com/Knetic/govaluate"
 )
 
 // Server представляет HTTP-сервер для обработки запросов.
 type Server struct {
 	orchestrator *orchestrator.Orchestrator
+	openapi      *openapi.Document // Описание маршрутов, построенное в Router
+	limiter      *rateLimiter      // Пределы частоты запросов клиентов
 }
 
 // NewServer создает новый экземпляр HTTP-сервера с заданным оркестратором.
 func NewServer(o *orchestrator.Orchestrator) *Server {
 	return &Server{
 		orchestrator: o,
+		limiter:      newRateLimiter(DefaultRateLimit),
 	}
 }
 
@@ -33,15 +37,20 @@ func (s *Server) AddExpressionHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Извлечение requestID и expression из JSON-тела запроса
 	decoder := json.NewDecoder(r.Body)
-	var requestBody map[string]string
+	var requestBody struct {
+		ID         string `json:"id"`
+		Expression string `json:"expression"`
+		Explain    bool   `json:"explain"`
+		NoCache    bool   `json:"no_cache"`
+	}
 	err := decoder.Decode(&requestBody)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	requestID := requestBody["id"]
-	expression := requestBody["expression"]
+	requestID := requestBody.ID
+	expression := requestBody.Expression
 
 	// Проверка валидности выражения
 	if !isValidExpression(expression) {
@@ -49,23 +58,42 @@ func (s *Server) AddExpressionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Проверка наличия requestID в базе данных
-	if requestID != "" {
-		if unq, err := s.AlreadyExistsRequestID(requestID); err != nil || unq {
-			http.Error(w, "Request ID already exists", http.StatusOK)
-			return
-		}
-	} else {
+	if requestID == "" {
 		http.Error(w, "Request ID is required", http.StatusBadRequest)
 		return
 	}
 
 	// Добавляем вычисление в оркестратор
-	errOrch := s.orchestrator.AddCalculation(expression, taskID, requestID)
+	created, errOrch := s.orchestrator.AddCalculation(r.Context(), expression, taskID, requestID, orchestrator.CalculationOptions{
+		Tenant:   tenantFromRequest(r),
+		Explain:  requestBody.Explain,
+		NoCache:  requestBody.NoCache,
+		APIKeyID: apiKeyID(r),
+		Owner:    owner(r),
+		Client:   client(r),
+	})
+	if errors.Is(errOrch, orchestrator.ErrInvalidExpression) {
+		http.Error(w, errOrch.Error(), http.StatusBadRequest)
+		return
+	}
+	// Повторный requestID отклоняется ограничением уникальности в базе данных
+	if errors.Is(errOrch, orchestrator.ErrDuplicateRequest) {
+		http.Error(w, "Request ID already exists", http.StatusOK)
+		return
+	}
+	if errors.Is(errOrch, orchestrator.ErrQuotaExceeded) {
+		writeQuotaError(w, r, errOrch)
+		return
+	}
+	if errors.Is(errOrch, orchestrator.ErrBacklogFull) {
+		writeBacklogError(w, r, errOrch)
+		return
+	}
 	if errOrch != nil {
 		http.Error(w, errOrch.Error(), http.StatusInternalServerError)
 		return
 	}
+	slog.InfoContext(r.Context(), "expression added", "task_id", taskID, "status", created.Status)
 
 	// Отправляем ID добавленной задачи
 	responseData := map[string]string{"task_id": taskID}
@@ -74,7 +102,8 @@ func (s *Server) AddExpressionHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(responseData)
 }
 
-// Получение списка выражений со статусами.
+// Получение страницы списка выражений со статусами.
+// Курсор следующей страницы передается в заголовке X-Next-Cursor.
 func (s *Server) GetExpressionsHandler(w http.ResponseWriter, r *http.Request) {
 	// Проверяем метод запроса
 	if r.Method != http.MethodGet {
@@ -82,14 +111,28 @@ func (s *Server) GetExpressionsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Получаем фильтры, сортировку и страницу из параметров запроса
+	query, err := parseTaskQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Получаем список выражений с их статусами
-	expressions, err := s.orchestrator.GetExpressions()
+	expressions, next, err := s.orchestrator.ListExpressions(query)
+	if errors.Is(err, database.ErrInvalidQuery) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Отправляем список выражений в формате JSON
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(expressions)
 }
@@ -106,7 +149,11 @@ func (s *Server) GetExpressionByIDHandler(w http.ResponseWriter, r *http.Request
 	requestID := r.URL.Query().Get("requestID")
 
 	// Получаем выражение по его идентификатору
-	task, err := s.orchestrator.GetExpressionByID(requestID)
+	task, err := s.orchestrator.GetExpressionByID(access(r), requestID)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -133,7 +180,12 @@ func (s *Server) UpdateOperationsHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Обновляем данные в БД
-	if err := s.orchestrator.UpdateCalculateTime(newRequestTime); err != nil {
+	err := s.orchestrator.UpdateCalculateTime(tenantFromRequest(r), newRequestTime)
+	if errors.Is(err, orchestrator.ErrInvalidOperation) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -151,7 +203,7 @@ func (s *Server) GetAvailableOperationsHandler(w http.ResponseWriter, r *http.Re
 	}
 
 	// Получаем доступные операции с временем выполнения
-	operations, err := s.orchestrator.GetAvailableOperations()
+	operations, err := s.orchestrator.GetAvailableOperations(tenantFromRequest(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -162,6 +214,25 @@ func (s *Server) GetAvailableOperationsHandler(w http.ResponseWriter, r *http.Re
 	json.NewEncoder(w).Encode(operations)
 }
 
+// Получение статистики кэша результатов вычислений.
+func (s *Server) GetCacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	// Проверяем метод запроса
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stats, err := s.orchestrator.GetCacheStats()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Отправляем статистику в формате JSON
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
 // Получение задачи для выполения. ПОКА ХЗХЗХЗХЗХЗХЗХЗХЗХЗХЗХЗ
 // func (s *Server) getTaskForExecutionHandler(w http.ResponseWriter, r *http.Request) {
 // 	// Проверяем метод запроса
@@ -214,21 +285,25 @@ func (s *Server) GetAvailableOperationsHandler(w http.ResponseWriter, r *http.Re
 
 // Функция для проверки валидности выражения.
 func isValidExpression(expression string) bool {
-	_, err := govaluate.NewEvaluableExpression(expression)
+	_, err := expr.Parse(expression)
 	return err == nil
 }
 
-// Функция для проверки уникальности requestID в базе данных.
-func (s *Server) AlreadyExistsRequestID(requestID string) (bool, error) {
-	unique, err := s.orchestrator.AlreadyExistsRequest(requestID)
-	if err != nil {
-		return unique, err
+// tenantFromRequest возвращает арендатора, от имени которого выполняется запрос:
+// арендатора, к которому привязаны учетные данные, или указанного в заголовке
+// X-Tenant-ID, или арендатора по умолчанию.
+func tenantFromRequest(r *http.Request) string {
+	if tenant := principalFrom(r).tenant(); tenant != "" {
+		return tenant
 	}
-
-	return unique, nil
+	if tenant := r.Header.Get("X-Tenant-ID"); tenant != "" {
+		return tenant
+	}
+	return task.DefaultTenant
 }
 
 // generateTaskID генерирует уникальный идентификатор для задачи.
+// Идентификаторы упорядочены по времени создания.
 func generateTaskID() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano())
+	return task.NewID()
 }
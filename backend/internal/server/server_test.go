@@ -0,0 +1,167 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/orchestrator"
+	"calcflow/backend/internal/task"
+)
+
+// fakeProcessor принимает задачи вместо агента; результаты передаются
+// оркестратору из теста.
+type fakeProcessor struct {
+	mu       sync.Mutex
+	accepted []*task.Task
+}
+
+func (p *fakeProcessor) EnqueueTask(t *task.Task) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.accepted = append(p.accepted, t)
+	return nil
+}
+
+func (p *fakeProcessor) taken() []*task.Task {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]*task.Task(nil), p.accepted...)
+}
+
+// testServer - сервер с базой данных во временном каталоге и ключом администратора.
+type testServer struct {
+	url          string
+	adminKey     string
+	server       *Server
+	orchestrator *orchestrator.Orchestrator
+	processor    *fakeProcessor
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	db, err := database.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	processor := &fakeProcessor{}
+	o, err := orchestrator.NewOrchestrator(db, processor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := o.EnsureAdminKey("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Частота запросов не ограничивается; тесты ограничений задают ее сами
+	s := NewServer(o)
+	s.ConfigureRateLimit(RateLimit{})
+	ts := httptest.NewServer(s.Router())
+	t.Cleanup(ts.Close)
+
+	return &testServer{url: ts.URL, adminKey: key, server: s, orchestrator: o, processor: processor}
+}
+
+// response - ответ сервера с прочитанным телом.
+type response struct {
+	status int
+	header http.Header
+	body   string
+}
+
+// decode разбирает тело ответа в формате JSON в v.
+func (r response) decode(t *testing.T, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(r.body), v); err != nil {
+		t.Fatalf("decode %q: %v", r.body, err)
+	}
+}
+
+// do выполняет запрос с ключом администратора; заголовки header дополняют
+// или заменяют его.
+func (ts *testServer) do(t *testing.T, method, path, body string, header http.Header) response {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.url+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-API-Key", ts.adminKey)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response{status: resp.StatusCode, header: resp.Header, body: string(data)}
+}
+
+// complete передает оркестратору результат принятой задачи, как это делает агент.
+func (ts *testServer) complete(t *testing.T, taskID, result string) {
+	t.Helper()
+	for _, taken := range ts.processor.taken() {
+		if taken.ID == taskID {
+			done := *taken
+			done.Status = "completed"
+			done.Result = result
+			if err := ts.orchestrator.ReceiveResult(&done); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+	}
+	t.Fatalf("task %s was not dispatched", taskID)
+}
+
+func TestAddExpressionHandler(t *testing.T) {
+	ts := newTestServer(t)
+
+	resp := ts.do(t, "POST", "/add-calculation", `{"id": "legacy-1", "expression": "2 + 2"}`, nil)
+	if resp.status != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.status, resp.body)
+	}
+	var created struct {
+		TaskID string `json:"task_id"`
+	}
+	resp.decode(t, &created)
+	ts.complete(t, created.TaskID, "4")
+
+	resp = ts.do(t, "GET", "/get-expression?requestID=legacy-1", "", nil)
+	var got task.Task
+	resp.decode(t, &got)
+	if got.Status != "completed" || got.Result != "4" || got.RequestID != "legacy-1" {
+		t.Errorf("get-expression = %+v, want completed 4", got)
+	}
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"id": "legacy-2", "expression": "2 +"}`, http.StatusBadRequest},
+		{`{"expression": "2 + 2"}`, http.StatusBadRequest},
+		{`{"id": "legacy-3"`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if resp := ts.do(t, "POST", "/add-calculation", tt.body, nil); resp.status != tt.status {
+			t.Errorf("add-calculation %s: status %d, want %d: %s", tt.body, resp.status, tt.status, resp.body)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"calcflow/backend/internal/orchestrator"
+	"calcflow/backend/internal/task"
+
+	"github.com/gorilla/mux"
+)
+
+// Получение настроек арендаторов: GET /api/v2/tenants.
+// В списке только арендаторы, настройки которых сохранялись.
+func (s *Server) ListTenantsHandler(w http.ResponseWriter, r *http.Request) {
+	tenants, err := s.orchestrator.ListTenants()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if tenants == nil {
+		tenants = []*task.Tenant{}
+	}
+
+	writeJSON(w, http.StatusOK, tenants)
+}
+
+// Получение настроек арендатора: GET /api/v2/tenants/{id}.
+func (s *Server) GetTenantHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.orchestrator.GetTenant(mux.Vars(r)["id"]))
+}
+
+// Изменение настроек арендатора: PUT /api/v2/tenants/{id}.
+func (s *Server) UpdateTenantHandler(w http.ResponseWriter, r *http.Request) {
+	var settings orchestrator.TenantSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tenant, err := s.orchestrator.UpdateTenant(mux.Vars(r)["id"], settings)
+	if errors.Is(err, orchestrator.ErrInvalidTenant) || errors.Is(err, orchestrator.ErrInvalidOperation) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tenant)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/task"
+
+	"github.com/gorilla/mux"
+)
+
+// TraceResponse представляет трассировку вычисления задачи в формате JSON.
+type TraceResponse struct {
+	TaskID     string           `json:"task_id"`
+	Expression string           `json:"expression"`
+	Expanded   string           `json:"expanded,omitempty"`
+	Status     string           `json:"status"`
+	Result     string           `json:"result"`
+	Steps      []task.TraceStep `json:"steps"`
+}
+
+// Получение пошаговой трассировки вычисления задачи.
+// Формат ответа выбирается параметром format=json|text или заголовком Accept.
+func (s *Server) GetTraceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskID := mux.Vars(r)["id"]
+
+	t, steps, err := s.orchestrator.GetTrace(access(r), taskID)
+	if errors.Is(err, database.ErrNotFound) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !t.Explain {
+		http.Error(w, "Trace was not requested for this task", http.StatusNotFound)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/plain") {
+		format = "text"
+	}
+
+	if format == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, renderTrace(t, steps))
+		return
+	}
+
+	if steps == nil {
+		steps = []task.TraceStep{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TraceResponse{
+		TaskID:     t.ID,
+		Expression: t.Expression,
+		Expanded:   t.Expanded,
+		Status:     t.Status,
+		Result:     t.Result,
+		Steps:      steps,
+	})
+}
+
+// renderTrace выводит трассировку в виде текстового дерева операций,
+// где потомками операции являются операции, вычислившие ее операнды.
+func renderTrace(t *task.Task, steps []task.TraceStep) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s = %s [%s]\n", t.Expression, t.Result, t.Status)
+
+	children := make(map[int][]task.TraceStep)
+	for _, step := range steps {
+		children[step.Parent] = append(children[step.Parent], step)
+	}
+
+	var render func(parent int, prefix string)
+	render = func(parent int, prefix string) {
+		nodes := children[parent]
+		for i, step := range nodes {
+			branch, indent := "├── ", "│   "
+			if i == len(nodes)-1 {
+				branch, indent = "└── ", "    "
+			}
+			computed := fmt.Sprintf("by %s, %s", step.Agent, step.Finished.Sub(step.Started))
+			if step.Shared {
+				computed = "shared with another task"
+			}
+			fmt.Fprintf(&b, "%s%s%s = %v  (%s %s)\n", prefix, branch,
+				step.Expression, step.Result, step.Operation, computed)
+			render(step.Step, prefix+indent)
+		}
+	}
+	render(0, "")
+
+	return b.String()
+}
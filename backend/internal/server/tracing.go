@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"calcflow/backend/internal/tracing"
+)
+
+// tracer создает участки трассировки запросов.
+var tracer = otel.Tracer("calcflow/backend/internal/server")
+
+// traceRequests записывает обработку запроса в участке трассировки. Если клиент
+// передал заголовок traceparent, участок продолжает его трассировку; контекст
+// участка передается обработчику, оркестратору и через задачу - агенту.
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+		ctx, span := tracer.Start(tracing.Extract(r.Context(), r.Header), "HTTP "+r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.route", route),
+			attribute.String("http.target", r.URL.RequestURI()),
+			attribute.Int("http.status_code", sw.status),
+		)
+		if sw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.status))
+		}
+	})
+}
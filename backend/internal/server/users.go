@@ -0,0 +1,114 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"calcflow/backend/internal/orchestrator"
+	"calcflow/backend/internal/task"
+)
+
+// Credentials представляет имя и пароль пользователя в запросах регистрации и входа.
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Регистрация пользователя арендатора из заголовка X-Tenant-ID: POST /api/v2/users.
+// Без учетных данных пользователь регистрируется только в арендаторе по умолчанию;
+// в другом арендаторе нужны учетные данные, привязанные к нему, или ключ
+// администратора, не привязанный к арендатору.
+func (s *Server) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	var request Credentials
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tenant, status, err := s.registrationTenant(r)
+	if err != nil {
+		if status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="calcflow"`)
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+
+	user, err := s.orchestrator.Register(tenant, request.Username, request.Password)
+	if errors.Is(err, orchestrator.ErrInvalidUser) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, orchestrator.ErrUserExists) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// registrationTenant возвращает арендатора, в котором запрос может зарегистрировать
+// пользователя, или код ответа и ошибку, если не может. Маршрут регистрации
+// доступен без учетных данных, поэтому они проверяются здесь, если переданы.
+func (s *Server) registrationTenant(r *http.Request) (string, int, error) {
+	tenant := r.Header.Get("X-Tenant-ID")
+	if tenant == "" {
+		tenant = task.DefaultTenant
+	}
+
+	secret := credentials(r)
+	if secret == "" {
+		if tenant != task.DefaultTenant {
+			return "", http.StatusUnauthorized, fmt.Errorf("API key or access token is required to register users in tenant %q", tenant)
+		}
+		return tenant, 0, nil
+	}
+
+	p, err := s.principal(secret)
+	if errors.Is(err, orchestrator.ErrUnauthorized) || errors.Is(err, orchestrator.ErrInvalidToken) {
+		return "", http.StatusUnauthorized, err
+	}
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+	switch bound := p.tenant(); {
+	case bound != "":
+		if r.Header.Get("X-Tenant-ID") != "" && tenant != bound {
+			return "", http.StatusForbidden, fmt.Errorf("credentials are bound to tenant %q", bound)
+		}
+		return bound, 0, nil
+	case p.scopes.Has(task.ScopeAdmin), tenant == task.DefaultTenant:
+		return tenant, 0, nil
+	}
+	return "", http.StatusForbidden, fmt.Errorf("credentials lack scope %s to register users in tenant %q", task.ScopeAdmin, tenant)
+}
+
+// Вход пользователя арендатора из заголовка X-Tenant-ID и получение токена доступа:
+// POST /api/v2/login.
+func (s *Server) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var request Credentials
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	token, err := s.orchestrator.Login(tenantFromRequest(r), request.Username, request.Password)
+	if errors.Is(err, orchestrator.ErrInvalidCredentials) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="calcflow"`)
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, token)
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"calcflow/backend/internal/database"
+	"calcflow/backend/internal/orchestrator"
+	"calcflow/backend/internal/task"
+
+	"github.com/gorilla/mux"
+)
+
+// APIPrefix - префикс маршрутов ресурсного API.
+const APIPrefix = "/api/v2"
+
+// CreateExpressionRequest представляет тело запроса на добавление выражения.
+type CreateExpressionRequest struct {
+	RequestID  string `json:"request_id"` // Необязательный; по умолчанию - заголовок X-Request-ID или идентификатор задачи
+	Expression string `json:"expression"`
+	Explain    bool   `json:"explain"`
+	NoCache    bool   `json:"no_cache"`
+}
+
+// ExpressionList представляет страницу списка выражений.
+type ExpressionList struct {
+	Items      []*task.Task `json:"items"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// ErrorResponse представляет тело ответа с ошибкой.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Добавление выражения для вычисления: POST /api/v2/expressions.
+func (s *Server) CreateExpressionV2Handler(w http.ResponseWriter, r *http.Request) {
+	var request CreateExpressionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if request.Expression == "" {
+		writeError(w, http.StatusBadRequest, "expression is required")
+		return
+	}
+
+	taskID := generateTaskID()
+	if request.RequestID == "" {
+		request.RequestID = r.Header.Get("X-Request-ID")
+	}
+	if request.RequestID == "" {
+		request.RequestID = taskID
+	}
+
+	created, err := s.orchestrator.AddCalculation(r.Context(), request.Expression, taskID, request.RequestID, orchestrator.CalculationOptions{
+		Tenant:   tenantFromRequest(r),
+		Explain:  request.Explain,
+		NoCache:  request.NoCache,
+		APIKeyID: apiKeyID(r),
+		Owner:    owner(r),
+		Client:   client(r),
+	})
+	if errors.Is(err, orchestrator.ErrInvalidExpression) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, orchestrator.ErrDuplicateRequest) {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, orchestrator.ErrQuotaExceeded) {
+		writeQuotaError(w, r, err)
+		return
+	}
+	if errors.Is(err, orchestrator.ErrBacklogFull) {
+		writeBacklogError(w, r, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	slog.InfoContext(r.Context(), "expression added", "task_id", created.ID, "status", created.Status)
+
+	w.Header().Set("Location", APIPrefix+"/expressions/"+created.ID)
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// Получение страницы списка выражений: GET /api/v2/expressions.
+func (s *Server) ListExpressionsV2Handler(w http.ResponseWriter, r *http.Request) {
+	query, err := parseTaskQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	expressions, next, err := s.orchestrator.ListExpressions(query)
+	if errors.Is(err, database.ErrInvalidQuery) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ExpressionList{Items: expressions, NextCursor: next})
+}
+
+// Получение выражения по идентификатору задачи: GET /api/v2/expressions/{id}.
+// С параметром wait, например ?wait=30s, ответ откладывается до завершения
+// вычисления или истечения времени ожидания; в последнем случае возвращается
+// незавершенная задача.
+func (s *Server) GetExpressionV2Handler(w http.ResponseWriter, r *http.Request) {
+	wait, err := parseWait(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var t *task.Task
+	if wait > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), wait)
+		defer cancel()
+		t, err = s.orchestrator.WaitExpression(ctx, access(r), mux.Vars(r)["id"])
+	} else {
+		t, err = s.orchestrator.GetExpression(access(r), mux.Vars(r)["id"])
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "expression not found")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+// Отмена вычисления выражения: DELETE /api/v2/expressions/{id}.
+func (s *Server) CancelExpressionV2Handler(w http.ResponseWriter, r *http.Request) {
+	t, err := s.orchestrator.CancelExpression(access(r), mux.Vars(r)["id"])
+	if errors.Is(err, database.ErrNotFound) {
+		writeError(w, http.StatusNotFound, "expression not found")
+		return
+	}
+	if errors.Is(err, orchestrator.ErrTaskFinished) {
+		writeError(w, http.StatusConflict, "expression is already "+t.Status)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+// Обновление времени выполнения операций: PUT /api/v2/operations.
+// В ответе возвращается время выполнения всех операций после обновления.
+func (s *Server) UpdateOperationsV2Handler(w http.ResponseWriter, r *http.Request) {
+	var newRequestTime task.CalculationRequest
+	if err := json.NewDecoder(r.Body).Decode(&newRequestTime); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err := s.orchestrator.UpdateCalculateTime(tenantFromRequest(r), newRequestTime)
+	if errors.Is(err, orchestrator.ErrInvalidOperation) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	operations, err := s.orchestrator.GetAvailableOperations(tenantFromRequest(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, operations)
+}
+
+// Регистрация новой версии пользовательской функции: POST /api/v2/functions.
+func (s *Server) CreateFunctionV2Handler(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Definition string `json:"definition"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if request.Definition == "" {
+		writeError(w, http.StatusBadRequest, "definition is required")
+		return
+	}
+
+	function, err := s.orchestrator.DefineFunction(tenantFromRequest(r), request.Definition)
+	if errors.Is(err, orchestrator.ErrInvalidFunction) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, orchestrator.ErrQuotaExceeded) {
+		writeError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", APIPrefix+"/functions/"+function.Name)
+	writeJSON(w, http.StatusCreated, function)
+}
+
+// Получение всех версий пользовательской функции: GET /api/v2/functions/{name}.
+func (s *Server) GetFunctionV2Handler(w http.ResponseWriter, r *http.Request) {
+	versions, err := s.orchestrator.GetFunctionVersions(tenantFromRequest(r), mux.Vars(r)["name"])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(versions) == 0 {
+		writeError(w, http.StatusNotFound, "function not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, versions)
+}
+
+// writeJSON отправляет ответ в формате JSON с заданным статусом.
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError отправляет ошибку в формате JSON с заданным статусом.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, ErrorResponse{Error: message})
+}
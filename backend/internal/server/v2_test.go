@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"calcflow/backend/internal/task"
+)
+
+func TestOperations(t *testing.T) {
+	ts := newTestServer(t)
+
+	// Без настроек действует время выполнения по умолчанию из реестра операций
+	var operations task.CalculationRequest
+	ts.do(t, "GET", "/get-available-operations", "", nil).decode(t, &operations)
+	if operations["summation"] != "10ms" || operations["exponentiation"] != "50ms" {
+		t.Errorf("default operations = %v", operations)
+	}
+
+	tests := []struct {
+		method, path, body string
+		status             int
+	}{
+		// Названия операций не зависят от регистра во всех маршрутах
+		{"PUT", APIPrefix + "/operations", `{"Summation": "1s"}`, http.StatusOK},
+		{"POST", "/update-operations", `{"MULTIPLICATION": "2s"}`, http.StatusOK},
+		{"PUT", APIPrefix + "/tenants/acme", `{"share": 0.5, "timings": {"Modulo": "3s"}}`, http.StatusOK},
+		{"PUT", APIPrefix + "/operations", `{"summation": "1s", "SUMMATION": "2s"}`, http.StatusBadRequest},
+		{"PUT", APIPrefix + "/operations", `{"power": "1s"}`, http.StatusBadRequest},
+		{"POST", "/update-operations", `{"power": "1s"}`, http.StatusBadRequest},
+		{"PUT", APIPrefix + "/operations", `{"summation": "fast"}`, http.StatusBadRequest},
+		{"PUT", APIPrefix + "/operations", `{"summation": "-1s"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if resp := ts.do(t, tt.method, tt.path, tt.body, nil); resp.status != tt.status {
+			t.Errorf("%s %s %s: status %d, want %d: %s", tt.method, tt.path, tt.body, resp.status, tt.status, resp.body)
+		}
+	}
+
+	ts.do(t, "GET", APIPrefix+"/operations", "", nil).decode(t, &operations)
+	if operations["summation"] != "1s" || operations["multiplication"] != "2s" || operations["modulo"] != "20ms" {
+		t.Errorf("operations = %v", operations)
+	}
+	ts.do(t, "GET", APIPrefix+"/operations", "", http.Header{"X-Tenant-ID": {"acme"}}).decode(t, &operations)
+	if operations["summation"] != "1s" || operations["modulo"] != "3s" {
+		t.Errorf("tenant operations = %v", operations)
+	}
+}
@@ -0,0 +1,68 @@
+package task
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Области доступа ключей API.
+const (
+	ScopeSubmit = "submit" // Добавление и отмена выражений, регистрация функций
+	ScopeRead   = "read"   // Чтение выражений, трассировок, функций и настроек
+	ScopeAdmin  = "admin"  // Изменение времени выполнения операций и управление ключами; включает остальные области
+)
+
+// APIKey представляет ключ API. Сам ключ не хранится: по нему вычисляется хеш,
+// а для отображения сохраняется несколько первых символов.
+type APIKey struct {
+	ID      string    `json:"id" gorm:"primaryKey"`
+	Name    string    `json:"name"`
+	Prefix  string    `json:"prefix"`                  // Начало ключа, по которому его можно узнать
+	Hash    string    `json:"-" gorm:"uniqueIndex"`    // SHA-256 ключа
+	Scopes  Scopes    `json:"scopes" gorm:"type:text"` // Области доступа
+	Tenant  string    `json:"tenant,omitempty"`        // Арендатор, к которому привязан ключ; пусто - любой
+	Created time.Time `json:"created"`
+	Rotated time.Time `json:"rotated"`              // Время последней замены ключа
+	Revoked time.Time `json:"revoked" gorm:"index"` // Время отзыва; нулевое для действующего ключа
+}
+
+// Active сообщает, что ключ не отозван.
+func (k *APIKey) Active() bool {
+	return k.Revoked.IsZero()
+}
+
+// Scopes представляет области доступа ключа API.
+type Scopes []string
+
+// Has сообщает, разрешает ли набор областей доступ к области scope.
+// Область admin разрешает доступ ко всем областям.
+func (s Scopes) Has(scope string) bool {
+	for _, granted := range s {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Value реализует интерфейс database/sql/driver.Valuer для Scopes.
+func (s Scopes) Value() (driver.Value, error) {
+	bytes, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(bytes), nil
+}
+
+// Scan реализует интерфейс database/sql/driver.Scanner для Scopes.
+func (s *Scopes) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	}
+	return errors.New("Scan: не удалось преобразовать в []byte")
+}
@@ -0,0 +1,25 @@
+package task
+
+import (
+	"strconv"
+	"time"
+)
+
+// CachedResult представляет закэшированный результат вычисления выражения.
+// Key - ключ кэша, см. CacheKeyFor.
+type CachedResult struct {
+	Key      string `gorm:"primaryKey"`
+	Result   string
+	Created  time.Time
+	Expires  time.Time `gorm:"index"`
+	LastUsed time.Time `gorm:"index"`
+	Hits     int
+}
+
+// CacheKeyFor возвращает ключ кэша результатов для нормализованной записи
+// выражения canonical арендатора tenant. Ключ начинается с имени арендатора
+// в кавычках, поэтому одинаковые выражения разных арендаторов не разделяют
+// ни кэш, ни выполняющиеся задачи.
+func CacheKeyFor(tenant, canonical string) string {
+	return strconv.Quote(tenant) + " " + canonical
+}
@@ -0,0 +1,43 @@
+package task
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Function представляет версию пользовательской функции, доступной в выражениях.
+type Function struct {
+	ID         uint      `json:"-" gorm:"primaryKey"`
+	Tenant     string    `json:"tenant" gorm:"uniqueIndex:idx_functions_version"`
+	Name       string    `json:"name" gorm:"uniqueIndex:idx_functions_version"`
+	Version    int       `json:"version" gorm:"uniqueIndex:idx_functions_version"`
+	Params     Params    `json:"params" gorm:"type:text"`
+	Body       string    `json:"body"`
+	Definition string    `json:"definition"`
+	Created    time.Time `json:"created"`
+}
+
+// Params представляет список имен параметров функции.
+type Params []string
+
+// Value реализует интерфейс database/sql/driver.Valuer для Params.
+func (p Params) Value() (driver.Value, error) {
+	bytes, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(bytes), nil
+}
+
+// Scan реализует интерфейс database/sql/driver.Scanner для Params.
+func (p *Params) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	}
+	return errors.New("Scan: не удалось преобразовать в []byte")
+}
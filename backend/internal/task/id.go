@@ -0,0 +1,74 @@
+package task
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// crockford - алфавит Base32 Крокфорда, сохраняющий порядок сортировки.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// idGenerator выдает идентификаторы в формате ULID: 48 бит времени в миллисекундах
+// и 80 случайных бит. В пределах одной миллисекунды случайная часть увеличивается
+// на единицу, поэтому идентификаторы строго возрастают и не повторяются.
+type idGenerator struct {
+	mu       sync.Mutex
+	lastTime uint64
+	hi       uint16 // Старшие 16 бит случайной части
+	lo       uint64 // Младшие 64 бита случайной части
+}
+
+var ids idGenerator
+
+// NewID возвращает новый уникальный идентификатор из 26 символов,
+// лексикографический порядок которых совпадает с порядком создания.
+func NewID() string {
+	return ids.next(time.Now())
+}
+
+func (g *idGenerator) next(now time.Time) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(now.UnixMilli())
+	if ms > g.lastTime {
+		var random [10]byte
+		if _, err := rand.Read(random[:]); err != nil {
+			panic(err)
+		}
+		g.lastTime = ms
+		g.hi = binary.BigEndian.Uint16(random[:2])
+		g.lo = binary.BigEndian.Uint64(random[2:])
+	} else {
+		// Часы не сдвинулись или ушли назад: продолжаем последовательность
+		g.lo++
+		if g.lo == 0 {
+			g.hi++
+			if g.hi == 0 {
+				// Случайная часть исчерпана: занимаем следующую миллисекунду
+				g.lastTime++
+			}
+		}
+	}
+
+	var value [16]byte
+	binary.BigEndian.PutUint64(value[:8], g.lastTime<<16|uint64(g.hi))
+	binary.BigEndian.PutUint64(value[8:], g.lo)
+	return encodeBase32(value)
+}
+
+// encodeBase32 кодирует 128 бит в 26 символов Base32 Крокфорда.
+func encodeBase32(value [16]byte) string {
+	hi := binary.BigEndian.Uint64(value[:8])
+	lo := binary.BigEndian.Uint64(value[8:])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
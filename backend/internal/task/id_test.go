@@ -0,0 +1,157 @@
+package task
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// idTime возвращает время в миллисекундах из первых 10 символов идентификатора.
+func idTime(t *testing.T, id string) uint64 {
+	t.Helper()
+	var ms uint64
+	for _, c := range id[:10] {
+		digit := strings.IndexRune(crockford, c)
+		if digit < 0 {
+			t.Fatalf("id %s: %q is not a Crockford Base32 digit", id, c)
+		}
+		ms = ms<<5 | uint64(digit)
+	}
+	return ms
+}
+
+func TestEncodeBase32(t *testing.T) {
+	var largest [16]byte
+	for i := range largest {
+		largest[i] = 0xff
+	}
+
+	tests := []struct {
+		value [16]byte
+		want  string
+	}{
+		{[16]byte{}, "00000000000000000000000000"},
+		{[16]byte{15: 1}, "00000000000000000000000001"},
+		{[16]byte{15: 32}, "00000000000000000000000010"},
+		{largest, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ"},
+	}
+	for _, tt := range tests {
+		if got := encodeBase32(tt.value); got != tt.want {
+			t.Errorf("encodeBase32(%x) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestIDFormat(t *testing.T) {
+	// Пример из спецификации ULID: время 1469918176385 кодируется как 01ARYZ6S41
+	var g idGenerator
+	id := g.next(time.UnixMilli(1469918176385))
+	if !strings.HasPrefix(id, "01ARYZ6S41") {
+		t.Errorf("id %s does not start with the timestamp 01ARYZ6S41", id)
+	}
+
+	before := uint64(time.Now().UnixMilli())
+	id = NewID()
+	after := uint64(time.Now().UnixMilli())
+	if len(id) != 26 {
+		t.Fatalf("id %s has %d characters, want 26", id, len(id))
+	}
+	if strings.Trim(id, crockford) != "" {
+		t.Errorf("id %s has characters outside the Crockford Base32 alphabet", id)
+	}
+	if ms := idTime(t, id); ms < before || ms > after {
+		t.Errorf("id %s has time %d, want between %d and %d", id, ms, before, after)
+	}
+}
+
+func TestIDMonotonic(t *testing.T) {
+	var g idGenerator
+	now := time.UnixMilli(1700000000000)
+
+	// В пределах одной миллисекунды и при переводе часов назад идентификаторы
+	// возрастают, а время в них не меняется
+	prev := g.next(now)
+	for i := 0; i < 1000; i++ {
+		at := now
+		if i%2 == 1 {
+			at = now.Add(-time.Second)
+		}
+		id := g.next(at)
+		if id <= prev {
+			t.Fatalf("id %s after %s is not greater", id, prev)
+		}
+		if idTime(t, id) != idTime(t, prev) {
+			t.Fatalf("id %s changed the time of %s", id, prev)
+		}
+		prev = id
+	}
+
+	// В следующей миллисекунде идентификатор больше любого из предыдущей
+	if id := g.next(now.Add(time.Millisecond)); id <= prev || idTime(t, id) != uint64(now.UnixMilli())+1 {
+		t.Errorf("id %s in the next millisecond after %s", id, prev)
+	}
+}
+
+func TestIDRandomOverflow(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	g := idGenerator{lastTime: uint64(now.UnixMilli()), hi: math.MaxUint16, lo: math.MaxUint64 - 1}
+
+	prev := g.next(now)
+	if !strings.HasSuffix(prev, "ZZZZZZZZZZZZZZZZ") {
+		t.Fatalf("id %s, want the largest random part", prev)
+	}
+	// Случайная часть исчерпана: идентификатор занимает следующую миллисекунду
+	id := g.next(now)
+	if id <= prev {
+		t.Errorf("id %s after %s is not greater", id, prev)
+	}
+	if idTime(t, id) != uint64(now.UnixMilli())+1 {
+		t.Errorf("id %s has time %d, want the next millisecond", id, idTime(t, id))
+	}
+}
+
+func TestIDConcurrent(t *testing.T) {
+	const goroutines, perGoroutine = 8, 2000
+
+	results := make([][]string, goroutines)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids := make([]string, perGoroutine)
+			for j := range ids {
+				ids[j] = NewID()
+			}
+			results[i] = ids
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool, goroutines*perGoroutine)
+	var all []string
+	for _, ids := range results {
+		// Каждая горутина получает возрастающую последовательность
+		if !sort.StringsAreSorted(ids) {
+			t.Error("ids of one goroutine are not increasing")
+		}
+		for _, id := range ids {
+			if seen[id] {
+				t.Fatalf("duplicate id %s", id)
+			}
+			seen[id] = true
+		}
+		all = append(all, ids...)
+	}
+
+	// Порядок строк совпадает с порядком времени в идентификаторах
+	sort.Strings(all)
+	for i := 1; i < len(all); i++ {
+		if idTime(t, all[i]) < idTime(t, all[i-1]) {
+			t.Fatalf("id %s sorts after %s but is older", all[i], all[i-1])
+		}
+	}
+}
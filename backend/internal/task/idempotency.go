@@ -0,0 +1,17 @@
+package task
+
+import "time"
+
+// IdempotencyRecord представляет ключ идемпотентности запроса и сохраненный ответ на него.
+// Пара (Tenant, Key) уникальна, поэтому повторный запрос не может выполниться дважды.
+type IdempotencyRecord struct {
+	Tenant      string `gorm:"primaryKey"`
+	Key         string `gorm:"primaryKey"`
+	Fingerprint string // Хеш метода, пути и тела запроса
+	Completed   bool   // Ответ сохранен; иначе запрос еще выполняется
+	StatusCode  int
+	ContentType string
+	Location    string
+	Body        []byte
+	Created     time.Time `gorm:"index"`
+}
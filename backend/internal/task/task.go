@@ -5,26 +5,45 @@ import (
 	"encoding/json"
 	"errors"
 	"time"
+
+	"calcflow/backend/internal/expr"
 )
 
 // Task представляет структуру арифметического выражения.
 type Task struct {
-	ID         string        `json:"id"`
-	RequestID  string        `json:"X-Request-id"`
-	Expression string        `json:"expression"`
-	Status     string        `json:"status"`
-	Result     string        `json:"result"`
-	Created    time.Time     `json:"created"`
-	Finished   time.Time     `json:"finished"`
-	Duration   time.Duration `json:"duration"`
+	ID          string        `json:"id" gorm:"primaryKey"`
+	RequestID   string        `json:"X-Request-id" gorm:"uniqueIndex"`
+	Expression  string        `json:"expression"`
+	Expanded    string        `json:"expanded,omitempty"` // Выражение с раскрытыми пользовательскими функциями
+	Status      string        `json:"status" gorm:"index"`
+	Result      string        `json:"result"`
+	Created     time.Time     `json:"created" gorm:"index"`
+	Finished    time.Time     `json:"finished" gorm:"index"`
+	Duration    time.Duration `json:"duration" gorm:"index"`
+	Explain     bool          `json:"explain,omitempty"`                 // Записывать ли пошаговую трассировку вычисления
+	Trace       []TraceStep   `json:"-" gorm:"-"`                        // Трассировка, переданная агентом вместе с результатом
+	CacheKey    string        `json:"-" gorm:"index"`                    // Нормализованная запись выражения для кэша результатов
+	Cached      bool          `json:"cached,omitempty"`                  // Результат получен из кэша или от идентичной задачи
+	Graph       *expr.Graph   `json:"-" gorm:"-"`                        // Граф операций, построенный оркестратором
+	APIKeyID    string        `json:"api_key_id,omitempty" gorm:"index"` // Ключ API, с которым добавлено выражение
+	Owner       string        `json:"owner,omitempty" gorm:"index"`      // Пользователь, добавивший выражение
+	Tenant      string        `json:"tenant" gorm:"index"`               // Арендатор, которому принадлежит выражение
+	TraceParent string        `json:"-"`                                 // Контекст трассировки добавления выражения в формате W3C traceparent
+}
+
+// Done сообщает, завершено ли вычисление: успешно, с ошибкой или отменой.
+func (t *Task) Done() bool {
+	return t.Status == "completed" || t.Status == "error" || t.Status == "canceled"
 }
 
-// CalculationRequest представляет значения выполнения каждой арифметической операции.
-type CalculationRequest struct {
-	Summation      string `json:"summation"`
-	Subtraction    string `json:"subtraction"`
-	Multiplication string `json:"multiplication"`
-	Division       string `json:"division"`
+// CalculationRequest представляет значения выполнения каждой арифметической операции:
+// ключом является название операции (например, "summation"), значением - длительность.
+type CalculationRequest map[string]string
+
+// OperationTiming представляет время выполнения одной операции в таблице `OperationTimings`.
+type OperationTiming struct {
+	Operation string `gorm:"primaryKey"`
+	Duration  string
 }
 
 // Value реализует интерфейс database/sql/driver.Valuer для CalcRequest.
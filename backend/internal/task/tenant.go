@@ -0,0 +1,23 @@
+package task
+
+import "time"
+
+// DefaultTenant - арендатор запросов, в которых арендатор не указан.
+const DefaultTenant = "default"
+
+// Tenant представляет настройки арендатора. Арендаторы без сохраненных
+// настроек используют NewTenant.
+type Tenant struct {
+	ID           string             `json:"id" gorm:"primaryKey"`
+	Timings      CalculationRequest `json:"timings" gorm:"type:text"` // Время выполнения операций, заменяющее общее
+	Share        float64            `json:"share"`                    // Доля мощности агентов, от 0 до 1
+	MaxPending   int                `json:"max_pending"`              // Наибольшее число невычисленных выражений; 0 - без ограничения
+	MaxFunctions int                `json:"max_functions"`            // Наибольшее число пользовательских функций; 0 - без ограничения
+	Updated      time.Time          `json:"updated"`
+}
+
+// NewTenant возвращает настройки арендатора по умолчанию: вся мощность агентов
+// и общее время выполнения операций без ограничений.
+func NewTenant(id string) *Tenant {
+	return &Tenant{ID: id, Timings: CalculationRequest{}, Share: 1}
+}
@@ -0,0 +1,47 @@
+package task
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// TraceStep представляет одну операцию в пошаговой трассировке вычисления задачи.
+type TraceStep struct {
+	ID         uint      `json:"-" gorm:"primaryKey"`
+	TaskID     string    `json:"-" gorm:"index"`
+	Step       int       `json:"step"`             // Порядковый номер операции, начиная с 1
+	Parent     int       `json:"parent,omitempty"` // Номер операции, использующей результат этой; 0 для корня
+	Operation  string    `json:"operation"`
+	Expression string    `json:"expression"` // Подвыражение, вычисленное операцией
+	Operands   Operands  `json:"operands" gorm:"type:text"`
+	Result     float64   `json:"result"`
+	Agent      string    `json:"agent"`
+	Shared     bool      `json:"shared,omitempty"` // Результат взят у другой задачи, вычислявшей ту же операцию
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+}
+
+// Operands представляет значения операндов операции.
+type Operands []float64
+
+// Value реализует интерфейс database/sql/driver.Valuer для Operands.
+func (o Operands) Value() (driver.Value, error) {
+	bytes, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	return string(bytes), nil
+}
+
+// Scan реализует интерфейс database/sql/driver.Scanner для Operands.
+func (o *Operands) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, o)
+	case string:
+		return json.Unmarshal([]byte(v), o)
+	}
+	return errors.New("Scan: не удалось преобразовать в []byte")
+}
@@ -0,0 +1,13 @@
+package task
+
+import "time"
+
+// Usage представляет расход суточной квоты клиента: выражения, добавленные
+// за сутки по UTC, и оценку времени их вычисления.
+type Usage struct {
+	Client      string        `json:"client" gorm:"primaryKey"` // Клиент: ключ API, пользователь или IP-адрес
+	Day         string        `json:"day" gorm:"primaryKey"`    // Сутки в формате 2006-01-02
+	Expressions int           `json:"expressions"`              // Число добавленных выражений
+	Operations  int           `json:"operations"`               // Число операций в добавленных выражениях
+	Cost        time.Duration `json:"cost"`                     // Оценка суммарного времени вычисления
+}
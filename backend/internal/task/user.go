@@ -0,0 +1,13 @@
+package task
+
+import "time"
+
+// User представляет учетную запись пользователя. Пароль не хранится:
+// сохраняется только его хеш.
+type User struct {
+	ID           string    `json:"id" gorm:"primaryKey"`
+	Username     string    `json:"username" gorm:"uniqueIndex:idx_users_tenant_username,priority:2"` // Уникально в пределах арендатора
+	PasswordHash string    `json:"-"`
+	Tenant       string    `json:"tenant" gorm:"uniqueIndex:idx_users_tenant_username,priority:1"` // Арендатор, к которому относится пользователь
+	Created      time.Time `json:"created"`
+}
@@ -1,14 +1,48 @@
 package taskresult
 
-import "calcflow/backend/internal/task"
+import (
+	"errors"
+	"time"
+
+	"calcflow/backend/internal/expr"
+	"calcflow/backend/internal/task"
+)
+
+// ErrQueueFull возвращается TaskProcessor.EnqueueTask, если очередь агента заполнена.
+var ErrQueueFull = errors.New("agent queue is full")
 
 // ResultProcessor интерфейс для обработки результатов выполнения задач.
 type ResultProcessor interface {
 	ReceiveResult(task *task.Task) error
-	GetAvailableOperations() (*task.CalculationRequest, error)
+	GetAvailableOperations(tenant string) (task.CalculationRequest, error)
+	Memo() *expr.Memo
 	EnqueueTask(task *task.Task)
 }
 
+// AgentState описывает состояние агента для проверок работоспособности и диагностики.
+type AgentState struct {
+	Name      string         `json:"name"`
+	Alive     bool           `json:"alive"`      // Агент принимает задачи из своей очереди
+	Queued    int            `json:"queued"`     // Задачи в очереди агента, еще не взятые в работу
+	QueueSize int            `json:"queue_size"` // Размер очереди агента
+	InFlight  []InFlightTask `json:"in_flight"`  // Задачи, которые агент вычисляет, от давно начатых к недавним
+}
+
+// InFlightTask описывает задачу, которую вычисляет агент.
+type InFlightTask struct {
+	ID      string        `json:"id"`
+	Tenant  string        `json:"tenant"`
+	Started time.Time     `json:"started"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// AgentReporter сообщает состояние агента оркестратору.
+type AgentReporter interface {
+	State() AgentState
+}
+
+// TaskProcessor интерфейс для отправки задач агентам. EnqueueTask не блокируется:
+// если агент не может принять задачу, возвращается ErrQueueFull.
 type TaskProcessor interface {
-	EnqueueTask(task *task.Task)
+	EnqueueTask(task *task.Task) error
 }
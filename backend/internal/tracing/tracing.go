@@ -0,0 +1,122 @@
+// Package tracing настраивает распределенную трассировку OpenTelemetry: экспорт
+// участков (spans) в файл строками JSON или по протоколу OTLP/HTTP и передачу
+// контекста в формате W3C Trace Context (заголовок traceparent). Участки создаются
+// через API go.opentelemetry.io/otel; пока экспорт не настроен, они не записываются,
+// но контекст трассировки клиента все равно передается дальше.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName - имя сервиса в экспортируемых участках.
+const ServiceName = "calcflow"
+
+// traceParentHeader - заголовок W3C Trace Context с контекстом участка.
+const traceParentHeader = "traceparent"
+
+// Config задает, куда экспортируются участки. Если оба поля пусты, трассировка
+// отключена.
+type Config struct {
+	File         string // Файл для участков строками JSON или stdout
+	OTLPEndpoint string // Адрес приемника OTLP/HTTP, например http://localhost:4318
+}
+
+func init() {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+// Setup настраивает глобального поставщика участков OpenTelemetry. Возвращаемая
+// функция завершает экспорт, отправляя накопленные участки, и закрывает файл.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	if cfg.File == "" && cfg.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+
+	var file io.Closer
+	if cfg.File != "" {
+		out := os.Stdout
+		if cfg.File != "stdout" {
+			f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return nil, err
+			}
+			out, file = f, f
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(out))
+		if err != nil {
+			return nil, err
+		}
+		// Участки записываются в файл сразу по завершении и не теряются при остановке
+		opts = append(opts, sdktrace.WithSyncer(exporter))
+	}
+	if cfg.OTLPEndpoint != "" {
+		exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}, nil
+}
+
+// Extract возвращает контекст с удаленным участком из заголовка traceparent запроса.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// ContextFrom возвращает контекст с удаленным участком из значения traceparent,
+// например сохраненного в задаче. Пустое или неверное значение игнорируется,
+// и участки, начатые от этого контекста, начинают новую трассировку.
+func ContextFrom(traceparent string) context.Context {
+	carrier := propagation.MapCarrier{traceParentHeader: traceparent}
+	return otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+}
+
+// TraceParent возвращает контекст участка из ctx в формате traceparent или пустую
+// строку, если участка нет.
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier.Get(traceParentHeader)
+}
+
+// SetError отмечает участок как завершившийся ошибкой err; nil ничего не меняет.
+func SetError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,35 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceParent(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"valid", traceparent, traceparent},
+		{"empty", "", ""},
+		{"malformed", "00-4bf92f3577b34da6-00f067aa0ba902b7-01", ""},
+		{"zero trace ID", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", ""},
+	}
+	for _, tt := range tests {
+		if got := TraceParent(ContextFrom(tt.in)); got != tt.want {
+			t.Errorf("%s: TraceParent(ContextFrom(%q)) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+
+	header := http.Header{"Traceparent": {traceparent}}
+	sc := trace.SpanContextFromContext(Extract(context.Background(), header))
+	if sc.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID().String() != "00f067aa0ba902b7" || !sc.IsRemote() {
+		t.Errorf("Extract = %v, want the remote span from the header", sc)
+	}
+}